@@ -1,18 +1,69 @@
 package main
 
 import (
+	"context"
 	"log"
 	"net/http"
 	"os"
 	"time"
 
 	"home-finder/internal/api"
+	"home-finder/internal/finance"
+	"home-finder/internal/photo"
+	"home-finder/internal/provider"
+	"home-finder/internal/store"
 )
 
 func main() {
 	addr := ":" + getEnv("PORT", "8080")
 
-	handler := api.NewRouter()
+	cfg := api.Config{Providers: provider.RegistryFromEnv()}
+	if dsn := os.Getenv("DATABASE_URL"); dsn != "" {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		db, err := store.Open(ctx, dsn)
+		if err != nil {
+			log.Fatalf("listing store: %v", err)
+		}
+		if err := db.Migrate(ctx); err != nil {
+			log.Fatalf("listing store migrations: %v", err)
+		}
+		cancel()
+		defer db.Close()
+		cfg.Listings = db
+		cfg.Users = db
+		cfg.SavedSearches = db
+	} else {
+		log.Printf("DATABASE_URL not set; serving demo/upstream listings only")
+	}
+
+	photos, err := photo.CacheFromEnv()
+	if err != nil {
+		log.Fatalf("photo cache: %v", err)
+	}
+	cfg.Photos = photos
+
+	taxes, err := finance.TaxTableFromEnv()
+	if err != nil {
+		log.Fatalf("property tax table: %v", err)
+	}
+	finance.Taxes = taxes
+
+	staleAfter, err := time.ParseDuration(getEnv("LISTING_STALE_AFTER", "24h"))
+	if err != nil || staleAfter < 0 {
+		log.Fatalf("LISTING_STALE_AFTER must be a non-negative duration")
+	}
+	cfg.StaleAfter = staleAfter
+
+	proxies, err := api.ParseTrustedProxies(os.Getenv("TRUSTED_PROXIES"))
+	if err != nil {
+		log.Fatalf("TRUSTED_PROXIES: %v", err)
+	}
+	if len(proxies) == 0 {
+		log.Printf("TRUSTED_PROXIES not set; X-User-ID is ignored and per-user features are off")
+	}
+	cfg.TrustedProxies = proxies
+
+	handler := api.NewRouter(cfg)
 	server := &http.Server{
 		Addr:         addr,
 		Handler:      handler,
@@ -0,0 +1,41 @@
+// Command visionmock serves vision.MockServer so the vision client can be run
+// against recorded responses:
+//
+//	go run ./cmd/visionmock -addr :8090 -rate-limit-every 3
+//	VISION_API_BASE=http://localhost:8090/v1 VISION_API_KEY=mock ...
+//
+// Photos with recordings are at http://localhost:8090/photos/{name}.png.
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+
+	"home-finder/internal/vision"
+)
+
+func main() {
+	addr := flag.String("addr", ":8090", "listen address")
+	key := flag.String("key", "", "required bearer token (empty accepts any)")
+	rateLimitEvery := flag.Int("rate-limit-every", 0, "answer every Nth completion with 429")
+	failEvery := flag.Int("fail-every", 0, "answer every Nth completion with 503")
+	delay := flag.Duration("delay", 0, "delay before each completion")
+	flag.Parse()
+
+	mock, err := vision.NewMockServer()
+	if err != nil {
+		log.Fatalf("vision mock: %v", err)
+	}
+	mock.APIKey = *key
+	mock.RateLimitEvery = *rateLimitEvery
+	mock.FailEvery = *failEvery
+	mock.Delay = *delay
+
+	names := mock.Recordings()
+	sort.Strings(names)
+	log.Printf("vision mock listening on %s; recorded photos: %s", *addr, strings.Join(names, ", "))
+	log.Fatal(http.ListenAndServe(*addr, mock.Handler()))
+}
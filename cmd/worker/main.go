@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"home-finder/internal/enrich"
+	"home-finder/internal/ingest"
+	"home-finder/internal/photo"
+	"home-finder/internal/provider"
+	"home-finder/internal/store"
+	"home-finder/internal/vision"
+)
+
+func main() {
+	revalidate := flag.Bool("revalidate", false, "redo cached vision results from an older model or prompt version (e.g. after a vocabulary change), then exit")
+	flag.Parse()
+	addr := ":" + getEnv("PORT", "8081")
+
+	dsn := os.Getenv("DATABASE_URL")
+	if dsn == "" {
+		log.Fatalf("DATABASE_URL is required for the ingest worker")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	openCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	db, err := store.Open(openCtx, dsn)
+	if err != nil {
+		log.Fatalf("listing store: %v", err)
+	}
+	if err := db.Migrate(openCtx); err != nil {
+		log.Fatalf("listing store migrations: %v", err)
+	}
+	cancel()
+	defer db.Close()
+
+	photos, err := photo.CacheFromEnv()
+	if err != nil {
+		log.Fatalf("photo cache: %v", err)
+	}
+
+	// Vision answers are cached by photo content, model and prompt version;
+	// without the photo cache the URL stands in for the content.
+	var visionCache *vision.CachedClient
+	if client, ok := vision.ClientFromEnv().(*vision.HTTPClient); ok {
+		var hasher vision.Hasher
+		if photos != nil {
+			client.Photos = photos
+			hasher = photos
+		}
+		ttl, err := vision.CacheTTLFromEnv()
+		if err != nil {
+			log.Fatalf("vision cache config: %v", err)
+		}
+		visionCache = vision.NewCachedClient(client, client.Model, client.Version(), db, hasher, ttl)
+	}
+
+	if *revalidate {
+		if visionCache == nil {
+			log.Fatalf("-revalidate needs VISION_API_KEY")
+		}
+		refreshed, failed, err := visionCache.Revalidate(ctx)
+		log.Printf("vision cache revalidated for %s: refreshed=%d failed=%d", visionCache.Version(), refreshed, failed)
+		if err != nil {
+			log.Fatalf("vision cache revalidate: %v", err)
+		}
+		return
+	}
+
+	cfg, err := ingest.ConfigFromEnv()
+	if err != nil {
+		log.Fatalf("ingest config: %v", err)
+	}
+	registry := provider.RegistryFromEnv()
+	cfg.SourcePriority = registry.Priority()
+	upstream, label, ok := registry.Pager(os.Getenv("INGEST_PROVIDER"))
+	if !ok {
+		log.Fatalf("no pageable upstream configured; set SCRAPER_LISTINGS_BASE or LISTINGS_API_BASE (and INGEST_PROVIDER to pick one)")
+	}
+
+	var enricher *enrich.Enricher
+	if visionCache != nil {
+		enrichCfg, err := enrich.ConfigFromEnv()
+		if err != nil {
+			log.Fatalf("vision enrichment config: %v", err)
+		}
+		enricher = enrich.New(visionCache, enrichCfg, db)
+		log.Printf("worker: vision enrichment on (%d workers, min confidence %.2f)", enrichCfg.Workers, enrichCfg.MinConfidence)
+	}
+
+	worker := ingest.NewWorker(cfg, label, upstream, db, db, photos, enricher)
+	stopped := make(chan struct{})
+	go func() {
+		worker.Run(ctx)
+		close(stopped)
+	}()
+
+	adminToken := os.Getenv("ADMIN_TOKEN")
+	if adminToken == "" {
+		log.Printf("ADMIN_TOKEN not set; admin routes answer 403")
+	}
+	server := &http.Server{
+		Addr:         addr,
+		Handler:      ingest.NewAdminRouter(worker, db, visionCache, adminToken),
+		ReadTimeout:  5 * time.Second,
+		WriteTimeout: 10 * time.Second,
+		IdleTimeout:  60 * time.Second,
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		_ = server.Shutdown(shutdownCtx)
+	}()
+
+	log.Printf("worker: %d ingest job(s) every %s from %s; admin listening on %s",
+		len(cfg.Jobs), cfg.Interval, label, addr)
+	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		log.Fatalf("server error: %v", err)
+	}
+	// Let a cancelled cycle wind down before the store closes.
+	<-stopped
+}
+
+func getEnv(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
@@ -14,6 +14,7 @@ export type Listing = {
   lotSqft: number;
   propertyType: string;
   photoUrl: string;
+  photos?: { url: string; hash?: string }[];
   tags: string[];
   visionTags?: string[];
   source: string;
@@ -0,0 +1,94 @@
+// Package amenity reconciles a listing's amenity flags (HasPool and friends)
+// with what its photos show. A photo can show an amenity but cannot rule one
+// out, since the pool may simply be out of frame, so vision evidence either
+// confirms a listed amenity or contradicts an unlisted one; everything else
+// stays unverified.
+package amenity
+
+import (
+	"strings"
+
+	"home-finder/internal/types"
+)
+
+const (
+	Confirmed    = "confirmed"    // listed and seen in a photo
+	Contradicted = "contradicted" // seen in a photo but not listed
+	Unverified   = "unverified"   // listed, but no photo shows it
+)
+
+// Flag ties a listing flag to the vision tags that evidence it.
+type Flag struct {
+	Name   string // search parameter and filter= field name
+	Column string // listings column
+	Tags   []string
+	Listed func(types.Listing) bool
+}
+
+// Flags are the amenity flags vision can verify, in display order.
+var Flags = []Flag{
+	{"pool", "has_pool", []string{"pool"}, func(l types.Listing) bool { return l.HasPool }},
+	{"waterfront", "has_waterfront", []string{"waterfront"}, func(l types.Listing) bool { return l.HasWaterfront }},
+	{"view", "has_view", []string{"lake view", "water view", "city view", "mountain view"}, func(l types.Listing) bool { return l.HasView }},
+	{"basement", "has_basement", []string{"basement"}, func(l types.Listing) bool { return l.HasBasement }},
+	{"fireplace", "has_fireplace", []string{"fireplace"}, func(l types.Listing) bool { return l.HasFireplace }},
+	{"adu", "has_adu", []string{"adu"}, func(l types.Listing) bool { return l.HasADU }},
+	{"rv_parking", "has_rv_parking", []string{"rv parking", "rv garage"}, func(l types.Listing) bool { return l.HasRVParking }},
+}
+
+// Lookup finds a flag by name.
+func Lookup(name string) (Flag, bool) {
+	for _, f := range Flags {
+		if f.Name == name {
+			return f, true
+		}
+	}
+	return Flag{}, false
+}
+
+// Seen reports whether any of l's vision tags evidences f.
+func (f Flag) Seen(l types.Listing) bool {
+	for _, t := range l.VisionTags {
+		t = strings.ToLower(strings.TrimSpace(t))
+		for _, want := range f.Tags {
+			if t == want {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Check classifies one flag, or returns "" when the listing neither lists
+// the amenity nor shows it.
+func (f Flag) Check(l types.Listing) string {
+	listed, seen := f.Listed(l), f.Seen(l)
+	switch {
+	case listed && seen:
+		return Confirmed
+	case seen:
+		return Contradicted
+	case listed:
+		return Unverified
+	}
+	return ""
+}
+
+// Reconcile fills l.Verification and l.Contradictions (in Flags order) from
+// its flags and vision tags.
+func Reconcile(l *types.Listing) {
+	l.Verification, l.Contradictions = nil, nil
+	for _, f := range Flags {
+		status := f.Check(*l)
+		if status == "" {
+			continue
+		}
+		if l.Verification == nil {
+			l.Verification = make(map[string]string)
+		}
+		l.Verification[f.Name] = status
+		if status == Contradicted {
+			l.Contradictions = append(l.Contradictions, f.Name)
+		}
+	}
+}
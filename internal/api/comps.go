@@ -0,0 +1,126 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"home-finder/internal/comps"
+	"home-finder/internal/provider"
+	"home-finder/internal/store"
+	"home-finder/internal/types"
+)
+
+// compCandidates caps how many sold listings are ranked per radius.
+const compCandidates = 200
+
+// compsResponse is a listing's comp set and the value estimated from it.
+type compsResponse struct {
+	Subject        types.Listing           `json:"subject"`
+	Comps          []comps.Comp            `json:"comps"`
+	Estimate       *comps.Estimate         `json:"estimate,omitempty"`
+	RadiusMi       float64                 `json:"radiusMi,omitempty"`
+	SoldWithinDays int                     `json:"soldWithinDays"`
+	Rates          comps.Rates             `json:"rates"`
+	Sources        []provider.SourceStatus `json:"sources,omitempty"`
+}
+
+// compsHandler serves GET /listings/{id}/comps. radius_mi, sold_within_days
+// and limit override comps.DefaultParams; without an explicit radius_mi the
+// search widens to two and then four times the default until it finds
+// enough comps. No comps is not an error, just a response without an
+// estimate.
+func (s *server) compsHandler(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	q := r.URL.Query()
+	p := comps.DefaultParams
+	radii := []float64{p.RadiusMi, 2 * p.RadiusMi, 4 * p.RadiusMi}
+	if raw := q.Get("radius_mi"); raw != "" {
+		v, err := strconv.ParseFloat(raw, 64)
+		if err != nil || !(v > 0) || math.IsInf(v, 0) {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "radius_mi must be a positive number of miles"})
+			return
+		}
+		p.RadiusMi, radii = v, []float64{v}
+	}
+	if v, err := strconv.Atoi(q.Get("sold_within_days")); err == nil && v > 0 {
+		p.SoldWithinDays = v
+	}
+	if v, err := strconv.Atoi(q.Get("limit")); err == nil && v > 0 {
+		// A smaller comp set is enough sooner; otherwise the search would
+		// always widen to the last radius.
+		p.MaxComps, p.MinComps = v, min(p.MinComps, v)
+	}
+
+	subject, err := s.lookupListing(r.Context(), id)
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "listing not found"})
+		return
+	case errors.Is(err, errUpstream):
+		writeJSON(w, http.StatusBadGateway, map[string]any{"error": err.Error(), "sources": subject.Sources})
+		return
+	case err != nil:
+		log.Printf("listing %s lookup failed: %v", id, err)
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "lookup failed"})
+		return
+	}
+
+	now := time.Now()
+	resp := compsResponse{Subject: subject.Listing, Comps: []comps.Comp{}, SoldWithinDays: p.SoldWithinDays, Rates: comps.DefaultRates}
+	if !subject.Listing.HasLocation() {
+		// Without coordinates candidates come from the same zip or city.
+		radii = radii[:1]
+	}
+	for _, radius := range radii {
+		p.RadiusMi = radius
+		f := comps.Filters(subject.Listing, p)
+		candidates, sources, err := s.soldListings(r.Context(), f)
+		if err != nil {
+			log.Printf("listing %s comps search failed: %v", id, err)
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "comps search failed"})
+			return
+		}
+		resp.Sources = sources
+		resp.Comps = comps.Select(subject.Listing, candidates, p, comps.DefaultRates, now)
+		if f.Near != nil {
+			resp.RadiusMi = radius
+		}
+		if len(resp.Comps) >= p.MinComps {
+			break
+		}
+	}
+	if est, ok := comps.Value(subject.Listing, resp.Comps); ok {
+		resp.Estimate = &est
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// soldListings runs a comps search against the store, or the providers and
+// then the demo data when there is none.
+func (s *server) soldListings(ctx context.Context, f types.SearchFilters) ([]types.Listing, []provider.SourceStatus, error) {
+	if s.listings != nil {
+		page := types.PageRequest{Limit: compCandidates}
+		if f.Near != nil {
+			page.Sort = "distance"
+		}
+		res, err := s.listings.Search(ctx, f, page)
+		return res.Results, nil, err
+	}
+	source := sampleListings
+	var sources []provider.SourceStatus
+	if s.providers != nil && s.providers.Len() > 0 {
+		var remote []types.Listing
+		remote, sources = s.providers.Search(ctx, f)
+		if len(remote) > 0 {
+			source = remote
+		}
+	}
+	return filterListings(f, source), sources, nil
+}
@@ -0,0 +1,49 @@
+package api
+
+import (
+	"strconv"
+	"strings"
+
+	"home-finder/internal/facet"
+	"home-finder/internal/types"
+)
+
+// computeFacets counts each named facet over listings matching filters minus
+// that facet's own filter, using the same predicates as filterListings.
+func computeFacets(filters types.SearchFilters, listings []types.Listing, names []string) types.Facets {
+	out := make(types.Facets, len(names))
+	for _, name := range names {
+		f := facet.Without(filters, name)
+		matched := filterListings(f, listings)
+		if name == facet.PriceHistogram {
+			counts := map[int]int{}
+			for _, l := range matched {
+				counts[facet.PriceBucket(l.Price)]++
+			}
+			out[name] = facet.Histogram(counts)
+			continue
+		}
+		counts := map[string]int{}
+		for _, l := range matched {
+			switch name {
+			case facet.PropertyType:
+				counts[strings.ToLower(strings.TrimSpace(l.PropertyType))]++
+			case facet.Beds:
+				counts[strconv.Itoa(l.Beds)]++
+			case facet.City:
+				counts[strings.TrimSpace(l.City)]++
+			case facet.Tags:
+				seen := map[string]bool{}
+				for _, t := range l.TagPool(f.UseVision, f.MinVisionConfidence) {
+					t = normalizeTag(t)
+					if t != "" && !seen[t] {
+						seen[t] = true
+						counts[t]++
+					}
+				}
+			}
+		}
+		out[name] = facet.Values(name, counts)
+	}
+	return out
+}
@@ -0,0 +1,171 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"home-finder/internal/finance"
+	"home-finder/internal/store"
+	"home-finder/internal/types"
+)
+
+// financing resolves the request's financing assumptions: the defaults,
+// then the caller's saved ones, then any query params.
+func (s *server) financing(r *http.Request) (types.Financing, error) {
+	return parseFinancing(r.URL.Query(), s.userFinancing(r.Context(), userID(r)))
+}
+
+// userFinancing is the user's saved financing, or the defaults.
+func (s *server) userFinancing(ctx context.Context, uid string) types.Financing {
+	if uid == "" || s.users == nil {
+		return finance.Defaults
+	}
+	saved, err := s.users.Financing(ctx, uid)
+	if err != nil {
+		if !errors.Is(err, store.ErrNotFound) {
+			log.Printf("user %s financing lookup failed: %v", uid, err)
+		}
+		return finance.Defaults
+	}
+	return saved
+}
+
+// parseFinancing overlays the financing query params on base: down_payment
+// (cash) or down_payment_pct, rate, term_years, tax_rate, insurance_rate and
+// pmi_rate, with rates in percent.
+func parseFinancing(q url.Values, base types.Financing) (types.Financing, error) {
+	f := base
+	floats := []struct {
+		key string
+		dst *float64
+	}{
+		{"down_payment_pct", &f.DownPaymentPct},
+		{"rate", &f.RatePct},
+		{"tax_rate", &f.TaxRatePct},
+		{"insurance_rate", &f.InsuranceRatePct},
+		{"pmi_rate", &f.PMIRatePct},
+	}
+	for _, p := range floats {
+		if raw := q.Get(p.key); raw != "" {
+			v, err := strconv.ParseFloat(raw, 64)
+			if err != nil {
+				return f, fmt.Errorf("%s must be a number", p.key)
+			}
+			*p.dst = v
+		}
+	}
+	if q.Get("down_payment_pct") != "" {
+		// A percentage asked for now beats a saved cash amount.
+		f.DownPayment = 0
+	}
+	ints := []struct {
+		key string
+		dst *int
+	}{
+		{"down_payment", &f.DownPayment},
+		{"term_years", &f.TermYears},
+	}
+	for _, p := range ints {
+		if raw := q.Get(p.key); raw != "" {
+			v, err := strconv.Atoi(raw)
+			if err != nil {
+				return f, fmt.Errorf("%s must be a whole number", p.key)
+			}
+			*p.dst = v
+		}
+	}
+	return f, finance.Validate(f)
+}
+
+// financingParams are the query params parseFinancing reads.
+var financingParams = []string{"down_payment_pct", "down_payment", "rate", "term_years", "tax_rate", "insurance_rate", "pmi_rate"}
+
+// hasFinancingParams reports whether q sets any financing assumption.
+func hasFinancingParams(q url.Values) bool {
+	for _, k := range financingParams {
+		if q.Get(k) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// withMonthlyCost sets each listing's MonthlyCost under f.
+func withMonthlyCost(listings []types.Listing, f types.Financing) {
+	m := finance.NewModel(f)
+	for i := range listings {
+		cost := m.Cost(listings[i])
+		listings[i].MonthlyCost = &cost
+	}
+}
+
+// financingResponse is a user's effective financing assumptions.
+type financingResponse struct {
+	Financing types.Financing `json:"financing"`
+	// Saved reports whether they come from the user's settings rather than
+	// the defaults.
+	Saved bool `json:"saved"`
+}
+
+// getFinancingHandler serves GET /me/financing.
+func (s *server) getFinancingHandler(w http.ResponseWriter, r *http.Request) {
+	uid := userID(r)
+	if uid == "" || s.users == nil {
+		writeJSON(w, http.StatusOK, financingResponse{Financing: finance.Defaults})
+		return
+	}
+	f, err := s.users.Financing(r.Context(), uid)
+	if errors.Is(err, store.ErrNotFound) {
+		writeJSON(w, http.StatusOK, financingResponse{Financing: finance.Defaults})
+		return
+	}
+	if err != nil {
+		log.Printf("user %s financing lookup failed: %v", uid, err)
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "lookup failed"})
+		return
+	}
+	writeJSON(w, http.StatusOK, financingResponse{Financing: f, Saved: true})
+}
+
+// putFinancingHandler serves PUT /me/financing. Fields the body leaves out
+// keep their current values.
+func (s *server) putFinancingHandler(w http.ResponseWriter, r *http.Request) {
+	uid := userID(r)
+	if uid == "" {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": userRequired})
+		return
+	}
+	if s.users == nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "user settings need a listing store"})
+		return
+	}
+	f, err := s.users.Financing(r.Context(), uid)
+	if errors.Is(err, store.ErrNotFound) {
+		f, err = finance.Defaults, nil
+	}
+	if err != nil {
+		log.Printf("user %s financing lookup failed: %v", uid, err)
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "save failed"})
+		return
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<16)).Decode(&f); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON body: " + err.Error()})
+		return
+	}
+	if err := finance.Validate(f); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	if err := s.users.SetFinancing(r.Context(), uid, f); err != nil {
+		log.Printf("user %s financing save failed: %v", uid, err)
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "save failed"})
+		return
+	}
+	writeJSON(w, http.StatusOK, financingResponse{Financing: f, Saved: true})
+}
@@ -0,0 +1,88 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// userHeader carries the caller's user ID. The API does no authentication
+// of its own: the header is only believed on connections from one of
+// Config.TrustedProxies, an authenticating proxy that sets it after logging
+// the user in and strips it from what clients send.
+const userHeader = "X-User-ID"
+
+// userRequired is the 401 message of endpoints that need a user.
+const userRequired = userHeader + " header from a trusted proxy required"
+
+type userKey struct{}
+
+// identify resolves the caller's user ID before anything else reads the
+// request. It must run ahead of middleware.RealIP, which rewrites
+// RemoteAddr from headers any client can send.
+func (s *server) identify(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := strings.TrimSpace(r.Header.Get(userHeader))
+		if id != "" && len(id) <= 128 && s.trustedPeer(r.RemoteAddr) {
+			r = r.WithContext(context.WithValue(r.Context(), userKey{}, id))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// trustedPeer reports whether addr, a RemoteAddr, is one of the trusted
+// proxies.
+func (s *server) trustedPeer(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return false
+	}
+	ip = ip.Unmap()
+	for _, p := range s.trustedProxies {
+		if p.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// userID is the caller's user ID, or "" for anonymous requests and for
+// requests that did not come through a trusted proxy.
+func userID(r *http.Request) string {
+	id, _ := r.Context().Value(userKey{}).(string)
+	return id
+}
+
+// ParseTrustedProxies reads a comma-separated list of IP addresses and CIDR
+// prefixes, the format of TRUSTED_PROXIES.
+func ParseTrustedProxies(raw string) ([]netip.Prefix, error) {
+	var out []netip.Prefix
+	for _, part := range strings.Split(raw, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		if strings.Contains(part, "/") {
+			p, err := netip.ParsePrefix(part)
+			if err != nil {
+				return nil, fmt.Errorf("trusted proxy %q: %w", part, err)
+			}
+			out = append(out, p.Masked())
+			continue
+		}
+		ip, err := netip.ParseAddr(part)
+		if err != nil {
+			return nil, fmt.Errorf("trusted proxy %q: %w", part, err)
+		}
+		ip = ip.Unmap()
+		out = append(out, netip.PrefixFrom(ip, ip.BitLen()))
+	}
+	return out, nil
+}
@@ -0,0 +1,249 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"home-finder/internal/amenity"
+	"home-finder/internal/dedup"
+	"home-finder/internal/finance"
+	"home-finder/internal/history"
+	"home-finder/internal/provider"
+	"home-finder/internal/store"
+	"home-finder/internal/types"
+)
+
+// errUpstream means no provider had the listing and at least one failed, so
+// it may exist after all.
+var errUpstream = errors.New("upstream lookup failed")
+
+// listingResponse is a listing plus how this copy was obtained. It decodes
+// as a plain types.Listing, which is what provider.ResultsClient expects
+// from an upstream of this shape.
+type listingResponse struct {
+	types.Listing
+	// Stale is set when the stored copy is past the refresh window and the
+	// providers could not refresh it.
+	Stale bool `json:"stale,omitempty"`
+	// Sources reports the providers asked for a fresh copy, if any.
+	Sources []provider.SourceStatus `json:"sources,omitempty"`
+	// History is the listing's price and status events, oldest first, when
+	// the store has recorded any.
+	History []types.HistoryEvent `json:"history,omitempty"`
+}
+
+// listingHandler serves GET /listings/{id}. The ETag covers the listing
+// itself and its history, not the provider statuses, so an unchanged home
+// revalidates even after a refresh.
+func (s *server) listingHandler(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	financing, err := s.financing(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	resp, err := s.lookupListing(r.Context(), id)
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "listing not found"})
+		return
+	case errors.Is(err, errUpstream):
+		writeJSON(w, http.StatusBadGateway, map[string]any{"error": err.Error(), "sources": resp.Sources})
+		return
+	case err != nil:
+		log.Printf("listing %s lookup failed: %v", id, err)
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "lookup failed"})
+		return
+	}
+	if s.listings != nil {
+		resp.History, err = s.listings.History(r.Context(), resp.ID)
+		if err != nil && !errors.Is(err, store.ErrNotFound) {
+			log.Printf("listing %s history failed: %v", id, err)
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "lookup failed"})
+			return
+		}
+	}
+	amenity.Reconcile(&resp.Listing)
+	history.SetDaysOnMarket(&resp.Listing, time.Now())
+	history.Summarize(&resp.Listing, resp.History)
+	cost := finance.NewModel(financing).Cost(resp.Listing)
+	resp.MonthlyCost = &cost
+
+	entity, err := json.Marshal(struct {
+		types.Listing
+		History []types.HistoryEvent
+	}{resp.Listing, resp.History})
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "lookup failed"})
+		return
+	}
+	body, err := json.Marshal(resp)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "lookup failed"})
+		return
+	}
+	sum := sha256.Sum256(entity)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:8])+`"`)
+	http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(body))
+}
+
+// lookupListing finds a listing in the store, refreshing it from the
+// providers once it is older than staleAfter. Without a store it asks the
+// providers and then the demo data.
+func (s *server) lookupListing(ctx context.Context, id string) (listingResponse, error) {
+	if s.listings == nil {
+		var resp listingResponse
+		notFound := store.ErrNotFound
+		if s.providers != nil && s.providers.Len() > 0 {
+			l, sources, err := s.providers.Lookup(ctx, "", id)
+			resp.Sources = sources
+			if err == nil {
+				resp.Listing = l
+				return resp, nil
+			}
+			if !errors.Is(err, provider.ErrNotFound) {
+				log.Printf("listing %s upstream lookup failed: %v", id, err)
+				notFound = errUpstream
+			}
+		}
+		for _, l := range sampleListings {
+			if l.ID == id {
+				resp.Listing = l
+				return resp, nil
+			}
+		}
+		return resp, notFound
+	}
+
+	l, err := s.listings.Get(ctx, id)
+	if err != nil {
+		return listingResponse{}, err
+	}
+	resp := listingResponse{Listing: l}
+	if !s.stale(l) || s.providers == nil || s.providers.Len() == 0 {
+		return resp, nil
+	}
+	fresh, sources, err := s.providers.Lookup(ctx, l.Source, id)
+	resp.Sources = sources
+	if err != nil {
+		if !errors.Is(err, provider.ErrNotFound) {
+			log.Printf("listing %s refresh failed: %v", id, err)
+		}
+		resp.Stale = true
+		return resp, nil
+	}
+	// The upstream copy wins every field it reports; what only the store
+	// knows, such as vision analyses and cached photo hashes, is kept.
+	stored := l
+	stored.LastSeen = nil
+	merged := dedup.Refresh(fresh, stored)
+	cached := make(map[string]types.Photo, len(l.Photos))
+	for _, p := range l.Photos {
+		cached[p.URL] = p
+	}
+	for i, p := range merged.Photos {
+		if old, ok := cached[p.URL]; ok && p.Hash == "" {
+			merged.Photos[i].Hash, merged.Photos[i].DHash, merged.Photos[i].Stock = old.Hash, old.DHash, old.Stock
+		}
+	}
+	if err := s.listings.Upsert(ctx, merged); err != nil {
+		log.Printf("listing %s refresh: store: %v", id, err)
+	}
+	now := time.Now().UTC()
+	merged.LastSeen = &now
+	resp.Listing = merged
+	return resp, nil
+}
+
+// stale reports whether a stored listing should be refreshed upstream.
+func (s *server) stale(l types.Listing) bool {
+	return s.staleAfter > 0 && l.LastSeen != nil && time.Since(*l.LastSeen) > s.staleAfter
+}
+
+// historyResponse summarizes a listing's price and status history.
+type historyResponse struct {
+	ID              string     `json:"id"`
+	Status          string     `json:"status"`
+	Price           int        `json:"price"`
+	OriginalPrice   int        `json:"originalPrice,omitempty"`
+	PriceChangePct  float64    `json:"priceChangePct,omitempty"`
+	LastPriceDropAt *time.Time `json:"lastPriceDropAt,omitempty"`
+	DaysOnMarket    int        `json:"daysOnMarket"`
+	// Expired is set when the listing has dropped out of the store; its
+	// history is kept in case it comes back.
+	Expired bool                 `json:"expired,omitempty"`
+	Events  []types.HistoryEvent `json:"events"`
+}
+
+// historyHandler serves GET /listings/{id}/history. Events are only
+// recorded by the store; without one the summary comes from the listing
+// and the event list is empty.
+func (s *server) historyHandler(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if s.listings == nil {
+		resp, err := s.lookupListing(r.Context(), id)
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "listing not found"})
+		case err != nil:
+			writeJSON(w, http.StatusBadGateway, map[string]any{"error": err.Error(), "sources": resp.Sources})
+		default:
+			l := resp.Listing
+			history.SetDaysOnMarket(&l, time.Now())
+			history.Summarize(&l, nil)
+			writeJSON(w, http.StatusOK, summarizeHistory(l, []types.HistoryEvent{}))
+		}
+		return
+	}
+
+	events, err := s.listings.History(r.Context(), id)
+	if errors.Is(err, store.ErrNotFound) {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "listing not found"})
+		return
+	}
+	if err != nil {
+		log.Printf("listing %s history failed: %v", id, err)
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "history lookup failed"})
+		return
+	}
+	l, err := s.listings.Get(r.Context(), id)
+	expired := errors.Is(err, store.ErrNotFound)
+	if err != nil && !expired {
+		log.Printf("listing %s history failed: %v", id, err)
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "history lookup failed"})
+		return
+	}
+	if expired {
+		// Only the history is left to describe the listing as it was last seen.
+		last := events[len(events)-1]
+		l = types.Listing{ID: id, Price: last.Price, Status: last.Status}
+		history.Summarize(&l, events)
+	}
+	resp := summarizeHistory(l, events)
+	resp.Expired = expired
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func summarizeHistory(l types.Listing, events []types.HistoryEvent) historyResponse {
+	return historyResponse{
+		ID:              l.ID,
+		Status:          types.NormalizeStatus(l.Status),
+		Price:           l.Price,
+		OriginalPrice:   l.OriginalPrice,
+		PriceChangePct:  l.PriceChangePct,
+		LastPriceDropAt: l.LastPriceDropAt,
+		DaysOnMarket:    l.DaysOnMarket,
+		Events:          events,
+	}
+}
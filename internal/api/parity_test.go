@@ -0,0 +1,234 @@
+package api
+
+import (
+	"context"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"home-finder/internal/paging"
+	"home-finder/internal/store"
+	"home-finder/internal/types"
+)
+
+// parityListings covers every filter at least once from both sides.
+func parityListings(now time.Time) []types.Listing {
+	days := func(n int) *time.Time {
+		t := now.AddDate(0, 0, -n)
+		return &t
+	}
+	return []types.Listing{
+		{
+			ID: "p-loft", Title: "Pearl loft", Description: "Corner loft with a gas fireplace and skyline views.",
+			Price: 489000, Address: "123 Mint Ave", City: "Portland", State: "OR", Zip: "97204",
+			Latitude: 45.5191, Longitude: -122.6770, Beds: 2, Baths: 2, Sqft: 1200, YearBuilt: 2016, Stories: 1,
+			GarageSpaces: 1, HasView: true, HasFireplace: true, HOAFee: 320, PropertyType: "Condo",
+			Tags: []string{"city view", "hardwood"}, VisionTags: []string{"loft"}, ListDate: days(10),
+			Vision: &types.VisionAnalysis{Scores: map[string]float64{"loft": 0.8, "fireplace": 0.4}},
+		},
+		{
+			ID: "p-craftsman", Title: "Charcoal craftsman", Description: "Classic craftsman with a wood-burning fireplace, a finished basement and RV parking.",
+			Price: 729000, Address: "456 Grove St", City: "Seattle", State: "WA", Zip: "98101",
+			Latitude: 47.6101, Longitude: -122.3344, Beds: 3, Baths: 2.5, Sqft: 1850, LotSqft: 4000, YearBuilt: 1928, Stories: 2,
+			GarageSpaces: 2, HasRVParking: true, HasBasement: true, HasFireplace: true, HasADU: true, PropertyType: "Single Family",
+			Tags: []string{"garden", "basement"}, VisionTags: []string{"fireplace"}, ListDate: days(40),
+			Vision: &types.VisionAnalysis{Scores: map[string]float64{"fireplace": 0.9}},
+		},
+		{
+			ID: "p-pool", Title: "Desert pool house", Description: "Single level with a pool and a three car garage.",
+			Price: 615000, Address: "9 Saguaro Ln", City: "Phoenix", State: "AZ", Zip: "85001",
+			Latitude: 33.4484, Longitude: -112.0740, Beds: 4, Baths: 3, Sqft: 2400, LotSqft: 9000, YearBuilt: 2004, Stories: 1,
+			GarageSpaces: 3, HasPool: true, PropertyType: "single family",
+			Tags: []string{"pool", "garage"}, ListDate: days(3),
+		},
+		{
+			ID: "p-water", Title: "Lakefront cabin", Description: "Waterfront cabin with a dock; a fixer with a wood stove.",
+			Price: 350000, Address: "1 Shore Rd", City: "Lake Oswego", State: "OR", Zip: "97034",
+			Latitude: 45.4207, Longitude: -122.6706, Beds: 2, Baths: 1, Sqft: 900, LotSqft: 12000, YearBuilt: 1955, Stories: 1,
+			HasWaterfront: true, IsFixer: true, PropertyType: "Cabin",
+			Tags: []string{"dock", "waterfront"}, VisionTags: []string{"pool"}, ListDate: days(90),
+		},
+		{
+			ID: "p-new", Title: "New build townhome", Description: "Brand new townhome, open layout, rooftop deck.",
+			Price: 560000, Address: "77 Alder St", City: "Portland", State: "or", Zip: "97209",
+			Latitude: 45.5290, Longitude: -122.6840, Beds: 3, Baths: 2.5, Sqft: 1600, YearBuilt: 2024, Stories: 3,
+			GarageSpaces: 1, IsNewBuild: true, HOAFee: 150, PropertyType: "Townhouse",
+			Tags: []string{"open layout", "deck"},
+		},
+		{
+			ID: "p-nowhere", Title: "Rural lot", Description: "Acreage without coordinates.",
+			Price: 120000, Address: "RR 2", City: "Portlandia", State: "OR", Zip: "97999",
+			Beds: 0, Sqft: 0, LotSqft: 200000, PropertyType: "Land",
+			Tags: []string{"acreage"},
+		},
+		{
+			ID: "p-pending", Title: "Pending bungalow", Description: "Bungalow with a fireplace under contract.",
+			Price: 499000, Address: "5 Elm St", City: "Portland", State: "OR", Zip: "97211",
+			Latitude: 45.5600, Longitude: -122.6500, Beds: 2, Baths: 1, Sqft: 1100, YearBuilt: 1920,
+			HasFireplace: true, PropertyType: "Single Family", Status: types.StatusPending,
+			Tags: []string{"porch"}, ListDate: days(20), PendingDate: days(2),
+		},
+		{
+			ID: "p-sold-recent", Title: "Sold foursquare", Description: "Foursquare with basement.",
+			Price: 650000, Address: "8 Oak St", City: "Portland", State: "OR", Zip: "97212",
+			Latitude: 45.5400, Longitude: -122.6400, Beds: 4, Baths: 2, Sqft: 2200, YearBuilt: 1912,
+			HasBasement: true, PropertyType: "Single Family", Status: types.StatusSold,
+			Tags: []string{"basement"}, ListDate: days(60), SoldDate: days(5), SoldPrice: 640000,
+		},
+		{
+			ID: "p-sold-old", Title: "Sold ranch", Description: "Ranch sold last year.",
+			Price: 450000, Address: "3 Pine St", City: "Salem", State: "OR", Zip: "97301",
+			Latitude: 44.9429, Longitude: -123.0351, Beds: 3, Baths: 2, Sqft: 1500, YearBuilt: 1970,
+			PropertyType: "Single Family", Status: types.StatusSold,
+			ListDate: days(400), SoldDate: days(300), SoldPrice: 440000,
+		},
+	}
+}
+
+// newParityStore stores listings in a fresh SQLite database, cutting the
+// price of p-craftsman after a first upsert so it counts as reduced.
+func newParityStore(t *testing.T, listings []types.Listing) *store.SQLStore {
+	t.Helper()
+	ctx := context.Background()
+	st, err := store.Open(ctx, "sqlite://"+filepath.Join(t.TempDir(), "parity.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { st.Close() })
+	if err := st.Migrate(ctx); err != nil {
+		t.Fatal(err)
+	}
+	first := slices.Clone(listings)
+	for i := range first {
+		if first[i].ID == "p-craftsman" {
+			first[i].Price = 779000
+		}
+	}
+	if err := st.Upsert(ctx, first...); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.Upsert(ctx, listings...); err != nil {
+		t.Fatal(err)
+	}
+	return st
+}
+
+// storeIDs pages through a store search.
+func storeIDs(t *testing.T, st store.ListingRepository, f types.SearchFilters, sort string) ([]string, int) {
+	t.Helper()
+	var ids []string
+	total := -1
+	req := types.PageRequest{Sort: sort, Limit: 3}
+	for {
+		page, err := st.Search(context.Background(), f, req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if total < 0 {
+			total = page.Total
+		}
+		for _, l := range page.Results {
+			ids = append(ids, l.ID)
+		}
+		if page.NextCursor == "" {
+			return ids, total
+		}
+		req.Cursor = page.NextCursor
+	}
+}
+
+// memoryIDs pages through the in-memory search the way searchHandler does.
+func memoryIDs(t *testing.T, source []types.Listing, f types.SearchFilters, sort string) ([]string, int) {
+	t.Helper()
+	var ids []string
+	total := -1
+	req := types.PageRequest{Sort: sort, Limit: 3}
+	for {
+		page, err := paging.Paginate(filterListings(f, slices.Clone(source)), req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if total < 0 {
+			total = page.Total
+		}
+		for _, l := range page.Results {
+			ids = append(ids, l.ID)
+		}
+		if page.NextCursor == "" {
+			return ids, total
+		}
+		req.Cursor = page.NextCursor
+	}
+}
+
+func TestSearchParity(t *testing.T) {
+	st := newParityStore(t, parityListings(time.Now()))
+	// The in-memory side sees exactly what the store holds.
+	stored, err := st.Search(context.Background(), types.SearchFilters{Statuses: types.Statuses}, types.PageRequest{Sort: "price"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stored.Results) != len(parityListings(time.Now())) {
+		t.Fatalf("stored %d listings", len(stored.Results))
+	}
+	source := stored.Results
+
+	portland := &types.GeoPoint{Lat: 45.5152, Lng: -122.6784}
+	cases := []struct {
+		name    string
+		filters types.SearchFilters
+		sort    string
+	}{
+		{"default", types.SearchFilters{}, ""},
+		{"price range", types.SearchFilters{MinPrice: 400000, MaxPrice: 650000}, "price"},
+		{"beds and baths", types.SearchFilters{MinBeds: 3, MaxBeds: 3, MinBaths: 2.5}, "-price"},
+		{"sizes", types.SearchFilters{MinSqft: 1000, MaxSqft: 2000, MinLotSqft: 1, MaxYearBuilt: 2020}, "sqft"},
+		{"year and stories", types.SearchFilters{MinYearBuilt: 2000, MinStories: 1, MinGarage: 1}, "year_built"},
+		{"hoa", types.SearchFilters{MinHOA: 100, MaxHOA: 300}, "price_per_sqft"},
+		{"property types", types.SearchFilters{PropertyTypes: []string{"single family", "CONDO"}}, "-sqft"},
+		{"tags", types.SearchFilters{Tags: []string{"Pool"}}, "price"},
+		{"exclude tags", types.SearchFilters{ExcludeTags: []string{"pool", "deck"}}, "price"},
+		{"vision tags", types.SearchFilters{Tags: []string{"pool"}, UseVision: true}, "price"},
+		{"vision below the cutoff", types.SearchFilters{ExcludeTags: []string{"fireplace"}, UseVision: true}, "price"},
+		{"vision threshold under the cutoff", types.SearchFilters{Tags: []string{"fireplace"}, UseVision: true, MinVisionConfidence: 0.3}, "price"},
+		{"vision threshold over the score", types.SearchFilters{ExcludeTags: []string{"fireplace", "loft"}, UseVision: true, MinVisionConfidence: 0.5}, "price"},
+		{"verified ignores scores below the cutoff", types.SearchFilters{Verified: []string{"fireplace"}}, "price"},
+		{"city", types.SearchFilters{City: "portland"}, "price"},
+		{"state and zip", types.SearchFilters{State: "OR", Zip: "972"}, "price"},
+		{"flags", types.SearchFilters{RequireFireplace: true, RequireBasement: true}, "price"},
+		{"fixer on the water", types.SearchFilters{RequireWater: true, RequireFixer: true}, "price"},
+		{"new build", types.SearchFilters{RequireNew: true}, "price"},
+		{"bbox", types.SearchFilters{BBox: &types.BBox{MinLng: -122.8, MinLat: 45.4, MaxLng: -122.6, MaxLat: 45.6}}, "price"},
+		{"near radius", types.SearchFilters{Near: portland, RadiusMi: 10}, "distance"},
+		{"near without radius", types.SearchFilters{Near: portland}, "-distance"},
+		{"polygon", types.SearchFilters{Polygons: []types.Polygon{{{
+			{Lat: 45.50, Lng: -122.70}, {Lat: 45.50, Lng: -122.66}, {Lat: 45.54, Lng: -122.66}, {Lat: 45.54, Lng: -122.70}, {Lat: 45.50, Lng: -122.70},
+		}}}}, "price"},
+		{"text", types.SearchFilters{Query: "fireplace"}, ""},
+		{"text terms", types.SearchFilters{Query: "fireplace basement"}, "relevance"},
+		{"text phrase", types.SearchFilters{Query: `"wood-burning fireplace"`}, ""},
+		{"all statuses", types.SearchFilters{Statuses: types.Statuses}, "price"},
+		{"pending", types.SearchFilters{Statuses: []string{types.StatusPending}}, "price"},
+		{"sold within", types.SearchFilters{SoldWithinDays: 30}, "price"},
+		{"active or sold within", types.SearchFilters{Statuses: []string{types.StatusActive, types.StatusSold}, SoldWithinDays: 30}, "-price"},
+		{"newest", types.SearchFilters{Statuses: types.Statuses}, "newest"},
+		{"days on market", types.SearchFilters{MaxDaysOnMarket: 30}, "price"},
+		{"price reduced", types.SearchFilters{PriceReduced: true}, "price"},
+		{"max monthly", types.SearchFilters{MaxMonthly: 4000}, "monthly_cost"},
+		{"filter expression", types.SearchFilters{Filter: `price < 700000 AND NOT pool AND (city = "portland" OR beds >= 2.5)`}, "price"},
+		{"filter fractional", types.SearchFilters{Filter: "baths > 2.25 OR tag = dock"}, "-price"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			wantIDs, wantTotal := memoryIDs(t, source, c.filters, c.sort)
+			gotIDs, gotTotal := storeIDs(t, st, c.filters, c.sort)
+			if !slices.Equal(gotIDs, wantIDs) || gotTotal != wantTotal {
+				t.Errorf("store = %v (total %d), in-memory = %v (total %d)", gotIDs, gotTotal, wantIDs, wantTotal)
+			}
+			if len(wantIDs) == 0 {
+				t.Errorf("case matches nothing")
+			}
+		})
+	}
+}
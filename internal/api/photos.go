@@ -0,0 +1,63 @@
+package api
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+
+	"home-finder/internal/photo"
+)
+
+// photoHandler serves a cached photo by content hash, resized with ?w= to
+// the nearest photo.Widths entry. Content never changes for a hash, so
+// responses are cacheable forever. Photos come from third parties but are
+// served from the API's origin, so browsers are told not to sniff them or
+// run anything they contain.
+func (s *server) photoHandler(w http.ResponseWriter, r *http.Request) {
+	if s.photos == nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "photo cache not configured"})
+		return
+	}
+	hash := chi.URLParam(r, "hash")
+	width := 0
+	if raw := r.URL.Query().Get("w"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "w must be a positive integer"})
+			return
+		}
+		width = photo.SnapWidth(n)
+	}
+	path, contentType, err := s.photos.Thumbnail(hash, width)
+	if errors.Is(err, photo.ErrNotFound) {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "photo not found"})
+		return
+	}
+	if err != nil {
+		log.Printf("photo %s w=%d: %v", hash, width, err)
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "could not load photo"})
+		return
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		log.Printf("photo %s: %v", hash, err)
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "could not load photo"})
+		return
+	}
+	defer f.Close()
+	stat, err := f.Stat()
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "could not load photo"})
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; sandbox")
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	w.Header().Set("ETag", `"`+hash+"-"+strconv.Itoa(width)+`"`)
+	http.ServeContent(w, r, "", stat.ModTime(), f)
+}
@@ -1,19 +1,70 @@
 package api
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"log"
+	"math"
 	"net/http"
+	"net/netip"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
+
+	"home-finder/internal/facet"
+	"home-finder/internal/filterexpr"
+	"home-finder/internal/geo"
+	"home-finder/internal/paging"
+	"home-finder/internal/photo"
+	"home-finder/internal/provider"
+	"home-finder/internal/store"
+	"home-finder/internal/types"
 )
 
-func NewRouter() http.Handler {
+// Config carries the dependencies the HTTP handlers need.
+type Config struct {
+	// Listings is the persistent listing store; nil means serve demo/upstream data only.
+	Listings store.ListingRepository
+	// Providers are queried live when there is no store; nil or empty means demo data.
+	Providers *provider.Registry
+	// Photos serves cached listing photos at /photos/{hash}; nil disables it.
+	Photos *photo.Cache
+	// StaleAfter is how long a stored listing is served by /listings/{id}
+	// before it is refreshed from the providers; zero never refreshes.
+	StaleAfter time.Duration
+	// Users holds per-user settings such as financing defaults; nil means
+	// every caller gets the defaults.
+	Users store.UserSettings
+	// SavedSearches stores per-user saved searches; nil disables
+	// /saved-searches.
+	SavedSearches store.SavedSearchStore
+	// TrustedProxies are the peers whose X-User-ID header is believed. Empty,
+	// the default, makes every caller anonymous, which turns off per-user
+	// settings and saved searches.
+	TrustedProxies []netip.Prefix
+}
+
+type server struct {
+	listings       store.ListingRepository
+	providers      *provider.Registry
+	photos         *photo.Cache
+	staleAfter     time.Duration
+	users          store.UserSettings
+	savedSearches  store.SavedSearchStore
+	trustedProxies []netip.Prefix
+}
+
+func NewRouter(cfg Config) http.Handler {
+	s := &server{listings: cfg.Listings, providers: cfg.Providers, photos: cfg.Photos, staleAfter: cfg.StaleAfter, users: cfg.Users, savedSearches: cfg.SavedSearches, trustedProxies: cfg.TrustedProxies}
+
 	r := chi.NewRouter()
+	r.Use(s.identify)
 	r.Use(middleware.RequestID)
 	r.Use(middleware.RealIP)
 	r.Use(middleware.Logger)
@@ -22,7 +73,21 @@ func NewRouter() http.Handler {
 	r.Use(cors)
 
 	r.Get("/health", healthHandler)
-	r.Get("/search", searchHandler)
+	r.Get("/search", s.searchHandler)
+	r.Post("/search", s.searchHandler)
+	r.Get("/listings/{id}", s.listingHandler)
+	r.Get("/listings/{id}/history", s.historyHandler)
+	r.Get("/listings/{id}/comps", s.compsHandler)
+	r.Get("/stats", s.statsHandler)
+	r.Get("/me/financing", s.getFinancingHandler)
+	r.Put("/me/financing", s.putFinancingHandler)
+	r.Get("/saved-searches", s.listSavedSearchesHandler)
+	r.Post("/saved-searches", s.createSavedSearchHandler)
+	r.Get("/saved-searches/{id}", s.getSavedSearchHandler)
+	r.Put("/saved-searches/{id}", s.updateSavedSearchHandler)
+	r.Delete("/saved-searches/{id}", s.deleteSavedSearchHandler)
+	r.Get("/saved-searches/{id}/results", s.savedSearchResultsHandler)
+	r.Get("/photos/{hash}", s.photoHandler)
 
 	return r
 }
@@ -30,8 +95,9 @@ func NewRouter() http.Handler {
 func cors(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, If-None-Match, X-User-ID")
+		w.Header().Set("Access-Control-Expose-Headers", "ETag")
 		if r.Method == http.MethodOptions {
 			w.WriteHeader(http.StatusNoContent)
 			return
@@ -44,23 +110,113 @@ func healthHandler(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
 }
 
-func searchHandler(w http.ResponseWriter, r *http.Request) {
+func (s *server) searchHandler(w http.ResponseWriter, r *http.Request) {
 	filters := parseFilters(r)
+	if err := parseGeo(r, &filters); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	polygons, err := parsePolygons(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	filters.Polygons = polygons
+	financing, err := s.financing(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	filters.Financing = &financing
+	if _, err := filterexpr.Parse(filters.Filter); err != nil {
+		resp := map[string]any{"error": err.Error()}
+		var syntax *filterexpr.SyntaxError
+		if errors.As(err, &syntax) {
+			resp["position"] = syntax.Pos
+		}
+		writeJSON(w, http.StatusBadRequest, resp)
+		return
+	}
+	pageReq, err := parsePage(r)
+	if sort, _ := paging.ParseSort(pageReq.Sort); err == nil && sort.Key == "distance" && filters.Near == nil {
+		err = fmt.Errorf("sort=distance requires near=lat,lng")
+	}
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	facets, err := facet.Parse(r.URL.Query().Get("facets"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	if s.listings != nil {
+		page, err := s.listings.Search(r.Context(), filters, pageReq)
+		if err != nil {
+			log.Printf("listing store search failed: %v", err)
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "search failed"})
+			return
+		}
+		withMonthlyCost(page.Results, financing)
+		resp := map[string]any{
+			"results":     page.Results,
+			"total":       page.Total,
+			"next_cursor": page.NextCursor,
+		}
+		if len(facets) > 0 {
+			counts, err := s.listings.Facets(r.Context(), filters, facets)
+			if err != nil {
+				log.Printf("listing store facets failed: %v", err)
+				writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "search failed"})
+				return
+			}
+			resp["facets"] = counts
+		}
+		writeJSON(w, http.StatusOK, resp)
+		return
+	}
+
+	// Demo listings stand in only when no provider is configured; a provider
+	// that answers with nothing means no homes match.
 	source := sampleListings
-	if base, key, label := listingsConfigFromEnv(); base != "" {
-		if remote, err := fetchListingsFromAPI(r.Context(), base, key, filters); err != nil {
-			log.Printf("%s listings fetch failed, using demo data: %v", label, err)
-		} else if len(remote) > 0 {
-			source = remote
+	fallback := true
+	var sources []provider.SourceStatus
+	if s.providers != nil && s.providers.Len() > 0 {
+		source, sources = s.providers.Search(r.Context(), filters)
+		fallback = false
+		answered := false
+		for _, st := range sources {
+			if st.Status == "ok" {
+				answered = true
+				continue
+			}
+			log.Printf("%s listings fetch %s after %dms: %s", st.Name, st.Status, st.LatencyMs, st.Error)
+		}
+		if !answered {
+			writeJSON(w, http.StatusBadGateway, map[string]any{"error": "every listing provider failed", "sources": sources})
+			return
 		}
 	}
 
-	results := filterListings(filters, source)
+	page, err := paging.Paginate(filterListings(filters, source), pageReq)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
 
-	writeJSON(w, http.StatusOK, map[string]any{
-		"results": results,
-		"total":   len(results),
-	})
+	resp := map[string]any{
+		"results":     page.Results,
+		"total":       page.Total,
+		"next_cursor": page.NextCursor,
+		"sources":     sources,
+	}
+	if len(facets) > 0 {
+		resp["facets"] = computeFacets(filters, source, facets)
+	}
+	if fallback {
+		resp["fallback"] = "demo"
+	}
+	writeJSON(w, http.StatusOK, resp)
 }
 
 func writeJSON(w http.ResponseWriter, status int, v any) {
@@ -69,7 +225,7 @@ func writeJSON(w http.ResponseWriter, status int, v any) {
 	_ = json.NewEncoder(w).Encode(v)
 }
 
-func parseFilters(r *http.Request) SearchFilters {
+func parseFilters(r *http.Request) types.SearchFilters {
 	q := r.URL.Query()
 
 	toInt := func(key string) int {
@@ -103,41 +259,181 @@ func parseFilters(r *http.Request) SearchFilters {
 		return out
 	}
 
-	return SearchFilters{
-		MinPrice:         toInt("min_price"),
-		MaxPrice:         toInt("max_price"),
-		MinBeds:          toInt("min_beds"),
-		MaxBeds:          toInt("max_beds"),
-		MinBaths:         toFloat("min_baths"),
-		MaxBaths:         toFloat("max_baths"),
-		MinSqft:          toInt("min_sqft"),
-		MaxSqft:          toInt("max_sqft"),
-		MinLotSqft:       toInt("min_lot_sqft"),
-		MaxLotSqft:       toInt("max_lot_sqft"),
-		MinYearBuilt:     toInt("min_year_built"),
-		MaxYearBuilt:     toInt("max_year_built"),
-		MinStories:       toInt("min_stories"),
-		MinGarage:        toInt("min_garage"),
-		MinHOA:           toInt("min_hoa"),
-		MaxHOA:           toInt("max_hoa"),
-		PropertyTypes:    mergePropertyTypes(q.Get("property_type"), q.Get("property_types")),
-		Tags:             parseList(q.Get("tags")),
-		ExcludeTags:      parseList(q.Get("exclude_tags")),
-		City:             q.Get("city"),
-		State:            sanitizeAlpha(q.Get("state"), 2),
-		Zip:              sanitizeDigits(q.Get("zip"), 10),
-		Query:            q.Get("q"),
-		UseVision:        boolFromString(q.Get("use_vision")),
-		RequirePool:      boolFromString(q.Get("pool")),
-		RequireWater:     boolFromString(q.Get("waterfront")),
-		RequireView:      boolFromString(q.Get("view")),
-		RequireBasement:  boolFromString(q.Get("basement")),
-		RequireFireplace: boolFromString(q.Get("fireplace")),
-		RequireADU:       boolFromString(q.Get("adu")),
-		RequireRVParking: boolFromString(q.Get("rv_parking")),
-		RequireNew:       boolFromString(q.Get("new_build")),
-		RequireFixer:     boolFromString(q.Get("fixer")),
+	// Amenity flags take a truthy value, or "verified" to also require a
+	// photo that shows the amenity.
+	var verified []string
+	flag := func(key string) bool {
+		if strings.EqualFold(strings.TrimSpace(q.Get(key)), "verified") {
+			verified = append(verified, key)
+			return true
+		}
+		return boolFromString(q.Get(key))
+	}
+	// A vision confidence threshold only means something with vision tags on.
+	useVision := boolFromString(q.Get("use_vision"))
+	minVision := toFloat("min_vision_confidence")
+	if minVision > 0 {
+		useVision = true
+	} else {
+		minVision = 0
+	}
+
+	// Searches default to active listings; status=all widens them to every
+	// status and sold_within_days alone asks for recent sales (see
+	// SearchFilters.EffectiveStatuses).
+	statuses := parseList(q.Get("status"))
+	if len(statuses) == 1 && strings.EqualFold(statuses[0], "all") {
+		statuses = types.Statuses
+	}
+
+	filters := types.SearchFilters{
+		MinPrice:            toInt("min_price"),
+		MaxPrice:            toInt("max_price"),
+		MinBeds:             toInt("min_beds"),
+		MaxBeds:             toInt("max_beds"),
+		MinBaths:            toFloat("min_baths"),
+		MaxBaths:            toFloat("max_baths"),
+		MinSqft:             toInt("min_sqft"),
+		MaxSqft:             toInt("max_sqft"),
+		MinLotSqft:          toInt("min_lot_sqft"),
+		MaxLotSqft:          toInt("max_lot_sqft"),
+		MinYearBuilt:        toInt("min_year_built"),
+		MaxYearBuilt:        toInt("max_year_built"),
+		MinStories:          toInt("min_stories"),
+		MinGarage:           toInt("min_garage"),
+		MinHOA:              toInt("min_hoa"),
+		MaxHOA:              toInt("max_hoa"),
+		PropertyTypes:       mergePropertyTypes(q.Get("property_type"), q.Get("property_types")),
+		Tags:                parseList(q.Get("tags")),
+		ExcludeTags:         parseList(q.Get("exclude_tags")),
+		City:                q.Get("city"),
+		State:               sanitizeAlpha(q.Get("state"), 2),
+		Zip:                 sanitizeDigits(q.Get("zip"), 10),
+		Query:               q.Get("q"),
+		Filter:              q.Get("filter"),
+		UseVision:           useVision,
+		MinVisionConfidence: minVision,
+		RequirePool:         flag("pool"),
+		RequireWater:        flag("waterfront"),
+		RequireView:         flag("view"),
+		RequireBasement:     flag("basement"),
+		RequireFireplace:    flag("fireplace"),
+		RequireADU:          flag("adu"),
+		RequireRVParking:    flag("rv_parking"),
+		RequireNew:          boolFromString(q.Get("new_build")),
+		RequireFixer:        boolFromString(q.Get("fixer")),
+		PriceReduced:        boolFromString(q.Get("price_reduced")),
+		MaxDaysOnMarket:     toInt("max_days_on_market"),
+		MaxMonthly:          toInt("max_monthly"),
+		Statuses:            statuses,
+		SoldWithinDays:      max(toInt("sold_within_days"), 0),
+	}
+	// Set after the literal: flag() fills verified while its fields evaluate.
+	filters.Verified = verified
+	return filters
+}
+
+// maxPolygonBody bounds POST /search bodies; MaxPolygonVertices positions fit
+// comfortably.
+const maxPolygonBody = 1 << 20
+
+// parseGeo reads near, radius_mi and bbox into f. Like polygons, and unlike
+// other filters, bad values are rejected rather than ignored: dropping one
+// would quietly widen the search to everywhere.
+func parseGeo(r *http.Request, f *types.SearchFilters) error {
+	q := r.URL.Query()
+	if raw := q.Get("near"); raw != "" {
+		p, err := geo.ParsePoint(raw)
+		if err != nil {
+			return fmt.Errorf("near must be lat,lng: %w", err)
+		}
+		f.Near = &p
+	}
+	if raw := q.Get("radius_mi"); raw != "" {
+		v, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return fmt.Errorf("radius_mi must be a number")
+		}
+		f.RadiusMi = v
+	}
+	if raw := q.Get("bbox"); raw != "" {
+		b, err := geo.ParseBBox(raw)
+		if err != nil {
+			return fmt.Errorf("bbox must be minLng,minLat,maxLng,maxLat: %w", err)
+		}
+		f.BBox = &b
+	}
+	return validateGeo(*f)
+}
+
+// validateGeo checks the location filters of f, however they were given.
+func validateGeo(f types.SearchFilters) error {
+	if f.Near != nil {
+		if err := geo.ValidatePoint(*f.Near); err != nil {
+			return fmt.Errorf("near: %w", err)
+		}
+	}
+	if f.BBox != nil {
+		if err := geo.ValidateBBox(*f.BBox); err != nil {
+			return err
+		}
+	}
+	switch {
+	case math.IsNaN(f.RadiusMi) || math.IsInf(f.RadiusMi, 0) || f.RadiusMi < 0:
+		return fmt.Errorf("radius_mi must be a positive number of miles")
+	case f.RadiusMi > 0 && f.Near == nil:
+		return fmt.Errorf("radius_mi requires near=lat,lng")
+	}
+	return nil
+}
+
+// parsePolygons reads a GeoJSON Polygon/MultiPolygon from a POST body or the
+// polygon query param (the body wins). Shapes are validated, not ignored.
+func parsePolygons(r *http.Request) ([]types.Polygon, error) {
+	var raw []byte
+	if r.Method == http.MethodPost && r.Body != nil {
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxPolygonBody+1))
+		if err != nil {
+			return nil, fmt.Errorf("read body: %w", err)
+		}
+		if len(body) > maxPolygonBody {
+			return nil, fmt.Errorf("%w: body exceeds %d bytes", geo.ErrInvalidPolygon, maxPolygonBody)
+		}
+		raw = bytes.TrimSpace(body)
+	}
+	if len(raw) == 0 {
+		raw = []byte(r.URL.Query().Get("polygon"))
+	}
+	if len(raw) == 0 {
+		return nil, nil
+	}
+	return geo.ParseGeoJSON(raw)
+}
+
+// parsePage reads sort, limit and cursor. Unlike filters, bad values are
+// rejected rather than ignored so clients notice a broken cursor.
+func parsePage(r *http.Request) (types.PageRequest, error) {
+	q := r.URL.Query()
+	page := types.PageRequest{
+		Sort:   q.Get("sort"),
+		Limit:  paging.DefaultLimit,
+		Cursor: q.Get("cursor"),
+	}
+	if raw := q.Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 {
+			return page, fmt.Errorf("limit must be a positive integer")
+		}
+		page.Limit = min(n, paging.MaxLimit)
+	}
+	sort, err := paging.ParseSort(page.Sort)
+	if err != nil {
+		return page, err
+	}
+	if _, err := paging.DecodeCursor(page.Cursor, sort); err != nil {
+		return page, err
 	}
+	return page, nil
 }
 
 func mergePropertyTypes(single string, csv string) []string {
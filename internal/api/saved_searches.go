@@ -0,0 +1,306 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"home-finder/internal/filterexpr"
+	"home-finder/internal/finance"
+	"home-finder/internal/paging"
+	"home-finder/internal/store"
+	"home-finder/internal/types"
+)
+
+// savedSearchRequest is the body of POST and PUT /saved-searches. Filters
+// can be given as JSON or as a /search query string, which is what the UI
+// already builds; the query string's sort is used when Sort is empty.
+type savedSearchRequest struct {
+	Name    string               `json:"name"`
+	Filters *types.SearchFilters `json:"filters"`
+	Query   string               `json:"query"`
+	Sort    string               `json:"sort"`
+}
+
+// savedSearchUser returns the caller's user ID, or writes the error that
+// keeps them from using saved searches.
+func (s *server) savedSearchUser(w http.ResponseWriter, r *http.Request) (string, bool) {
+	uid := userID(r)
+	if uid == "" {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": userRequired})
+		return "", false
+	}
+	if s.savedSearches == nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "saved searches need a listing store"})
+		return "", false
+	}
+	return uid, true
+}
+
+// savedSearchID parses the {id} route param, writing a 404 when it is not
+// one.
+func savedSearchID(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "saved search not found"})
+		return 0, false
+	}
+	return id, true
+}
+
+// decodeSavedSearch reads and validates a savedSearchRequest.
+func (s *server) decodeSavedSearch(w http.ResponseWriter, r *http.Request, uid string) (store.SavedSearch, error) {
+	var req savedSearchRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxPolygonBody)).Decode(&req); err != nil {
+		return store.SavedSearch{}, fmt.Errorf("invalid JSON body: %w", err)
+	}
+	ss := store.SavedSearch{UserID: uid, Name: strings.TrimSpace(req.Name), Sort: strings.TrimSpace(req.Sort)}
+	if ss.Name == "" || len(ss.Name) > 200 {
+		return ss, errors.New("name is required and must be at most 200 characters")
+	}
+	switch {
+	case req.Filters != nil && req.Query != "":
+		return ss, errors.New("give either filters or query, not both")
+	case req.Filters != nil:
+		ss.Filters = *req.Filters
+	case req.Query != "":
+		q, err := url.ParseQuery(strings.TrimPrefix(req.Query, "?"))
+		if err != nil {
+			return ss, fmt.Errorf("invalid query: %w", err)
+		}
+		qr := &http.Request{Method: http.MethodGet, URL: &url.URL{RawQuery: q.Encode()}}
+		ss.Filters = parseFilters(qr)
+		if err := parseGeo(qr, &ss.Filters); err != nil {
+			return ss, err
+		}
+		if ss.Filters.Polygons, err = parsePolygons(qr); err != nil {
+			return ss, err
+		}
+		if hasFinancingParams(q) {
+			fin, err := parseFinancing(q, s.userFinancing(r.Context(), uid))
+			if err != nil {
+				return ss, err
+			}
+			ss.Filters.Financing = &fin
+		}
+		if ss.Sort == "" {
+			ss.Sort = q.Get("sort")
+		}
+	}
+	if err := validateGeo(ss.Filters); err != nil {
+		return ss, err
+	}
+	expr, err := filterexpr.Parse(ss.Filters.Filter)
+	if err != nil {
+		return ss, err
+	}
+	ss.Filters.Filter = ""
+	if expr != nil {
+		ss.Filters.Filter = expr.String()
+	}
+	sortKey := ""
+	if ss.Sort != "" {
+		sort, err := paging.ParseSort(ss.Sort)
+		if err != nil {
+			return ss, err
+		}
+		ss.Sort, sortKey = sort.String(), sort.Key
+	}
+	// Financing only changes which listings match, or their order, through
+	// max_monthly and sort=monthly_cost; otherwise it would only keep
+	// equivalent searches apart.
+	if ss.Filters.MaxMonthly <= 0 && sortKey != "monthly_cost" {
+		ss.Filters.Financing = nil
+	}
+	if ss.Filters.Financing != nil {
+		if err := finance.Validate(*ss.Filters.Financing); err != nil {
+			return ss, err
+		}
+	}
+	return ss, nil
+}
+
+// writeSavedSearchError maps store errors from saved search calls.
+func writeSavedSearchError(w http.ResponseWriter, existing store.SavedSearch, err error) {
+	switch {
+	case errors.Is(err, store.ErrDuplicateSearch):
+		writeJSON(w, http.StatusConflict, map[string]any{"error": err.Error(), "id": existing.ID})
+	case errors.Is(err, store.ErrNotFound):
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "saved search not found"})
+	default:
+		log.Printf("saved search failed: %v", err)
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "saved search failed"})
+	}
+}
+
+// listSavedSearchesHandler serves GET /saved-searches.
+func (s *server) listSavedSearchesHandler(w http.ResponseWriter, r *http.Request) {
+	uid, ok := s.savedSearchUser(w, r)
+	if !ok {
+		return
+	}
+	list, err := s.savedSearches.SavedSearches(r.Context(), uid)
+	if err != nil {
+		writeSavedSearchError(w, store.SavedSearch{}, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"searches": list})
+}
+
+// createSavedSearchHandler serves POST /saved-searches. Saving filters
+// equivalent to an existing search answers 409 with that search's ID.
+func (s *server) createSavedSearchHandler(w http.ResponseWriter, r *http.Request) {
+	uid, ok := s.savedSearchUser(w, r)
+	if !ok {
+		return
+	}
+	ss, err := s.decodeSavedSearch(w, r, uid)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	created, err := s.savedSearches.CreateSavedSearch(r.Context(), ss)
+	if err != nil {
+		writeSavedSearchError(w, created, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, created)
+}
+
+// getSavedSearchHandler serves GET /saved-searches/{id}.
+func (s *server) getSavedSearchHandler(w http.ResponseWriter, r *http.Request) {
+	uid, ok := s.savedSearchUser(w, r)
+	if !ok {
+		return
+	}
+	id, ok := savedSearchID(w, r)
+	if !ok {
+		return
+	}
+	ss, err := s.savedSearches.SavedSearch(r.Context(), uid, id)
+	if err != nil {
+		writeSavedSearchError(w, ss, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, ss)
+}
+
+// updateSavedSearchHandler serves PUT /saved-searches/{id}, replacing the
+// name, filters and sort. Listings already shown stay shown.
+func (s *server) updateSavedSearchHandler(w http.ResponseWriter, r *http.Request) {
+	uid, ok := s.savedSearchUser(w, r)
+	if !ok {
+		return
+	}
+	id, ok := savedSearchID(w, r)
+	if !ok {
+		return
+	}
+	ss, err := s.decodeSavedSearch(w, r, uid)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	ss.ID = id
+	updated, err := s.savedSearches.UpdateSavedSearch(r.Context(), ss)
+	if err != nil {
+		writeSavedSearchError(w, updated, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, updated)
+}
+
+// deleteSavedSearchHandler serves DELETE /saved-searches/{id}.
+func (s *server) deleteSavedSearchHandler(w http.ResponseWriter, r *http.Request) {
+	uid, ok := s.savedSearchUser(w, r)
+	if !ok {
+		return
+	}
+	id, ok := savedSearchID(w, r)
+	if !ok {
+		return
+	}
+	if err := s.savedSearches.DeleteSavedSearch(r.Context(), uid, id); err != nil {
+		writeSavedSearchError(w, store.SavedSearch{}, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// savedSearchResultsHandler serves GET /saved-searches/{id}/results: the
+// search re-run against the store, paged like /search, with "new" listing
+// the IDs on this page it had never shown before. Viewing a page marks its
+// listings as shown. Financing query params override the saved ones.
+func (s *server) savedSearchResultsHandler(w http.ResponseWriter, r *http.Request) {
+	uid, ok := s.savedSearchUser(w, r)
+	if !ok {
+		return
+	}
+	id, ok := savedSearchID(w, r)
+	if !ok {
+		return
+	}
+	ss, err := s.savedSearches.SavedSearch(r.Context(), uid, id)
+	if err != nil {
+		writeSavedSearchError(w, ss, err)
+		return
+	}
+	pageReq, err := parsePage(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	if r.URL.Query().Get("sort") == "" {
+		pageReq.Sort = ss.Sort
+	}
+	filters := ss.Filters
+	base := s.userFinancing(r.Context(), uid)
+	if filters.Financing != nil {
+		base = *filters.Financing
+	}
+	financing, err := parseFinancing(r.URL.Query(), base)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	filters.Financing = &financing
+
+	page, err := s.listings.Search(r.Context(), filters, pageReq)
+	if errors.Is(err, paging.ErrInvalidCursor) {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		log.Printf("saved search %d failed: %v", id, err)
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "search failed"})
+		return
+	}
+	withMonthlyCost(page.Results, financing)
+	ids := make([]string, len(page.Results))
+	for i, l := range page.Results {
+		ids[i] = l.ID
+	}
+	unseen, err := s.savedSearches.MarkSavedSearchViewed(r.Context(), uid, id, ids, time.Now())
+	if err != nil {
+		writeSavedSearchError(w, ss, err)
+		return
+	}
+	if unseen == nil {
+		unseen = []string{}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"search":      ss,
+		"results":     page.Results,
+		"total":       page.Total,
+		"next_cursor": page.NextCursor,
+		"new":         unseen,
+	})
+}
@@ -1,64 +1,31 @@
 package api
 
 import (
-	"context"
-	"encoding/json"
-	"fmt"
-	"net/http"
-	"net/url"
-	"os"
 	"strings"
 	"time"
 
+	"home-finder/internal/amenity"
+	"home-finder/internal/filterexpr"
+	"home-finder/internal/finance"
+	"home-finder/internal/fulltext"
+	"home-finder/internal/geo"
+	"home-finder/internal/history"
 	"home-finder/internal/types"
 )
 
-type SearchFilters struct {
-	MinPrice         int
-	MaxPrice         int
-	MinBeds          int
-	MaxBeds          int
-	MinBaths         float64
-	MaxBaths         float64
-	MinSqft          int
-	MaxSqft          int
-	MinLotSqft       int
-	MaxLotSqft       int
-	MinYearBuilt     int
-	MaxYearBuilt     int
-	MinStories       int
-	MinGarage        int
-	MaxHOA           int
-	MinHOA           int
-	PropertyTypes    []string
-	Tags             []string
-	ExcludeTags      []string
-	City             string
-	State            string
-	Zip              string
-	Query            string
-	UseVision        bool
-	RequirePool      bool
-	RequireWater     bool
-	RequireView      bool
-	RequireBasement  bool
-	RequireFireplace bool
-	RequireADU       bool
-	RequireRVParking bool
-	RequireNew       bool
-	RequireFixer     bool
-}
-
 // In-memory demo data; swap out with provider/DB later.
 var sampleListings = []types.Listing{
 	{
 		ID:            "demo-001",
 		Title:         "Bright Modern Loft",
+		Description:   "Sun-filled corner loft with floor-to-ceiling windows, a gas fireplace and skyline views over the Pearl District.",
 		Price:         489000,
 		Address:       "123 Mint Ave",
 		City:          "Portland",
 		State:         "OR",
 		Zip:           "97204",
+		Latitude:      45.5191,
+		Longitude:     -122.6770,
 		Beds:          2,
 		Baths:         2,
 		Sqft:          1200,
@@ -85,11 +52,14 @@ var sampleListings = []types.Listing{
 	{
 		ID:            "demo-002",
 		Title:         "Calm Charcoal Craftsman",
+		Description:   "Classic craftsman with original built-ins, a wood-burning fireplace, a finished basement, a backyard ADU and RV parking off the alley.",
 		Price:         729000,
 		Address:       "456 Grove St",
 		City:          "Seattle",
 		State:         "WA",
 		Zip:           "98101",
+		Latitude:      47.6101,
+		Longitude:     -122.3344,
 		Beds:          3,
 		Baths:         2.5,
 		Sqft:          1850,
@@ -116,11 +86,14 @@ var sampleListings = []types.Listing{
 	{
 		ID:            "demo-003",
 		Title:         "Mint Courtyard Townhome",
+		Description:   "End-unit townhome wrapped around a private courtyard, with mountain views from the rooftop deck and an attached garage.",
 		Price:         615000,
 		Address:       "789 Courtyard Ln",
 		City:          "Denver",
 		State:         "CO",
 		Zip:           "80205",
+		Latitude:      39.7589,
+		Longitude:     -104.9669,
 		Beds:          3,
 		Baths:         2,
 		Sqft:          1500,
@@ -147,11 +120,14 @@ var sampleListings = []types.Listing{
 	{
 		ID:            "demo-004",
 		Title:         "Minimal Lakeview Flat",
+		Description:   "Quiet waterfront flat with lake views from every room, a building pool and a walk to the lakefront trail.",
 		Price:         540000,
 		Address:       "12 Shoreline Dr",
 		City:          "Chicago",
 		State:         "IL",
 		Zip:           "60601",
+		Latitude:      41.8864,
+		Longitude:     -87.6186,
 		Beds:          2,
 		Baths:         1.5,
 		Sqft:          1100,
@@ -178,11 +154,14 @@ var sampleListings = []types.Listing{
 	{
 		ID:            "demo-005",
 		Title:         "Soft Mint Bungalow",
+		Description:   "Fixer bungalow with good bones, a brick fireplace and RV parking behind the gate. Bring your ideas.",
 		Price:         455000,
 		Address:       "22 Fern St",
 		City:          "Austin",
 		State:         "TX",
 		Zip:           "78704",
+		Latitude:      30.2437,
+		Longitude:     -97.7650,
 		Beds:          2,
 		Baths:         1,
 		Sqft:          980,
@@ -208,9 +187,24 @@ var sampleListings = []types.Listing{
 	},
 }
 
-func filterListings(filters SearchFilters, listings []types.Listing) []types.Listing {
+func filterListings(filters types.SearchFilters, listings []types.Listing) []types.Listing {
+	// Relevance statistics come from the whole input, not just the rows that
+	// survive the other filters, matching the store.
+	var hits map[int]float64
+	query := fulltext.ParseQuery(filters.Query)
+	if !query.Empty() {
+		hits = fulltext.NewIndex(listings).Search(query)
+	}
+	// The handler rejects malformed expressions before we get here.
+	expr, _ := filterexpr.Parse(filters.Filter)
+	now := time.Now()
+	financing := finance.Defaults
+	if filters.Financing != nil {
+		financing = *filters.Financing
+	}
+	model := finance.NewModel(financing)
 	var out []types.Listing
-	for _, l := range listings {
+	for i, l := range listings {
 		if filters.MinPrice > 0 && l.Price < filters.MinPrice {
 			continue
 		}
@@ -262,10 +256,7 @@ func filterListings(filters SearchFilters, listings []types.Listing) []types.Lis
 		if len(filters.PropertyTypes) > 0 && !matchesAnyPropertyType(l.PropertyType, filters.PropertyTypes) {
 			continue
 		}
-		tagPool := l.Tags
-		if filters.UseVision && len(l.VisionTags) > 0 {
-			tagPool = append(tagPool, l.VisionTags...)
-		}
+		tagPool := l.TagPool(filters.UseVision, filters.MinVisionConfidence)
 		if len(filters.Tags) > 0 && !hasAllTags(tagPool, filters.Tags) {
 			continue
 		}
@@ -281,9 +272,27 @@ func filterListings(filters SearchFilters, listings []types.Listing) []types.Lis
 		if filters.Zip != "" && !strings.HasPrefix(l.Zip, filters.Zip) {
 			continue
 		}
-		if filters.Query != "" && !matchesQuery(l, filters.Query) {
+		if filters.BBox != nil && (!l.HasLocation() || !geo.Contains(*filters.BBox, geo.Point(l))) {
 			continue
 		}
+		if len(filters.Polygons) > 0 && (!l.HasLocation() || !geo.ContainsPolygons(filters.Polygons, geo.Point(l))) {
+			continue
+		}
+		if filters.Near != nil {
+			if l.HasLocation() {
+				l.DistanceMi = geo.DistanceMi(*filters.Near, geo.Point(l))
+			}
+			if filters.RadiusMi > 0 && (!l.HasLocation() || l.DistanceMi > filters.RadiusMi) {
+				continue
+			}
+		}
+		if !query.Empty() {
+			score, ok := hits[i]
+			if !ok {
+				continue
+			}
+			l.Score = score
+		}
 		if filters.RequirePool && !l.HasPool {
 			continue
 		}
@@ -311,27 +320,83 @@ func filterListings(filters SearchFilters, listings []types.Listing) []types.Lis
 		if filters.RequireFixer && !l.IsFixer {
 			continue
 		}
+		if !matchesStatus(l, filters, now) {
+			continue
+		}
+		history.SetDaysOnMarket(&l, now)
+		history.Summarize(&l, nil)
+		if filters.PriceReduced && !history.Reduced(l) {
+			continue
+		}
+		if filters.MaxDaysOnMarket > 0 && l.DaysOnMarket > filters.MaxDaysOnMarket {
+			continue
+		}
+		if filters.MaxMonthly > 0 && !model.Within(l, filters.MaxMonthly) {
+			continue
+		}
+		cost := model.Cost(l)
+		l.MonthlyCost = &cost
+		if expr != nil && !expr.Eval(l, tagPool) {
+			continue
+		}
+		if !verifiedAmenities(l, filters.Verified) {
+			continue
+		}
+		amenity.Reconcile(&l)
 		out = append(out, l)
 	}
 	return out
 }
 
+// matchesStatus reports whether l has one of the requested statuses, and for
+// sold listings whether it sold within SoldWithinDays. It mirrors the store's
+// statusCond.
+func matchesStatus(l types.Listing, filters types.SearchFilters, now time.Time) bool {
+	status := types.NormalizeStatus(l.Status)
+	for _, st := range filters.EffectiveStatuses() {
+		if st != status {
+			continue
+		}
+		if st == types.StatusSold && filters.SoldWithinDays > 0 {
+			return l.SoldDate != nil && l.SoldDate.Unix() > now.AddDate(0, 0, -filters.SoldWithinDays).Unix()
+		}
+		return true
+	}
+	return false
+}
+
 func hasAllTags(listingTags []string, required []string) bool {
 	tagSet := make(map[string]struct{}, len(listingTags))
 	for _, t := range listingTags {
-		tagSet[strings.ToLower(strings.TrimSpace(t))] = struct{}{}
+		tagSet[normalizeTag(t)] = struct{}{}
 	}
 	for _, t := range required {
 		if t == "" {
 			continue
 		}
-		if _, ok := tagSet[strings.ToLower(strings.TrimSpace(t))]; !ok {
+		if _, ok := tagSet[normalizeTag(t)]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// verifiedAmenities reports whether every named amenity flag is confirmed by
+// the listing's vision tags. Unknown names match nothing.
+func verifiedAmenities(l types.Listing, names []string) bool {
+	for _, name := range names {
+		f, ok := amenity.Lookup(name)
+		if !ok || f.Check(l) != amenity.Confirmed {
 			return false
 		}
 	}
 	return true
 }
 
+func normalizeTag(t string) string {
+	return strings.ToLower(strings.TrimSpace(t))
+}
+
 func boolFromString(v string) bool {
 	switch strings.ToLower(strings.TrimSpace(v)) {
 	case "1", "true", "yes", "on":
@@ -341,35 +406,13 @@ func boolFromString(v string) bool {
 	}
 }
 
-func matchesQuery(l types.Listing, q string) bool {
-	q = strings.ToLower(strings.TrimSpace(q))
-	if q == "" {
-		return true
-	}
-	fields := []string{
-		l.Title,
-		l.Address,
-		l.City,
-		l.State,
-		l.Zip,
-		l.PropertyType,
-		strings.Join(l.Tags, " "),
-	}
-	for _, f := range fields {
-		if strings.Contains(strings.ToLower(f), q) {
-			return true
-		}
-	}
-	return false
-}
-
 func hasAnyTag(listingTags []string, unwanted []string) bool {
 	tagSet := make(map[string]struct{}, len(listingTags))
 	for _, t := range listingTags {
-		tagSet[strings.ToLower(strings.TrimSpace(t))] = struct{}{}
+		tagSet[normalizeTag(t)] = struct{}{}
 	}
 	for _, t := range unwanted {
-		if _, ok := tagSet[strings.ToLower(strings.TrimSpace(t))]; ok {
+		if _, ok := tagSet[normalizeTag(t)]; ok {
 			return true
 		}
 	}
@@ -384,149 +427,3 @@ func matchesAnyPropertyType(pt string, allowed []string) bool {
 	}
 	return false
 }
-
-// fetchListingsFromAPI calls an external listing API (if configured) and maps results.
-// The external API is expected to return JSON shaped as {"results": [ ... listings ... ]}.
-func fetchListingsFromAPI(ctx context.Context, baseURL, apiKey string, filters SearchFilters) ([]types.Listing, error) {
-	client := &http.Client{Timeout: 8 * time.Second}
-	q := url.Values{}
-	if filters.MinPrice > 0 {
-		q.Set("min_price", fmt.Sprintf("%d", filters.MinPrice))
-	}
-	if filters.MaxPrice > 0 {
-		q.Set("max_price", fmt.Sprintf("%d", filters.MaxPrice))
-	}
-	if filters.MinBeds > 0 {
-		q.Set("min_beds", fmt.Sprintf("%d", filters.MinBeds))
-	}
-	if filters.MaxBeds > 0 {
-		q.Set("max_beds", fmt.Sprintf("%d", filters.MaxBeds))
-	}
-	if filters.MinBaths > 0 {
-		q.Set("min_baths", fmt.Sprintf("%g", filters.MinBaths))
-	}
-	if filters.MaxBaths > 0 {
-		q.Set("max_baths", fmt.Sprintf("%g", filters.MaxBaths))
-	}
-	if filters.MinSqft > 0 {
-		q.Set("min_sqft", fmt.Sprintf("%d", filters.MinSqft))
-	}
-	if filters.MaxSqft > 0 {
-		q.Set("max_sqft", fmt.Sprintf("%d", filters.MaxSqft))
-	}
-	if filters.MinLotSqft > 0 {
-		q.Set("min_lot_sqft", fmt.Sprintf("%d", filters.MinLotSqft))
-	}
-	if filters.MaxLotSqft > 0 {
-		q.Set("max_lot_sqft", fmt.Sprintf("%d", filters.MaxLotSqft))
-	}
-	if filters.MinYearBuilt > 0 {
-		q.Set("min_year_built", fmt.Sprintf("%d", filters.MinYearBuilt))
-	}
-	if filters.MaxYearBuilt > 0 {
-		q.Set("max_year_built", fmt.Sprintf("%d", filters.MaxYearBuilt))
-	}
-	if filters.MinStories > 0 {
-		q.Set("min_stories", fmt.Sprintf("%d", filters.MinStories))
-	}
-	if filters.MinGarage > 0 {
-		q.Set("min_garage", fmt.Sprintf("%d", filters.MinGarage))
-	}
-	if filters.MinHOA > 0 {
-		q.Set("min_hoa", fmt.Sprintf("%d", filters.MinHOA))
-	}
-	if filters.MaxHOA > 0 {
-		q.Set("max_hoa", fmt.Sprintf("%d", filters.MaxHOA))
-	}
-	if len(filters.PropertyTypes) > 0 {
-		q.Set("property_types", strings.Join(filters.PropertyTypes, ","))
-	}
-	if len(filters.Tags) > 0 {
-		q.Set("tags", strings.Join(filters.Tags, ","))
-	}
-	if len(filters.ExcludeTags) > 0 {
-		q.Set("exclude_tags", strings.Join(filters.ExcludeTags, ","))
-	}
-	if filters.City != "" {
-		q.Set("city", filters.City)
-	}
-	if filters.State != "" {
-		q.Set("state", filters.State)
-	}
-	if filters.Zip != "" {
-		q.Set("zip", filters.Zip)
-	}
-	if filters.Query != "" {
-		q.Set("q", filters.Query)
-	}
-	if filters.UseVision {
-		q.Set("use_vision", "1")
-	}
-	if filters.RequirePool {
-		q.Set("pool", "1")
-	}
-	if filters.RequireWater {
-		q.Set("waterfront", "1")
-	}
-	if filters.RequireView {
-		q.Set("view", "1")
-	}
-	if filters.RequireBasement {
-		q.Set("basement", "1")
-	}
-	if filters.RequireFireplace {
-		q.Set("fireplace", "1")
-	}
-	if filters.RequireADU {
-		q.Set("adu", "1")
-	}
-	if filters.RequireRVParking {
-		q.Set("rv_parking", "1")
-	}
-	if filters.RequireNew {
-		q.Set("new_build", "1")
-	}
-	if filters.RequireFixer {
-		q.Set("fixer", "1")
-	}
-
-	apiURL := fmt.Sprintf("%s/search", strings.TrimRight(baseURL, "/"))
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, apiURL, nil)
-	if err != nil {
-		return nil, err
-	}
-	req.URL.RawQuery = q.Encode()
-	if apiKey != "" {
-		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", apiKey))
-	}
-
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-	if resp.StatusCode >= 300 {
-		return nil, fmt.Errorf("upstream status %d", resp.StatusCode)
-	}
-	var payload struct {
-		Results []types.Listing `json:"results"`
-	}
-	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
-		return nil, err
-	}
-	return payload.Results, nil
-}
-
-// listingsConfigFromEnv selects the first available upstream:
-// 1) SCRAPER_LISTINGS_BASE (unofficial scrapers like Zillow/Redfin via a self-hosted proxy)
-// 2) LISTINGS_API_BASE (official/partner API)
-// Returns base, apiKey, and a label describing the source.
-func listingsConfigFromEnv() (string, string, string) {
-	if base := os.Getenv("SCRAPER_LISTINGS_BASE"); base != "" {
-		return base, os.Getenv("SCRAPER_LISTINGS_KEY"), "scraper"
-	}
-	if base := os.Getenv("LISTINGS_API_BASE"); base != "" {
-		return base, os.Getenv("LISTINGS_API_KEY"), "official"
-	}
-	return "", "", ""
-}
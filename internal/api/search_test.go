@@ -0,0 +1,70 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"home-finder/internal/provider"
+	"home-finder/internal/types"
+)
+
+// stubProvider answers every search with the same listings or error.
+type stubProvider struct {
+	name     string
+	listings []types.Listing
+	err      error
+}
+
+func (p stubProvider) Name() string                        { return p.name }
+func (p stubProvider) Capabilities() provider.Capabilities { return provider.Capabilities{} }
+func (p stubProvider) Search(context.Context, types.SearchFilters) ([]types.Listing, error) {
+	return p.listings, p.err
+}
+
+func TestSearchFallsBackOnlyWithoutProviders(t *testing.T) {
+	home := types.Listing{ID: "remote-1", Title: "Remote home", Price: 500000, Source: "a"}
+	cases := []struct {
+		name      string
+		providers []provider.Provider
+		code      int
+		results   int
+		fallback  bool
+	}{
+		{"no providers", nil, http.StatusOK, len(sampleListings), true},
+		{"matches", []provider.Provider{stubProvider{name: "a", listings: []types.Listing{home}}}, http.StatusOK, 1, false},
+		{"no matches", []provider.Provider{stubProvider{name: "a"}}, http.StatusOK, 0, false},
+		{"some fail", []provider.Provider{stubProvider{name: "a"}, stubProvider{name: "b", err: errors.New("blocked")}}, http.StatusOK, 0, false},
+		{"all fail", []provider.Provider{stubProvider{name: "a", err: errors.New("blocked")}, stubProvider{name: "b", err: errors.New("down")}}, http.StatusBadGateway, 0, false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			reg := provider.NewRegistry()
+			for _, p := range c.providers {
+				reg.Register(p, 0)
+			}
+			rec := httptest.NewRecorder()
+			NewRouter(Config{Providers: reg}).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/search", nil))
+			if rec.Code != c.code {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, c.code, rec.Body)
+			}
+			var body struct {
+				Results  []types.Listing         `json:"results"`
+				Fallback string                  `json:"fallback"`
+				Sources  []provider.SourceStatus `json:"sources"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			if len(body.Results) != c.results || (body.Fallback == "demo") != c.fallback {
+				t.Errorf("results = %d, fallback = %q; want %d, %v", len(body.Results), body.Fallback, c.results, c.fallback)
+			}
+			if len(body.Sources) != len(c.providers) {
+				t.Errorf("sources = %v, want one per provider", body.Sources)
+			}
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package api
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"home-finder/internal/market"
+	"home-finder/internal/types"
+)
+
+// maxStatsPeriods caps how far back /stats goes.
+const maxStatsPeriods = 104
+
+// statsHandler serves GET /stats. city, state and zip narrow the market the
+// way they narrow a search, property_type (or property_types) picks the
+// kinds of home, period is week or month and periods how many to return,
+// 12 by default. Stats are replayed from the store's history, so there are
+// none without a store.
+func (s *server) statsHandler(w http.ResponseWriter, r *http.Request) {
+	if s.listings == nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "market stats need a listing store"})
+		return
+	}
+	q := r.URL.Query()
+	period, err := market.ParsePeriod(q.Get("period"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	periods := 12
+	if raw := q.Get("periods"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > maxStatsPeriods {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "periods must be between 1 and " + strconv.Itoa(maxStatsPeriods)})
+			return
+		}
+		periods = n
+	}
+	parsed := parseFilters(r)
+	f := types.SearchFilters{City: parsed.City, State: parsed.State, Zip: parsed.Zip, PropertyTypes: parsed.PropertyTypes}
+
+	series, err := s.listings.MarketSeries(r.Context(), f)
+	if err != nil {
+		log.Printf("market stats failed: %v", err)
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "stats failed"})
+		return
+	}
+	now := time.Now().UTC()
+	writeJSON(w, http.StatusOK, map[string]any{
+		"period":  period,
+		"buckets": market.Compute(series, market.Buckets(period, periods, now), now),
+	})
+}
@@ -0,0 +1,251 @@
+// Package comps estimates what a listing is worth from comparable sales:
+// recently sold homes nearby that resemble it, each adjusted for the
+// features it differs in, the way an appraiser would.
+package comps
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"home-finder/internal/geo"
+	"home-finder/internal/types"
+)
+
+// Params control how comps are selected.
+type Params struct {
+	RadiusMi       float64
+	SoldWithinDays int
+	// MaxComps caps the comp set; MinComps is how many a search should find
+	// before it stops widening the radius.
+	MaxComps int
+	MinComps int
+}
+
+// DefaultParams are used for anything a request leaves unset.
+var DefaultParams = Params{RadiusMi: 1, SoldWithinDays: 180, MaxComps: 6, MinComps: 3}
+
+// Rates price the features comps are adjusted for. A comp that lacks
+// something the subject has is adjusted up, and down the other way round.
+type Rates struct {
+	// SqftFactor is the share of the comps' median sale price per square
+	// foot applied to a living-area difference; extra space adds less than
+	// the average foot costs.
+	SqftFactor float64 `json:"sqftFactor"`
+	PerBath    int     `json:"perBath"`
+	PerGarage  int     `json:"perGarageSpace"`
+	Pool       int     `json:"pool"`
+	PerLotSqft float64 `json:"perLotSqft"`
+}
+
+// DefaultRates are rough national figures.
+var DefaultRates = Rates{SqftFactor: 0.5, PerBath: 10000, PerGarage: 7500, Pool: 20000, PerLotSqft: 2}
+
+// Adjustment is one feature's correction to a comp's sale price. Subject
+// and Comp are the feature's values on each home (1 or 0 for a pool).
+type Adjustment struct {
+	Feature string  `json:"feature"`
+	Subject float64 `json:"subject"`
+	Comp    float64 `json:"comp"`
+	Rate    float64 `json:"rate"`
+	Amount  int     `json:"amount"`
+}
+
+// Comp is a sold listing with its adjustments to the subject.
+type Comp struct {
+	Listing    types.Listing `json:"listing"`
+	SalePrice  int           `json:"salePrice"`
+	DistanceMi float64       `json:"distanceMi,omitempty"`
+	// Similarity in (0, 1] weighs the comp in the estimate.
+	Similarity    float64      `json:"similarity"`
+	Adjustments   []Adjustment `json:"adjustments"`
+	AdjustedPrice int          `json:"adjustedPrice"`
+}
+
+// Estimate is the subject's value from its comps: Value is their
+// similarity-weighted adjusted price and Low and High the range of
+// adjusted prices.
+type Estimate struct {
+	Low     int `json:"low"`
+	Value   int `json:"value"`
+	High    int `json:"high"`
+	PerSqft int `json:"perSqft,omitempty"`
+	Comps   int `json:"comps"`
+}
+
+// Filters returns the search for candidate comps of subject: homes of the
+// same type sold within p.SoldWithinDays, within p.RadiusMi (or the same zip
+// or city when subject has no coordinates), with beds and baths within one,
+// living area within 25% and built within 20 years of it.
+func Filters(subject types.Listing, p Params) types.SearchFilters {
+	f := types.SearchFilters{
+		Statuses:       []string{types.StatusSold},
+		SoldWithinDays: p.SoldWithinDays,
+	}
+	switch {
+	case subject.HasLocation():
+		pt := geo.Point(subject)
+		f.Near, f.RadiusMi = &pt, p.RadiusMi
+	case subject.Zip != "":
+		f.Zip = subject.Zip
+	default:
+		f.City, f.State = subject.City, subject.State
+	}
+	if subject.PropertyType != "" {
+		f.PropertyTypes = []string{subject.PropertyType}
+	}
+	if subject.Beds > 0 {
+		f.MinBeds, f.MaxBeds = max(subject.Beds-1, 0), subject.Beds+1
+	}
+	if subject.Baths > 0 {
+		f.MinBaths, f.MaxBaths = math.Max(subject.Baths-1, 0), subject.Baths+1
+	}
+	if subject.Sqft > 0 {
+		f.MinSqft, f.MaxSqft = subject.Sqft*3/4, subject.Sqft*5/4
+	}
+	if subject.YearBuilt > 0 {
+		f.MinYearBuilt, f.MaxYearBuilt = subject.YearBuilt-20, subject.YearBuilt+20
+	}
+	return f
+}
+
+// Select ranks candidates by similarity to subject and adjusts the best
+// p.MaxComps of them. Candidates without a sale price, and subject itself,
+// are skipped.
+func Select(subject types.Listing, candidates []types.Listing, p Params, rates Rates, now time.Time) []Comp {
+	var out []Comp
+	for _, c := range candidates {
+		if c.ID == subject.ID || SalePrice(c) <= 0 {
+			continue
+		}
+		comp := Comp{Listing: c, SalePrice: SalePrice(c)}
+		if subject.HasLocation() && c.HasLocation() {
+			comp.DistanceMi = math.Round(geo.DistanceMi(geo.Point(subject), geo.Point(c))*100) / 100
+		}
+		comp.Similarity = similarity(subject, c, comp.DistanceMi, p, now)
+		out = append(out, comp)
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Similarity > out[j].Similarity })
+	if p.MaxComps > 0 && len(out) > p.MaxComps {
+		out = out[:p.MaxComps]
+	}
+	perSqft := medianPerSqft(out) * rates.SqftFactor
+	for i := range out {
+		out[i].Adjustments = adjustments(subject, out[i].Listing, perSqft, rates)
+		out[i].AdjustedPrice = out[i].SalePrice
+		for _, a := range out[i].Adjustments {
+			out[i].AdjustedPrice += a.Amount
+		}
+	}
+	return out
+}
+
+// Value estimates subject's value from its comps. It reports false when
+// there are none.
+func Value(subject types.Listing, comps []Comp) (Estimate, bool) {
+	if len(comps) == 0 {
+		return Estimate{}, false
+	}
+	est := Estimate{Low: comps[0].AdjustedPrice, High: comps[0].AdjustedPrice, Comps: len(comps)}
+	var sum, weights float64
+	for _, c := range comps {
+		est.Low = min(est.Low, c.AdjustedPrice)
+		est.High = max(est.High, c.AdjustedPrice)
+		sum += float64(c.AdjustedPrice) * c.Similarity
+		weights += c.Similarity
+	}
+	est.Value = roundThousand(sum / weights)
+	est.Low, est.High = roundThousand(float64(est.Low)), roundThousand(float64(est.High))
+	if subject.Sqft > 0 {
+		est.PerSqft = int(math.Round(float64(est.Value) / float64(subject.Sqft)))
+	}
+	return est, true
+}
+
+// SalePrice is what l sold for: SoldPrice, or the last asking price when a
+// sold listing's source does not report one.
+func SalePrice(l types.Listing) int {
+	if l.SoldPrice > 0 {
+		return l.SoldPrice
+	}
+	return l.Price
+}
+
+// similarity scores c against subject in (0, 1], falling off with each mile
+// of distance, differences in size, rooms and age, and how long ago it sold.
+func similarity(subject, c types.Listing, distanceMi float64, p Params, now time.Time) float64 {
+	penalty := distanceMi
+	if subject.Sqft > 0 && c.Sqft > 0 {
+		penalty += 4 * math.Abs(float64(c.Sqft-subject.Sqft)) / float64(subject.Sqft)
+	}
+	penalty += 0.5 * math.Abs(float64(c.Beds-subject.Beds))
+	penalty += 0.5 * math.Abs(c.Baths-subject.Baths)
+	if subject.YearBuilt > 0 && c.YearBuilt > 0 {
+		penalty += math.Abs(float64(c.YearBuilt-subject.YearBuilt)) / 20
+	}
+	if c.SoldDate != nil && p.SoldWithinDays > 0 {
+		penalty += 0.5 * now.Sub(*c.SoldDate).Hours() / 24 / float64(p.SoldWithinDays)
+	}
+	return math.Round(1/(1+penalty)*1000) / 1000
+}
+
+// adjustments corrects c's sale price for each priced feature it differs
+// from subject in. Features either side does not report are left alone.
+func adjustments(subject, c types.Listing, perSqft float64, rates Rates) []Adjustment {
+	out := []Adjustment{}
+	add := func(feature string, s, v, rate float64) {
+		if s == v {
+			return
+		}
+		out = append(out, Adjustment{
+			Feature: feature,
+			Subject: s,
+			Comp:    v,
+			Rate:    math.Round(rate*100) / 100,
+			Amount:  int(math.Round((s - v) * rate)),
+		})
+	}
+	if subject.Sqft > 0 && c.Sqft > 0 && perSqft > 0 {
+		add("sqft", float64(subject.Sqft), float64(c.Sqft), perSqft)
+	}
+	if subject.Baths > 0 && c.Baths > 0 {
+		add("baths", subject.Baths, c.Baths, float64(rates.PerBath))
+	}
+	add("garage", float64(subject.GarageSpaces), float64(c.GarageSpaces), float64(rates.PerGarage))
+	add("pool", boolValue(subject.HasPool), boolValue(c.HasPool), float64(rates.Pool))
+	if subject.LotSqft > 0 && c.LotSqft > 0 {
+		add("lot", float64(subject.LotSqft), float64(c.LotSqft), rates.PerLotSqft)
+	}
+	return out
+}
+
+// medianPerSqft is the median sale price per square foot across comps that
+// report their size, or 0.
+func medianPerSqft(comps []Comp) float64 {
+	var vals []float64
+	for _, c := range comps {
+		if c.Listing.Sqft > 0 {
+			vals = append(vals, float64(c.SalePrice)/float64(c.Listing.Sqft))
+		}
+	}
+	if len(vals) == 0 {
+		return 0
+	}
+	sort.Float64s(vals)
+	mid := len(vals) / 2
+	if len(vals)%2 == 1 {
+		return vals[mid]
+	}
+	return (vals[mid-1] + vals[mid]) / 2
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func roundThousand(v float64) int {
+	return int(math.Round(v/1000) * 1000)
+}
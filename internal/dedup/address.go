@@ -0,0 +1,195 @@
+package dedup
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Address is a street address reduced to comparable parts.
+type Address struct {
+	Number string
+	Street string
+	Unit   string
+	City   string
+	State  string
+	Zip5   string
+}
+
+// streetSuffixes follows the USPS Publication 28 standard abbreviations for
+// the suffixes that actually show up in listing feeds.
+var streetSuffixes = map[string]string{
+	"street": "st", "str": "st",
+	"avenue": "ave", "av": "ave", "aven": "ave",
+	"boulevard": "blvd", "boul": "blvd",
+	"drive": "dr", "drv": "dr",
+	"road":    "rd",
+	"lane":    "ln",
+	"court":   "ct",
+	"place":   "pl",
+	"terrace": "ter",
+	"circle":  "cir",
+	"parkway": "pkwy", "pky": "pkwy",
+	"highway":    "hwy",
+	"trail":      "trl",
+	"square":     "sq",
+	"crossing":   "xing",
+	"point":      "pt",
+	"loop":       "loop",
+	"alley":      "aly",
+	"heights":    "hts",
+	"mount":      "mt",
+	"expressway": "expy",
+	"freeway":    "fwy",
+	"center":     "ctr",
+	"cove":       "cv",
+	"creek":      "crk",
+	"ridge":      "rdg",
+	"run":        "run",
+	"view":       "vw",
+}
+
+var directionals = map[string]string{
+	"north": "n", "south": "s", "east": "e", "west": "w",
+	"northeast": "ne", "northwest": "nw", "southeast": "se", "southwest": "sw",
+}
+
+var unitDesignators = map[string]bool{
+	"apt": true, "apartment": true, "unit": true, "ste": true, "suite": true,
+	"#": true, "bldg": true, "building": true, "rm": true, "room": true,
+	"lot": true, "spc": true, "space": true,
+}
+
+// NormalizeAddress parses a free-form address line. Scrapers often put the
+// whole "123 Main St, Portland, OR 97204" in the line and leave city/state/zip
+// empty, so trailing comma-separated parts backfill whatever was not given.
+func NormalizeAddress(line, city, state, zip string) Address {
+	parts := strings.Split(line, ",")
+	street := parts[0]
+	for _, extra := range parts[1:] {
+		extra = strings.TrimSpace(extra)
+		fields := strings.Fields(strings.ToLower(extra))
+		switch {
+		case len(fields) == 0:
+		case strings.HasPrefix(extra, "#") || unitDesignators[fields[0]]:
+			street += " " + extra
+		case findZip(extra) != "":
+			if zip == "" {
+				zip = findZip(extra)
+			}
+			if state == "" && len(fields) > 1 && len(fields[0]) == 2 && isAlpha(fields[0]) {
+				state = fields[0]
+			}
+		case len(fields) == 1 && len(fields[0]) == 2 && isAlpha(fields[0]):
+			if state == "" {
+				state = fields[0]
+			}
+		case city == "":
+			city = extra
+		}
+	}
+
+	a := Address{
+		City:  strings.Join(strings.Fields(strings.ToLower(city)), " "),
+		State: strings.ToLower(strings.TrimSpace(state)),
+		Zip5:  zip5(zip),
+	}
+
+	tokens := tokenize(street)
+	var rest []string
+	for i := 0; i < len(tokens); i++ {
+		t := tokens[i]
+		if strings.HasPrefix(t, "#") && len(t) > 1 {
+			a.Unit = strings.TrimPrefix(t, "#")
+			continue
+		}
+		if unitDesignators[t] && i > 0 {
+			if i+1 < len(tokens) {
+				a.Unit = strings.TrimPrefix(tokens[i+1], "#")
+				i++
+			}
+			continue
+		}
+		if i == 0 && t != "" && unicode.IsDigit(rune(t[0])) {
+			a.Number = t
+			continue
+		}
+		if v, ok := directionals[t]; ok {
+			t = v
+		} else if v, ok := streetSuffixes[t]; ok {
+			t = v
+		}
+		rest = append(rest, t)
+	}
+	a.Street = strings.Join(rest, " ")
+	return a
+}
+
+// Key is an exact-match key for the address; equal keys are the same property.
+func (a Address) Key() string {
+	loc := a.Zip5
+	if loc == "" {
+		loc = a.City + "," + a.State
+	}
+	return strings.Join([]string{a.Number, a.Street, a.Unit, loc}, "|")
+}
+
+func tokenize(s string) []string {
+	s = strings.ToLower(s)
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '#' || r == '-':
+			b.WriteRune(r)
+		default:
+			b.WriteRune(' ')
+		}
+	}
+	var out []string
+	for _, f := range strings.Fields(b.String()) {
+		// Split "#" glued to a designator-less unit, e.g. "st#4".
+		if i := strings.Index(f, "#"); i > 0 {
+			out = append(out, f[:i], f[i:])
+			continue
+		}
+		out = append(out, f)
+	}
+	return out
+}
+
+// findZip returns the first 5-digit (optionally ZIP+4) token in s.
+func findZip(s string) string {
+	for _, f := range strings.Fields(s) {
+		if z := zip5(f); len(z) == 5 && len(strings.TrimFunc(f, func(r rune) bool { return unicode.IsDigit(r) || r == '-' })) == 0 {
+			return z
+		}
+	}
+	return ""
+}
+
+func zip5(zip string) string {
+	var digits []rune
+	for _, r := range zip {
+		if r == '-' {
+			break
+		}
+		if unicode.IsDigit(r) {
+			digits = append(digits, r)
+			if len(digits) == 5 {
+				break
+			}
+		}
+	}
+	if len(digits) != 5 {
+		return ""
+	}
+	return string(digits)
+}
+
+func isAlpha(s string) bool {
+	for _, r := range s {
+		if !unicode.IsLetter(r) {
+			return false
+		}
+	}
+	return s != ""
+}
@@ -0,0 +1,114 @@
+package dedup
+
+import "testing"
+
+func TestNormalizeAddress(t *testing.T) {
+	cases := []struct {
+		name                   string
+		line, city, state, zip string
+		want                   Address
+	}{
+		{
+			"suffix and directional",
+			"123 North Main Street", "Portland", "OR", "97204",
+			Address{Number: "123", Street: "n main st", City: "portland", State: "or", Zip5: "97204"},
+		},
+		{
+			"variant suffix spelling",
+			"9 Grand Boul", "Portland", "OR", "97204",
+			Address{Number: "9", Street: "grand blvd", City: "portland", State: "or", Zip5: "97204"},
+		},
+		{
+			"apartment designator",
+			"456 Oak Avenue Apt 4B", "Seattle", "wa", "98101-1234",
+			Address{Number: "456", Street: "oak ave", Unit: "4b", City: "seattle", State: "wa", Zip5: "98101"},
+		},
+		{
+			"hash unit",
+			"456 Oak Ave #4b", "Seattle", "WA", "98101",
+			Address{Number: "456", Street: "oak ave", Unit: "4b", City: "seattle", State: "wa", Zip5: "98101"},
+		},
+		{
+			"hash glued to street",
+			"456 Oak Ave#4B", "Seattle", "WA", "98101",
+			Address{Number: "456", Street: "oak ave", Unit: "4b", City: "seattle", State: "wa", Zip5: "98101"},
+		},
+		{
+			"unit after a comma",
+			"456 Oak Ave, Suite 200", "Seattle", "WA", "98101",
+			Address{Number: "456", Street: "oak ave", Unit: "200", City: "seattle", State: "wa", Zip5: "98101"},
+		},
+		{
+			"whole address in the line",
+			"123 Main St, Portland, OR 97204", "", "", "",
+			Address{Number: "123", Street: "main st", City: "portland", State: "or", Zip5: "97204"},
+		},
+		{
+			"line does not override given fields",
+			"123 Main St, Beaverton, OR 97005", "Portland", "OR", "97204",
+			Address{Number: "123", Street: "main st", City: "portland", State: "or", Zip5: "97204"},
+		},
+		{
+			"state on its own",
+			"5 Elm Ct, Boise, ID", "", "", "",
+			Address{Number: "5", Street: "elm ct", City: "boise", State: "id"},
+		},
+		{
+			"no street number",
+			"Lot 7 Canyon Road", "Bend", "OR", "",
+			Address{Street: "lot 7 canyon rd", City: "bend", State: "or"},
+		},
+		{
+			"short zip dropped",
+			"1 Shore Rd", "Lake  Oswego", "OR", "9703",
+			Address{Number: "1", Street: "shore rd", City: "lake oswego", State: "or"},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := NormalizeAddress(c.line, c.city, c.state, c.zip); got != c.want {
+				t.Errorf("NormalizeAddress(%q, %q, %q, %q) = %+v, want %+v", c.line, c.city, c.state, c.zip, got, c.want)
+			}
+		})
+	}
+}
+
+func TestAddressKey(t *testing.T) {
+	cases := []struct {
+		name string
+		a, b Address
+		same bool
+	}{
+		{
+			"suffix spellings agree",
+			NormalizeAddress("12 Pine Street", "", "", "97204"),
+			NormalizeAddress("12 pine st.", "", "", "97204"),
+			true,
+		},
+		{
+			"unit designators agree",
+			NormalizeAddress("12 Pine St Unit 3", "", "", "97204"),
+			NormalizeAddress("12 Pine St #3", "", "", "97204"),
+			true,
+		},
+		{
+			"different units",
+			NormalizeAddress("12 Pine St Unit 3", "", "", "97204"),
+			NormalizeAddress("12 Pine St Unit 4", "", "", "97204"),
+			false,
+		},
+		{
+			"city used without zip",
+			NormalizeAddress("12 Pine St", "Portland", "OR", ""),
+			NormalizeAddress("12 Pine Street", "portland", "or", ""),
+			true,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if same := c.a.Key() == c.b.Key(); same != c.same {
+				t.Errorf("keys %q and %q: same = %v, want %v", c.a.Key(), c.b.Key(), same, c.same)
+			}
+		})
+	}
+}
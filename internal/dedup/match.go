@@ -0,0 +1,91 @@
+package dedup
+
+import (
+	"math"
+
+	"home-finder/internal/types"
+)
+
+// Thresholds for treating two records as the same property.
+const (
+	minStreetSimilarity = 0.85
+	maxBathDelta        = 0.5
+	maxSqftRatioDelta   = 0.10
+)
+
+// Match reports whether a and b describe the same property. Street numbers and
+// units must agree exactly; the street name may differ by small typos; beds,
+// baths and sqft must be compatible wherever both sources report them.
+func Match(a, b types.Listing) bool {
+	return matchNormalized(a, NormalizeAddress(a.Address, a.City, a.State, a.Zip), b, NormalizeAddress(b.Address, b.City, b.State, b.Zip))
+}
+
+func matchNormalized(a types.Listing, aa Address, b types.Listing, ba Address) bool {
+	if aa.Number == "" || aa.Number != ba.Number || aa.Unit != ba.Unit {
+		return false
+	}
+	switch {
+	case aa.Zip5 != "" && ba.Zip5 != "":
+		if aa.Zip5 != ba.Zip5 {
+			return false
+		}
+	case aa.City != "" && ba.City != "":
+		if aa.City != ba.City || (aa.State != "" && ba.State != "" && aa.State != ba.State) {
+			return false
+		}
+	default:
+		return false
+	}
+	if similarity(aa.Street, ba.Street) < minStreetSimilarity {
+		return false
+	}
+	if a.Beds > 0 && b.Beds > 0 && a.Beds != b.Beds {
+		return false
+	}
+	if a.Baths > 0 && b.Baths > 0 && math.Abs(a.Baths-b.Baths) > maxBathDelta {
+		return false
+	}
+	if a.Sqft > 0 && b.Sqft > 0 {
+		delta := math.Abs(float64(a.Sqft - b.Sqft))
+		if delta/math.Max(float64(a.Sqft), float64(b.Sqft)) > maxSqftRatioDelta {
+			return false
+		}
+	}
+	return true
+}
+
+// similarity is 1 - normalized Levenshtein distance.
+func similarity(a, b string) float64 {
+	if a == b {
+		return 1
+	}
+	ra, rb := []rune(a), []rune(b)
+	longest := len(ra)
+	if len(rb) > longest {
+		longest = len(rb)
+	}
+	if longest == 0 {
+		return 1
+	}
+	return 1 - float64(levenshtein(ra, rb))/float64(longest)
+}
+
+func levenshtein(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
@@ -0,0 +1,76 @@
+package dedup
+
+import (
+	"testing"
+
+	"home-finder/internal/types"
+)
+
+func TestMatch(t *testing.T) {
+	base := types.Listing{
+		Address: "123 Washington Street", City: "Portland", State: "OR", Zip: "97204",
+		Latitude: 45.51910, Longitude: -122.67700, Beds: 3, Baths: 2, Sqft: 1500,
+	}
+	with := func(edit func(*types.Listing)) types.Listing {
+		l := base
+		edit(&l)
+		return l
+	}
+	cases := []struct {
+		name string
+		b    types.Listing
+		want bool
+	}{
+		{"identical", base, true},
+		{"suffix abbreviated", with(func(l *types.Listing) { l.Address = "123 Washington St." }), true},
+		{"whole address in the line", with(func(l *types.Listing) {
+			l.Address, l.City, l.State, l.Zip = "123 Washington St, Portland, OR 97204", "", "", ""
+		}), true},
+		{"street typo", with(func(l *types.Listing) { l.Address = "123 Washingon St" }), true},
+		{"different street", with(func(l *types.Listing) { l.Address = "123 Wellington St" }), false},
+		{"different number", with(func(l *types.Listing) { l.Address = "125 Washington St" }), false},
+		{"unit on one side", with(func(l *types.Listing) { l.Address = "123 Washington St Apt 2" }), false},
+		{"different zip", with(func(l *types.Listing) { l.Zip = "97209" }), false},
+		{"city without zip", with(func(l *types.Listing) { l.Zip = "" }), true},
+		// Sources geocode the same door a few meters apart; coordinates
+		// never decide a match.
+		{"near-miss coordinates", with(func(l *types.Listing) { l.Latitude, l.Longitude = 45.51913, -122.67705 }), true},
+		{"no coordinates", with(func(l *types.Listing) { l.Latitude, l.Longitude = 0, 0 }), true},
+		{"next door at the same point", with(func(l *types.Listing) { l.Address = "121 Washington St" }), false},
+		{"beds disagree", with(func(l *types.Listing) { l.Beds = 4 }), false},
+		{"beds unreported", with(func(l *types.Listing) { l.Beds = 0 }), true},
+		{"baths within half", with(func(l *types.Listing) { l.Baths = 2.5 }), true},
+		{"baths apart", with(func(l *types.Listing) { l.Baths = 3 }), false},
+		{"sqft within ten percent", with(func(l *types.Listing) { l.Sqft = 1400 }), true},
+		{"sqft apart", with(func(l *types.Listing) { l.Sqft = 1300 }), false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := Match(base, c.b); got != c.want {
+				t.Errorf("Match = %v, want %v", got, c.want)
+			}
+			if got := Match(c.b, base); got != c.want {
+				t.Errorf("Match reversed = %v, want %v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestMatchNeedsStreetNumberAndLocation(t *testing.T) {
+	cases := []struct {
+		name string
+		a, b types.Listing
+	}{
+		{"no number", types.Listing{Address: "Main St", Zip: "97204"}, types.Listing{Address: "Main St", Zip: "97204"}},
+		{"no location", types.Listing{Address: "1 Main St"}, types.Listing{Address: "1 Main St"}},
+		{"zip against city", types.Listing{Address: "1 Main St", Zip: "97204"}, types.Listing{Address: "1 Main St", City: "Portland"}},
+		{"states disagree", types.Listing{Address: "1 Main St", City: "Portland", State: "OR"}, types.Listing{Address: "1 Main St", City: "Portland", State: "ME"}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if Match(c.a, c.b) {
+				t.Errorf("Match(%q, %q) = true", c.a.Address, c.b.Address)
+			}
+		})
+	}
+}
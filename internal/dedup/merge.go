@@ -0,0 +1,277 @@
+package dedup
+
+import (
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+
+	"home-finder/internal/types"
+)
+
+// Priority ranks sources from most to least trusted by name. Sources it does
+// not name rank below the ones it does, in name order.
+type Priority []string
+
+func (p Priority) rank(source string) int {
+	for i, name := range p {
+		if name == source {
+			return i
+		}
+	}
+	return len(p)
+}
+
+// less orders sources by p, breaking ties between unlisted ones by name.
+func (p Priority) less(a, b string) bool {
+	ra, rb := p.rank(a), p.rank(b)
+	if ra != rb {
+		return ra < rb
+	}
+	return ra == len(p) && a < b
+}
+
+// Dedupe collapses duplicate records into canonical listings (see Canonical).
+// Output keeps first-occurrence order.
+func Dedupe(listings []types.Listing, p Priority) []types.Listing {
+	groups := Group(listings)
+	out := make([]types.Listing, 0, len(groups))
+	for _, g := range groups {
+		out = append(out, Canonical(g, p))
+	}
+	return out
+}
+
+// Group clusters matching records. Only records sharing a street number and
+// location bucket are compared, so this stays near-linear on large batches.
+func Group(listings []types.Listing) [][]types.Listing {
+	addrs := make([]Address, len(listings))
+	parent := make([]int, len(listings))
+	buckets := make(map[string][]int)
+	for i, l := range listings {
+		parent[i] = i
+		addrs[i] = NormalizeAddress(l.Address, l.City, l.State, l.Zip)
+		// Records without a zip can still match on city, so index under both.
+		for _, loc := range []string{addrs[i].Zip5, addrs[i].City} {
+			if loc != "" && addrs[i].Number != "" {
+				key := addrs[i].Number + "|" + loc
+				buckets[key] = append(buckets[key], i)
+			}
+		}
+	}
+
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+	union := func(i, j int) {
+		ri, rj := find(i), find(j)
+		// Keep the earliest record as root so groups come out in
+		// first-occurrence order.
+		if ri < rj {
+			parent[rj] = ri
+		} else if rj < ri {
+			parent[ri] = rj
+		}
+	}
+
+	byID := make(map[string]int)
+	for i, l := range listings {
+		if l.ID == "" {
+			continue
+		}
+		if j, ok := byID[l.ID]; ok {
+			union(j, i)
+		} else {
+			byID[l.ID] = i
+		}
+	}
+	for _, idx := range buckets {
+		for x := 0; x < len(idx); x++ {
+			for y := x + 1; y < len(idx); y++ {
+				i, j := idx[x], idx[y]
+				if find(i) != find(j) && matchNormalized(listings[i], addrs[i], listings[j], addrs[j]) {
+					union(i, j)
+				}
+			}
+		}
+	}
+
+	order := make(map[int]int)
+	var groups [][]types.Listing
+	for i, l := range listings {
+		root := find(i)
+		gi, ok := order[root]
+		if !ok {
+			gi = len(groups)
+			order[root] = gi
+			groups = append(groups, nil)
+		}
+		groups[gi] = append(groups[gi], l)
+	}
+	return groups
+}
+
+// mergeSkip are fields Canonical handles explicitly.
+var mergeSkip = map[string]bool{
+	"ID": true, "Source": true, "Tags": true, "VisionTags": true,
+	"Provenance": true, "Alternates": true,
+}
+
+// Canonical merges a duplicate group into one listing. Records are ranked by
+// p; only records from the same source keep their group order, so callers
+// list fresher copies first. The top-ranked record supplies the ID, Source
+// and every flag, since a false flag is as much a claim as a true one. Every
+// other field takes the first non-zero value in rank order. Provenance
+// records which Source supplied each value, and tags are unioned. A
+// single-record group is returned unchanged.
+func Canonical(group []types.Listing, p Priority) types.Listing {
+	if len(group) == 0 {
+		return types.Listing{}
+	}
+	if len(group) == 1 {
+		return group[0]
+	}
+	group = slices.Clone(group)
+	sort.SliceStable(group, func(i, j int) bool { return p.less(group[i].Source, group[j].Source) })
+
+	out := types.Listing{ID: group[0].ID, Source: group[0].Source}
+	prov := make(map[string]string)
+	dst := reflect.ValueOf(&out).Elem()
+	t := dst.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if mergeSkip[field.Name] {
+			continue
+		}
+		if field.Type.Kind() == reflect.Bool {
+			dst.Field(i).Set(reflect.ValueOf(group[0]).Field(i))
+			prov[jsonName(field)] = group[0].Source
+			continue
+		}
+		for _, l := range group {
+			v := reflect.ValueOf(l).Field(i)
+			if v.IsZero() {
+				continue
+			}
+			dst.Field(i).Set(v)
+			prov[jsonName(field)] = l.Source
+			break
+		}
+	}
+
+	var tags, visionTags []string
+	for _, l := range group {
+		tags = append(tags, l.Tags...)
+		visionTags = append(visionTags, l.VisionTags...)
+		// Provenance recorded on an already-merged record is more precise than its Source.
+		for k, v := range l.Provenance {
+			if prov[k] == l.Source && v != "" {
+				prov[k] = v
+			}
+		}
+	}
+	out.Tags = unionTags(tags)
+	out.VisionTags = unionTags(visionTags)
+	out.Provenance = prov
+	out.Alternates = alternates(group)
+	return out
+}
+
+// Refresh updates stored, a saved listing, with fresh, a new copy of it from
+// its source. Unlike Canonical, fresh wins every field it reports, false
+// amenity flags included, so a feature the source has since taken back does
+// not linger. Fields fresh leaves empty keep their stored values, which
+// preserves what only the store knows, such as vision results and values
+// merged in from other sources. stored's ID is kept.
+func Refresh(fresh, stored types.Listing) types.Listing {
+	out := fresh
+	out.ID = stored.ID
+	var prov map[string]string
+	if len(stored.Provenance) > 0 {
+		prov = make(map[string]string, len(stored.Provenance))
+	}
+	dst := reflect.ValueOf(&out).Elem()
+	old := reflect.ValueOf(stored)
+	t := dst.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if mergeSkip[field.Name] {
+			continue
+		}
+		name := jsonName(field)
+		if field.Type.Kind() == reflect.Bool || !dst.Field(i).IsZero() {
+			if prov != nil {
+				prov[name] = fresh.Source
+			}
+			continue
+		}
+		if v := old.Field(i); !v.IsZero() {
+			dst.Field(i).Set(v)
+			if prov != nil && stored.Provenance[name] != "" {
+				prov[name] = stored.Provenance[name]
+			}
+		}
+	}
+	if len(out.Tags) == 0 {
+		out.Tags = stored.Tags
+	}
+	if len(out.VisionTags) == 0 {
+		out.VisionTags = stored.VisionTags
+	}
+	if len(stored.Alternates) > 0 {
+		out.Alternates = alternates([]types.Listing{fresh, stored})
+	}
+	out.Provenance = prov
+	return out
+}
+
+func alternates(group []types.Listing) []types.SourceRef {
+	seen := make(map[types.SourceRef]struct{})
+	var out []types.SourceRef
+	add := func(ref types.SourceRef) {
+		if ref.ID == "" {
+			return
+		}
+		if _, ok := seen[ref]; ok {
+			return
+		}
+		seen[ref] = struct{}{}
+		out = append(out, ref)
+	}
+	for _, l := range group {
+		add(types.SourceRef{Source: l.Source, ID: l.ID})
+		for _, alt := range l.Alternates {
+			add(alt)
+		}
+	}
+	return out
+}
+
+func unionTags(tags []string) []string {
+	seen := make(map[string]struct{}, len(tags))
+	var out []string
+	for _, t := range tags {
+		key := strings.ToLower(strings.TrimSpace(t))
+		if key == "" {
+			continue
+		}
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+		out = append(out, t)
+	}
+	return out
+}
+
+func jsonName(f reflect.StructField) string {
+	name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+	if name == "" {
+		return f.Name
+	}
+	return name
+}
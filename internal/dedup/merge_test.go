@@ -0,0 +1,115 @@
+package dedup
+
+import (
+	"reflect"
+	"testing"
+
+	"home-finder/internal/types"
+)
+
+func TestPriorityLess(t *testing.T) {
+	p := Priority{"mls", "zillow"}
+	cases := []struct {
+		a, b string
+		want bool
+	}{
+		{"mls", "zillow", true},
+		{"zillow", "mls", false},
+		{"zillow", "craigslist", true},
+		{"craigslist", "redfin", true},
+		{"redfin", "craigslist", false},
+		{"mls", "mls", false},
+		{"redfin", "redfin", false},
+	}
+	for _, c := range cases {
+		if got := p.less(c.a, c.b); got != c.want {
+			t.Errorf("less(%q, %q) = %v, want %v", c.a, c.b, got, c.want)
+		}
+	}
+}
+
+func TestCanonicalSourcePriority(t *testing.T) {
+	mls := types.Listing{ID: "m1", Source: "mls", Price: 500000, HasPool: false, Tags: []string{"Garden"}}
+	zillow := types.Listing{ID: "z1", Source: "zillow", Price: 495000, Beds: 3, HasPool: true, Tags: []string{"garden", "pool"}}
+	redfin := types.Listing{ID: "r1", Source: "redfin", Sqft: 1500, Beds: 4}
+	craigslist := types.Listing{ID: "c1", Source: "craigslist", Sqft: 1450, Description: "cash only"}
+	p := Priority{"mls", "zillow"}
+
+	cases := []struct {
+		name      string
+		group     []types.Listing
+		want      types.Listing
+		wantAlts  []string
+		wantProvs map[string]string
+	}{
+		{
+			name:      "listed sources first",
+			group:     []types.Listing{zillow, mls},
+			want:      types.Listing{ID: "m1", Source: "mls", Price: 500000, Beds: 3, Tags: []string{"Garden", "pool"}},
+			wantAlts:  []string{"m1", "z1"},
+			wantProvs: map[string]string{"price": "mls", "beds": "zillow", "hasPool": "mls"},
+		},
+		{
+			name:      "unlisted sources tie broken by name",
+			group:     []types.Listing{redfin, craigslist},
+			want:      types.Listing{ID: "c1", Source: "craigslist", Sqft: 1450, Beds: 4, Description: "cash only"},
+			wantAlts:  []string{"c1", "r1"},
+			wantProvs: map[string]string{"sqft": "craigslist", "beds": "redfin"},
+		},
+		{
+			name:      "unlisted ranks below listed",
+			group:     []types.Listing{craigslist, redfin, zillow},
+			want:      types.Listing{ID: "z1", Source: "zillow", Price: 495000, Beds: 3, Sqft: 1450, HasPool: true, Description: "cash only", Tags: []string{"garden", "pool"}},
+			wantAlts:  []string{"z1", "c1", "r1"},
+			wantProvs: map[string]string{"price": "zillow", "sqft": "craigslist", "hasPool": "zillow"},
+		},
+		{
+			name:      "same source keeps group order",
+			group:     []types.Listing{{ID: "m2", Source: "mls", Price: 510000}, mls},
+			want:      types.Listing{ID: "m2", Source: "mls", Price: 510000, Tags: []string{"Garden"}},
+			wantAlts:  []string{"m2", "m1"},
+			wantProvs: map[string]string{"price": "mls"},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := Canonical(c.group, p)
+			var alts []string
+			for _, ref := range got.Alternates {
+				alts = append(alts, ref.ID)
+			}
+			if !reflect.DeepEqual(alts, c.wantAlts) {
+				t.Errorf("alternates = %v, want %v", alts, c.wantAlts)
+			}
+			for field, source := range c.wantProvs {
+				if got.Provenance[field] != source {
+					t.Errorf("provenance[%q] = %q, want %q", field, got.Provenance[field], source)
+				}
+			}
+			got.Alternates, got.Provenance = nil, nil
+			if !reflect.DeepEqual(got, c.want) {
+				t.Errorf("Canonical = %+v\nwant %+v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestDedupe(t *testing.T) {
+	listings := []types.Listing{
+		{ID: "z1", Source: "zillow", Address: "12 Pine Street", City: "Portland", State: "OR", Zip: "97204", Latitude: 45.5191, Longitude: -122.6770, Beds: 2},
+		{ID: "n1", Source: "mls", Address: "14 Pine St", City: "Portland", State: "OR", Zip: "97204", Latitude: 45.5191, Longitude: -122.6770},
+		{ID: "m1", Source: "mls", Address: "12 Pine St", City: "Portland", State: "OR", Zip: "97204", Latitude: 45.5192, Longitude: -122.6771, Price: 400000},
+		{ID: "z2", Source: "zillow", Address: "12 Pine St Unit 2", Zip: "97204"},
+	}
+	got := Dedupe(listings, Priority{"mls"})
+	var ids []string
+	for _, l := range got {
+		ids = append(ids, l.ID)
+	}
+	if want := []string{"m1", "n1", "z2"}; !reflect.DeepEqual(ids, want) {
+		t.Fatalf("Dedupe IDs = %v, want %v", ids, want)
+	}
+	if got[0].Price != 400000 || got[0].Beds != 2 {
+		t.Errorf("merged = %+v, want price from mls and beds from zillow", got[0])
+	}
+}
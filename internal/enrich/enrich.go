@@ -0,0 +1,308 @@
+// Package enrich runs listing photos through the vision client before
+// listings are stored, keeping each listing's VisionTags in step with its
+// latest analysis.
+package enrich
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"home-finder/internal/store"
+	"home-finder/internal/types"
+	"home-finder/internal/vision"
+)
+
+// Aggregation modes for combining per-photo confidences.
+const (
+	// NoisyOR treats photos as independent evidence: 1 - Π(1 - score). Two
+	// photos that each half-show a pool add up to a likely pool.
+	NoisyOR = "noisy-or"
+	// Max takes the single most convincing photo.
+	Max = "max"
+)
+
+// Config controls the enrichment stage.
+type Config struct {
+	// Workers bounds concurrent vision calls.
+	Workers int
+	// MinConfidence is the score a tag needs to land in VisionTags. All
+	// scores are kept in Listing.Vision regardless.
+	MinConfidence float64
+	// MaxAge re-analyzes photos whose analysis is older than this; zero
+	// keeps analyses until the photo changes.
+	MaxAge time.Duration
+	// Version re-analyzes photos analyzed under a different prompt version;
+	// New defaults it to the client's version (see vision.Versioned), and
+	// empty skips the check.
+	Version string
+	// MaxPhotos caps how many gallery photos are analyzed per listing, in
+	// display order.
+	MaxPhotos int
+	// Aggregate is NoisyOR or Max.
+	Aggregate string
+}
+
+// ConfigFromEnv reads the enrichment variables:
+//
+//	VISION_WORKERS         concurrent vision calls (default 4)
+//	VISION_MIN_CONFIDENCE  score a tag needs to count as a vision tag (default 0.5)
+//	VISION_MAX_AGE         re-analyze photos after this long (default 720h, 0 disables)
+//	VISION_MAX_PHOTOS      photos analyzed per listing (default 8)
+//	VISION_AGGREGATE       how photo scores combine: noisy-or (default) or max
+func ConfigFromEnv() (Config, error) {
+	cfg := Config{
+		Workers:       4,
+		MinConfidence: 0.5,
+		MaxAge:        720 * time.Hour,
+		MaxPhotos:     8,
+		Aggregate:     NoisyOR,
+	}
+	if v := os.Getenv("VISION_WORKERS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return cfg, fmt.Errorf("VISION_WORKERS must be a positive integer")
+		}
+		cfg.Workers = n
+	}
+	if v := os.Getenv("VISION_MIN_CONFIDENCE"); v != "" {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil || f < 0 || f > 1 {
+			return cfg, fmt.Errorf("VISION_MIN_CONFIDENCE must be between 0 and 1")
+		}
+		cfg.MinConfidence = f
+	}
+	if v := os.Getenv("VISION_MAX_AGE"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			return cfg, fmt.Errorf("VISION_MAX_AGE must be a non-negative duration")
+		}
+		cfg.MaxAge = d
+	}
+	if v := os.Getenv("VISION_MAX_PHOTOS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return cfg, fmt.Errorf("VISION_MAX_PHOTOS must be a positive integer")
+		}
+		cfg.MaxPhotos = n
+	}
+	if v := os.Getenv("VISION_AGGREGATE"); v != "" {
+		if v != NoisyOR && v != Max {
+			return cfg, fmt.Errorf("VISION_AGGREGATE must be %q or %q", NoisyOR, Max)
+		}
+		cfg.Aggregate = v
+	}
+	return cfg, nil
+}
+
+// Stats counts what one Enrich call did, in photos.
+type Stats struct {
+	Analyzed int // photos sent to the vision client
+	Reused   int // stored photo analyses still current
+	Failed   int // vision calls that errored; the previous analysis is kept
+}
+
+// Enricher attaches vision analyses to listings. Stored photo analyses are
+// reused while the prompt version and age allow, so a re-ingested listing
+// only pays for photos that are new to its gallery.
+type Enricher struct {
+	client   vision.Client
+	cfg      Config
+	listings store.ListingRepository
+	now      func() time.Time
+}
+
+// New returns an Enricher that looks up stored analyses in listings, which
+// may be nil when there is no store to consult.
+func New(client vision.Client, cfg Config, listings store.ListingRepository) *Enricher {
+	if cfg.Workers <= 0 {
+		cfg.Workers = 1
+	}
+	if cfg.Aggregate == "" {
+		cfg.Aggregate = NoisyOR
+	}
+	if v, ok := client.(vision.Versioned); ok && cfg.Version == "" {
+		cfg.Version = v.Version()
+	}
+	return &Enricher{client: client, cfg: cfg, listings: listings, now: time.Now}
+}
+
+// photoJob is the slot-th photo of listings[listing], due for analysis.
+type photoJob struct {
+	listing, slot int
+	url           string
+}
+
+// Enrich updates listings in place. Listings without photos are left alone;
+// every other listing ends up with an analysis aggregated over its photos
+// and VisionTags derived from it and the configured threshold.
+func (e *Enricher) Enrich(ctx context.Context, listings []types.Listing) Stats {
+	var stats Stats
+	// slots[i][k] is the analysis of listing i's k-th photo.
+	slots := make([][]*types.PhotoAnalysis, len(listings))
+	var jobs []photoJob
+	for i := range listings {
+		l := &listings[i]
+		urls := analyzable(*l)
+		if len(urls) == 0 {
+			continue
+		}
+		if e.cfg.MaxPhotos > 0 && len(urls) > e.cfg.MaxPhotos {
+			urls = urls[:e.cfg.MaxPhotos]
+		}
+		if l.Vision == nil && e.listings != nil {
+			stored, err := e.listings.Get(ctx, l.ID)
+			if err != nil && !errors.Is(err, store.ErrNotFound) {
+				log.Printf("enrich %s: load stored analysis: %v", l.ID, err)
+			}
+			l.Vision = stored.Vision
+		}
+		prev := make(map[string]*types.PhotoAnalysis)
+		if l.Vision != nil {
+			for k := range l.Vision.Photos {
+				prev[l.Vision.Photos[k].URL] = &l.Vision.Photos[k]
+			}
+		}
+		slots[i] = make([]*types.PhotoAnalysis, len(urls))
+		for k, u := range urls {
+			if p := prev[u]; p != nil && !e.stale(p) {
+				slots[i][k] = p
+				stats.Reused++
+				continue
+			}
+			// Keep the stored analysis unless a fresh one replaces it.
+			slots[i][k] = prev[u]
+			jobs = append(jobs, photoJob{listing: i, slot: k, url: u})
+		}
+	}
+
+	work := make(chan photoJob)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for w := 0; w < e.cfg.Workers && w < len(jobs); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range work {
+				p, err := e.analyze(ctx, j.url)
+				mu.Lock()
+				if err != nil {
+					log.Printf("enrich %s: %s: %v", listings[j.listing].ID, j.url, err)
+					stats.Failed++
+				} else {
+					slots[j.listing][j.slot] = p
+					stats.Analyzed++
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, j := range jobs {
+		if ctx.Err() != nil {
+			break
+		}
+		work <- j
+	}
+	close(work)
+	wg.Wait()
+
+	for i, photos := range slots {
+		if photos == nil {
+			continue
+		}
+		if a := e.aggregate(photos); a != nil {
+			listings[i].Vision = a
+			listings[i].VisionTags = e.tags(a)
+		}
+	}
+	return stats
+}
+
+// analyzable lists the photos worth analyzing: the gallery in display
+// order minus stock shots, which say nothing about this home.
+func analyzable(l types.Listing) []string {
+	stock := make(map[string]bool)
+	for _, p := range l.Photos {
+		if p.Stock {
+			stock[p.URL] = true
+		}
+	}
+	var out []string
+	for _, u := range l.PhotoURLs() {
+		if !stock[u] {
+			out = append(out, u)
+		}
+	}
+	return out
+}
+
+func (e *Enricher) analyze(ctx context.Context, url string) (*types.PhotoAnalysis, error) {
+	features, err := e.client.ExtractFeatures(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	version := features.Version
+	if version == "" {
+		version = e.cfg.Version
+	}
+	return &types.PhotoAnalysis{
+		URL:        url,
+		Scores:     features.Tags,
+		Model:      features.Model,
+		Version:    version,
+		AnalyzedAt: e.now().UTC(),
+	}, nil
+}
+
+func (e *Enricher) stale(p *types.PhotoAnalysis) bool {
+	switch {
+	case e.cfg.Version != "" && p.Version != e.cfg.Version:
+		return true
+	case e.cfg.MaxAge > 0 && e.now().Sub(p.AnalyzedAt) > e.cfg.MaxAge:
+		return true
+	}
+	return false
+}
+
+// aggregate combines the photo analyses, skipping photos that have none,
+// and records which photo scored each tag highest. It returns nil when no
+// photo has been analyzed.
+func (e *Enricher) aggregate(photos []*types.PhotoAnalysis) *types.VisionAnalysis {
+	var a *types.VisionAnalysis
+	best := make(map[string]float64)
+	for _, p := range photos {
+		if p == nil {
+			continue
+		}
+		if a == nil {
+			a = &types.VisionAnalysis{Scores: map[string]float64{}, Evidence: map[string]string{}}
+		}
+		a.Photos = append(a.Photos, *p)
+		if !p.AnalyzedAt.Before(a.AnalyzedAt) {
+			a.Model, a.Version, a.AnalyzedAt = p.Model, p.Version, p.AnalyzedAt
+		}
+		for tag, score := range p.Scores {
+			if e.cfg.Aggregate == Max {
+				a.Scores[tag] = math.Max(a.Scores[tag], score)
+			} else {
+				a.Scores[tag] = 1 - (1-a.Scores[tag])*(1-score)
+			}
+			if _, seen := best[tag]; !seen || score > best[tag] {
+				best[tag] = score
+				a.Evidence[tag] = p.URL
+			}
+		}
+	}
+	return a
+}
+
+// tags lists the analysis's tags scored at or above MinConfidence, sorted.
+func (e *Enricher) tags(a *types.VisionAnalysis) []string {
+	return a.ScoredTags(e.cfg.MinConfidence)
+}
@@ -0,0 +1,131 @@
+// Package facet defines the facet counts returned next to /search results.
+// Each facet is counted over the filtered set with that facet's own filter
+// removed, so the panel shows what every other choice would yield.
+package facet
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"home-finder/internal/types"
+)
+
+const (
+	PropertyType   = "property_type"
+	Beds           = "beds"
+	Tags           = "tags"
+	PriceHistogram = "price_histogram"
+	City           = "city"
+)
+
+// MaxValues caps open-ended facets (tags, city) to the most frequent values.
+const MaxValues = 50
+
+var known = map[string]bool{
+	PropertyType:   true,
+	Beds:           true,
+	Tags:           true,
+	PriceHistogram: true,
+	City:           true,
+}
+
+// PriceEdges are the lower bounds of the price histogram buckets; the last
+// bucket is open-ended. Fixed edges keep SQL and in-memory counts identical.
+var PriceEdges = []int{0, 100000, 200000, 300000, 400000, 500000, 750000, 1000000, 1500000, 2000000, 3000000, 5000000}
+
+// Parse reads a comma-separated facet list, dropping duplicates. Unknown
+// names are an error.
+func Parse(raw string) ([]string, error) {
+	var names []string
+	seen := map[string]bool{}
+	for _, p := range strings.Split(raw, ",") {
+		p = strings.ToLower(strings.TrimSpace(p))
+		if p == "" || seen[p] {
+			continue
+		}
+		if !known[p] {
+			return nil, fmt.Errorf("unknown facet %q", p)
+		}
+		seen[p] = true
+		names = append(names, p)
+	}
+	return names, nil
+}
+
+// Without returns filters with the named facet's own filter removed.
+func Without(f types.SearchFilters, name string) types.SearchFilters {
+	switch name {
+	case PropertyType:
+		f.PropertyTypes = nil
+	case Beds:
+		f.MinBeds, f.MaxBeds = 0, 0
+	case Tags:
+		f.Tags = nil
+	case PriceHistogram:
+		f.MinPrice, f.MaxPrice = 0, 0
+	case City:
+		f.City = ""
+	}
+	return f
+}
+
+// PriceBucket is the histogram bucket index for price. Prices below the first
+// edge fall into the first bucket.
+func PriceBucket(price int) int {
+	i := sort.Search(len(PriceEdges), func(i int) bool { return PriceEdges[i] > price }) - 1
+	if i < 0 {
+		return 0
+	}
+	return i
+}
+
+// Histogram turns per-bucket counts into buckets, keeping empty ones so the
+// shape is stable for charts.
+func Histogram(counts map[int]int) []types.FacetBucket {
+	out := make([]types.FacetBucket, len(PriceEdges))
+	for i, lo := range PriceEdges {
+		min := float64(lo)
+		b := types.FacetBucket{Count: counts[i], Min: &min}
+		if i+1 < len(PriceEdges) {
+			max := float64(PriceEdges[i+1])
+			b.Max = &max
+			b.Value = strconv.Itoa(lo) + "-" + strconv.Itoa(PriceEdges[i+1])
+		} else {
+			b.Value = strconv.Itoa(lo) + "+"
+		}
+		out[i] = b
+	}
+	return out
+}
+
+// Values turns value counts into buckets. Beds are ordered numerically; other
+// facets by descending count then value, capped at MaxValues.
+func Values(name string, counts map[string]int) []types.FacetBucket {
+	out := make([]types.FacetBucket, 0, len(counts))
+	for v, n := range counts {
+		if v == "" || n == 0 {
+			continue
+		}
+		out = append(out, types.FacetBucket{Value: v, Count: n})
+	}
+	if name == Beds {
+		sort.Slice(out, func(i, j int) bool {
+			a, _ := strconv.Atoi(out[i].Value)
+			b, _ := strconv.Atoi(out[j].Value)
+			return a < b
+		})
+		return out
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Count != out[j].Count {
+			return out[i].Count > out[j].Count
+		}
+		return out[i].Value < out[j].Value
+	})
+	if len(out) > MaxValues {
+		out = out[:MaxValues]
+	}
+	return out
+}
@@ -0,0 +1,132 @@
+package filterexpr
+
+import (
+	"strconv"
+	"strings"
+
+	"home-finder/internal/types"
+)
+
+// Expr is a node of a parsed filter: *Binary, *Not or *Cond.
+type Expr interface {
+	// Eval reports whether l satisfies the expression. Tag conditions match
+	// against tags, normally l.TagPool for the search's vision settings.
+	Eval(l types.Listing, tags []string) bool
+	// String renders the canonical form, which parses back to the same tree.
+	String() string
+}
+
+// Binary is an AND or OR of two expressions.
+type Binary struct {
+	Op          string // "AND" or "OR"
+	Left, Right Expr
+}
+
+// Not negates an expression.
+type Not struct {
+	X Expr
+}
+
+// Cond compares one field against a constant. Value holds a float64 for
+// Number fields, a string for Text and Tag fields and a bool for Flag fields.
+type Cond struct {
+	Field string // canonical name
+	Op    string // =, !=, <, <=, >, >=
+	Value any
+	Pos   int // byte offset of the field name in the source
+}
+
+func (b *Binary) Eval(l types.Listing, tags []string) bool {
+	if b.Op == "AND" {
+		return b.Left.Eval(l, tags) && b.Right.Eval(l, tags)
+	}
+	return b.Left.Eval(l, tags) || b.Right.Eval(l, tags)
+}
+
+func (n *Not) Eval(l types.Listing, tags []string) bool {
+	return !n.X.Eval(l, tags)
+}
+
+func (c *Cond) Eval(l types.Listing, tags []string) bool {
+	f := fields[c.Field]
+	switch f.kind {
+	case Number:
+		v, want := f.num(l), c.Value.(float64)
+		switch c.Op {
+		case "=":
+			return v == want
+		case "!=":
+			return v != want
+		case "<":
+			return v < want
+		case "<=":
+			return v <= want
+		case ">":
+			return v > want
+		case ">=":
+			return v >= want
+		}
+	case Text:
+		eq := strings.EqualFold(strings.TrimSpace(f.text(l)), c.Value.(string))
+		return eq == (c.Op == "=")
+	case Flag:
+		eq := f.flag(l) == c.Value.(bool)
+		return eq == (c.Op == "=")
+	case Tag:
+		want := c.Value.(string)
+		has := false
+		for _, t := range tags {
+			if strings.ToLower(strings.TrimSpace(t)) == want {
+				has = true
+				break
+			}
+		}
+		return has == (c.Op == "=")
+	}
+	return false
+}
+
+// precedence orders OR below AND below NOT for parenthesization.
+func precedence(e Expr) int {
+	switch e := e.(type) {
+	case *Binary:
+		if e.Op == "OR" {
+			return 1
+		}
+		return 2
+	}
+	return 3
+}
+
+func wrap(e Expr, min int) string {
+	if precedence(e) < min {
+		return "(" + e.String() + ")"
+	}
+	return e.String()
+}
+
+func (b *Binary) String() string {
+	p := precedence(b)
+	// Both operators are associative, so a same-operator right child only
+	// needs parentheses to preserve the tree shape.
+	return wrap(b.Left, p) + " " + b.Op + " " + wrap(b.Right, p+1)
+}
+
+func (n *Not) String() string {
+	return "NOT " + wrap(n.X, 3)
+}
+
+func (c *Cond) String() string {
+	switch v := c.Value.(type) {
+	case bool:
+		if c.Op == "=" && v {
+			return c.Field
+		}
+		return c.Field + " " + c.Op + " " + strconv.FormatBool(v)
+	case float64:
+		return c.Field + " " + c.Op + " " + strconv.FormatFloat(v, 'g', -1, 64)
+	case string:
+		return c.Field + " " + c.Op + " " + strconv.Quote(v)
+	}
+	return c.Field
+}
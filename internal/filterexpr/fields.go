@@ -0,0 +1,97 @@
+package filterexpr
+
+import (
+	"strings"
+
+	"home-finder/internal/types"
+)
+
+// Kind is the type of a filterable field, which decides the operators and
+// values it accepts.
+type Kind int
+
+const (
+	Number Kind = iota // price > 500000
+	Text               // city = "Portland", case-insensitive
+	Flag               // pool, NOT fixer, view = false
+	Tag                // tag = "city view"
+)
+
+type field struct {
+	kind Kind
+	num  func(types.Listing) float64
+	text func(types.Listing) string
+	flag func(types.Listing) bool
+}
+
+// fields maps canonical field names to accessors.
+var fields = map[string]field{
+	"price":         {kind: Number, num: func(l types.Listing) float64 { return float64(l.Price) }},
+	"beds":          {kind: Number, num: func(l types.Listing) float64 { return float64(l.Beds) }},
+	"baths":         {kind: Number, num: func(l types.Listing) float64 { return l.Baths }},
+	"sqft":          {kind: Number, num: func(l types.Listing) float64 { return float64(l.Sqft) }},
+	"lot_sqft":      {kind: Number, num: func(l types.Listing) float64 { return float64(l.LotSqft) }},
+	"year_built":    {kind: Number, num: func(l types.Listing) float64 { return float64(l.YearBuilt) }},
+	"stories":       {kind: Number, num: func(l types.Listing) float64 { return float64(l.Stories) }},
+	"garage":        {kind: Number, num: func(l types.Listing) float64 { return float64(l.GarageSpaces) }},
+	"hoa":           {kind: Number, num: func(l types.Listing) float64 { return float64(l.HOAFee) }},
+	"city":          {kind: Text, text: func(l types.Listing) string { return l.City }},
+	"state":         {kind: Text, text: func(l types.Listing) string { return l.State }},
+	"zip":           {kind: Text, text: func(l types.Listing) string { return l.Zip }},
+	"property_type": {kind: Text, text: func(l types.Listing) string { return l.PropertyType }},
+	"source":        {kind: Text, text: func(l types.Listing) string { return l.Source }},
+	"pool":          {kind: Flag, flag: func(l types.Listing) bool { return l.HasPool }},
+	"waterfront":    {kind: Flag, flag: func(l types.Listing) bool { return l.HasWaterfront }},
+	"view":          {kind: Flag, flag: func(l types.Listing) bool { return l.HasView }},
+	"basement":      {kind: Flag, flag: func(l types.Listing) bool { return l.HasBasement }},
+	"fireplace":     {kind: Flag, flag: func(l types.Listing) bool { return l.HasFireplace }},
+	"adu":           {kind: Flag, flag: func(l types.Listing) bool { return l.HasADU }},
+	"rv_parking":    {kind: Flag, flag: func(l types.Listing) bool { return l.HasRVParking }},
+	"new_build":     {kind: Flag, flag: func(l types.Listing) bool { return l.IsNewBuild }},
+	"fixer":         {kind: Flag, flag: func(l types.Listing) bool { return l.IsFixer }},
+	"tag":           {kind: Tag},
+}
+
+// aliases accept the query-parameter and JSON spellings of a field.
+var aliases = map[string]string{
+	"bedrooms":       "beds",
+	"bathrooms":      "baths",
+	"lot":            "lot_sqft",
+	"lotsqft":        "lot_sqft",
+	"yearbuilt":      "year_built",
+	"garage_spaces":  "garage",
+	"garagespaces":   "garage",
+	"hoa_fee":        "hoa",
+	"hoafee":         "hoa",
+	"propertytype":   "property_type",
+	"type":           "property_type",
+	"has_pool":       "pool",
+	"haspool":        "pool",
+	"has_waterfront": "waterfront",
+	"water":          "waterfront",
+	"has_view":       "view",
+	"has_basement":   "basement",
+	"has_fireplace":  "fireplace",
+	"has_adu":        "adu",
+	"has_rv_parking": "rv_parking",
+	"rv":             "rv_parking",
+	"is_new_build":   "new_build",
+	"new":            "new_build",
+	"is_fixer":       "fixer",
+	"tags":           "tag",
+}
+
+// lookup resolves a field name or alias to its canonical name.
+func lookup(name string) (string, field, bool) {
+	name = strings.ToLower(name)
+	if canon, ok := aliases[name]; ok {
+		name = canon
+	}
+	f, ok := fields[name]
+	return name, f, ok
+}
+
+// KindOf reports the kind of a canonical field name.
+func KindOf(name string) Kind {
+	return fields[name].kind
+}
@@ -0,0 +1,193 @@
+package filterexpr
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+type tokKind int
+
+const (
+	tokEOF tokKind = iota
+	tokIdent
+	tokNumber
+	tokString
+	tokOp
+	tokAnd
+	tokOr
+	tokNot
+	tokLParen
+	tokRParen
+)
+
+type token struct {
+	kind tokKind
+	text string // identifier, operator, number literal or unquoted string
+	pos  int
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokEOF:
+		return "end of expression"
+	case tokString:
+		return strconv.Quote(t.text)
+	case tokLParen:
+		return "("
+	case tokRParen:
+		return ")"
+	}
+	return fmt.Sprintf("%q", t.text)
+}
+
+type lexer struct {
+	src string
+	pos int
+}
+
+func (l *lexer) next() (token, error) {
+	for l.pos < len(l.src) {
+		r, size := utf8.DecodeRuneInString(l.src[l.pos:])
+		if !unicode.IsSpace(r) {
+			break
+		}
+		l.pos += size
+	}
+	start := l.pos
+	if l.pos >= len(l.src) {
+		return token{kind: tokEOF, pos: start}, nil
+	}
+	rest := l.src[l.pos:]
+	for _, op := range []struct {
+		text string
+		kind tokKind
+	}{
+		{"&&", tokAnd}, {"||", tokOr}, {"!=", tokOp}, {"<>", tokOp}, {"<=", tokOp}, {">=", tokOp},
+		{"==", tokOp}, {"=", tokOp}, {":", tokOp}, {"<", tokOp}, {">", tokOp}, {"!", tokNot},
+		{"(", tokLParen}, {")", tokRParen},
+	} {
+		if strings.HasPrefix(rest, op.text) {
+			l.pos += len(op.text)
+			text := op.text
+			switch text {
+			case "==":
+				text = "="
+			case "<>":
+				text = "!="
+			}
+			return token{kind: op.kind, text: text, pos: start}, nil
+		}
+	}
+
+	c := rest[0]
+	switch {
+	case c == '"' || c == '\'':
+		return l.quoted(c)
+	case c >= '0' && c <= '9' || c == '.' || c == '-' || c == '+':
+		return l.number()
+	}
+	r, _ := utf8.DecodeRuneInString(rest)
+	if !isIdentRune(r) {
+		return token{}, &SyntaxError{Pos: start, Msg: fmt.Sprintf("unexpected character %q", r)}
+	}
+	for l.pos < len(l.src) {
+		r, size := utf8.DecodeRuneInString(l.src[l.pos:])
+		if !isIdentRune(r) {
+			break
+		}
+		l.pos += size
+	}
+	word := l.src[start:l.pos]
+	switch strings.ToUpper(word) {
+	case "AND":
+		return token{kind: tokAnd, text: "AND", pos: start}, nil
+	case "OR":
+		return token{kind: tokOr, text: "OR", pos: start}, nil
+	case "NOT":
+		return token{kind: tokNot, text: "NOT", pos: start}, nil
+	}
+	return token{kind: tokIdent, text: word, pos: start}, nil
+}
+
+func isIdentRune(r rune) bool {
+	return r == '_' || r == '-' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// number scans an optionally signed decimal with an optional exponent.
+func (l *lexer) number() (token, error) {
+	start := l.pos
+	if c := l.src[l.pos]; c == '-' || c == '+' {
+		l.pos++
+	}
+	digits := l.digits()
+	if l.pos < len(l.src) && l.src[l.pos] == '.' {
+		l.pos++
+		digits += l.digits()
+	}
+	if digits == 0 {
+		return token{}, &SyntaxError{Pos: start, Msg: "malformed number"}
+	}
+	if l.pos < len(l.src) && (l.src[l.pos] == 'e' || l.src[l.pos] == 'E') {
+		l.pos++
+		if l.pos < len(l.src) && (l.src[l.pos] == '-' || l.src[l.pos] == '+') {
+			l.pos++
+		}
+		if l.digits() == 0 {
+			return token{}, &SyntaxError{Pos: start, Msg: "malformed number"}
+		}
+	}
+	if l.pos < len(l.src) {
+		if r, _ := utf8.DecodeRuneInString(l.src[l.pos:]); isIdentRune(r) {
+			return token{}, &SyntaxError{Pos: start, Msg: "malformed number"}
+		}
+	}
+	return token{kind: tokNumber, text: l.src[start:l.pos], pos: start}, nil
+}
+
+func (l *lexer) digits() int {
+	n := 0
+	for l.pos < len(l.src) && l.src[l.pos] >= '0' && l.src[l.pos] <= '9' {
+		l.pos++
+		n++
+	}
+	return n
+}
+
+// quoted scans a '...' or "..." string. Backslash escapes the next
+// character; double-quoted strings also accept Go escapes such as \u00e9,
+// which is what Expr.String emits.
+func (l *lexer) quoted(quote byte) (token, error) {
+	start := l.pos
+	l.pos++
+	for l.pos < len(l.src) {
+		switch l.src[l.pos] {
+		case '\\':
+			l.pos += 2
+			continue
+		case quote:
+			l.pos++
+			raw := l.src[start:l.pos]
+			if quote == '"' {
+				s, err := strconv.Unquote(raw)
+				if err != nil {
+					return token{}, &SyntaxError{Pos: start, Msg: "malformed string"}
+				}
+				return token{kind: tokString, text: s, pos: start}, nil
+			}
+			var b strings.Builder
+			inner := raw[1 : len(raw)-1]
+			for i := 0; i < len(inner); i++ {
+				if inner[i] == '\\' && i+1 < len(inner) {
+					i++
+				}
+				b.WriteByte(inner[i])
+			}
+			return token{kind: tokString, text: b.String(), pos: start}, nil
+		}
+		l.pos++
+	}
+	return token{}, &SyntaxError{Pos: start, Msg: "unterminated string"}
+}
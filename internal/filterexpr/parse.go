@@ -0,0 +1,216 @@
+// Package filterexpr parses the filter= expression language of /search:
+//
+//	expr    = or
+//	or      = and { "OR" and }
+//	and     = unary { "AND" unary }
+//	unary   = "NOT" unary | primary
+//	primary = "(" expr ")" | field [ op value ]
+//	op      = "=" | ":" | "!=" | "<" | "<=" | ">" | ">="
+//
+// Keywords are case-insensitive and &&, || and ! are accepted as aliases. A
+// bare flag field such as pool means pool = true. Values are numbers, quoted
+// strings ('...' or "..."), bare words or true/false, e.g.
+//
+//	(adu OR basement) AND NOT fixer
+//	beds >= 3 AND (sqft > 2000 OR lot_sqft > 8000)
+//	tag = "city view" OR city = Portland
+package filterexpr
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Limits keep hostile input from costing more than a normal request.
+const (
+	MaxLength = 2000
+	MaxDepth  = 32
+)
+
+// SyntaxError reports where parsing failed. Pos is a byte offset into the
+// expression.
+type SyntaxError struct {
+	Pos int
+	Msg string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("filter: %s at position %d", e.Msg, e.Pos)
+}
+
+// Parse parses an expression. An empty or all-space expression yields nil.
+func Parse(src string) (Expr, error) {
+	if len(src) > MaxLength {
+		return nil, &SyntaxError{Pos: MaxLength, Msg: fmt.Sprintf("expression longer than %d bytes", MaxLength)}
+	}
+	if !utf8.ValidString(src) {
+		return nil, &SyntaxError{Pos: 0, Msg: "expression is not valid UTF-8"}
+	}
+	p := &parser{lex: lexer{src: src}}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	if p.tok.kind == tokEOF {
+		return nil, nil
+	}
+	e, err := p.or(0)
+	if err != nil {
+		return nil, err
+	}
+	if p.tok.kind != tokEOF {
+		return nil, p.errorf("unexpected %s", p.tok)
+	}
+	return e, nil
+}
+
+type parser struct {
+	lex lexer
+	tok token
+}
+
+func (p *parser) next() error {
+	t, err := p.lex.next()
+	if err != nil {
+		return err
+	}
+	p.tok = t
+	return nil
+}
+
+func (p *parser) errorf(format string, args ...any) error {
+	return &SyntaxError{Pos: p.tok.pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (p *parser) or(depth int) (Expr, error) {
+	left, err := p.and(depth)
+	if err != nil {
+		return nil, err
+	}
+	for p.tok.kind == tokOr {
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		right, err := p.and(depth)
+		if err != nil {
+			return nil, err
+		}
+		left = &Binary{Op: "OR", Left: left, Right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) and(depth int) (Expr, error) {
+	left, err := p.unary(depth)
+	if err != nil {
+		return nil, err
+	}
+	for p.tok.kind == tokAnd {
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		right, err := p.unary(depth)
+		if err != nil {
+			return nil, err
+		}
+		left = &Binary{Op: "AND", Left: left, Right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) unary(depth int) (Expr, error) {
+	if depth > MaxDepth {
+		return nil, p.errorf("expression nested deeper than %d", MaxDepth)
+	}
+	switch p.tok.kind {
+	case tokNot:
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		x, err := p.unary(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		return &Not{X: x}, nil
+	case tokLParen:
+		open := p.tok.pos
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		e, err := p.or(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		if p.tok.kind != tokRParen {
+			if p.tok.kind == tokEOF {
+				return nil, &SyntaxError{Pos: open, Msg: "unclosed parenthesis"}
+			}
+			return nil, p.errorf("expected ) but found %s", p.tok)
+		}
+		return e, p.next()
+	case tokIdent:
+		return p.cond()
+	case tokEOF:
+		return nil, p.errorf("unexpected end of expression")
+	}
+	return nil, p.errorf("expected a condition but found %s", p.tok)
+}
+
+func (p *parser) cond() (Expr, error) {
+	nameTok := p.tok
+	name, f, ok := lookup(nameTok.text)
+	if !ok {
+		return nil, p.errorf("unknown field %q", nameTok.text)
+	}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	c := &Cond{Field: name, Pos: nameTok.pos}
+	if p.tok.kind != tokOp {
+		if f.kind != Flag {
+			return nil, p.errorf("expected an operator after %s", name)
+		}
+		c.Op, c.Value = "=", true
+		return c, nil
+	}
+	opTok := p.tok
+	c.Op = opTok.text
+	if c.Op == ":" {
+		c.Op = "="
+	}
+	if f.kind != Number && c.Op != "=" && c.Op != "!=" {
+		return nil, &SyntaxError{Pos: opTok.pos, Msg: fmt.Sprintf("%s only supports = and !=", name)}
+	}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	valTok := p.tok
+	switch {
+	case valTok.kind != tokIdent && valTok.kind != tokNumber && valTok.kind != tokString:
+		if valTok.kind == tokEOF {
+			return nil, p.errorf("missing value for %s", name)
+		}
+		return nil, p.errorf("expected a value for %s but found %s", name, valTok)
+	case f.kind == Number:
+		if valTok.kind != tokNumber {
+			return nil, p.errorf("%s needs a number", name)
+		}
+		v, err := strconv.ParseFloat(valTok.text, 64)
+		if err != nil {
+			return nil, p.errorf("number %s out of range", valTok.text)
+		}
+		c.Value = v
+	case f.kind == Flag:
+		b, err := strconv.ParseBool(strings.ToLower(valTok.text))
+		if valTok.kind != tokIdent || err != nil {
+			return nil, p.errorf("%s needs true or false", name)
+		}
+		c.Value = b
+	case f.kind == Tag:
+		c.Value = strings.ToLower(strings.TrimSpace(valTok.text))
+	default:
+		c.Value = strings.TrimSpace(valTok.text)
+	}
+	return c, p.next()
+}
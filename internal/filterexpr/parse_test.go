@@ -0,0 +1,144 @@
+package filterexpr
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"home-finder/internal/types"
+)
+
+func TestParseString(t *testing.T) {
+	cases := []struct{ src, want string }{
+		{"", ""},
+		{"pool", "pool"},
+		{"has_pool = TRUE", "pool"},
+		{"!fixer", "NOT fixer"},
+		{"view = false", "view = false"},
+		{"beds >= 3", "beds >= 3"},
+		{"bedrooms: 2.5", "beds = 2.5"},
+		{"price <= 1500000", "price <= 1.5e+06"},
+		{"price > 1e6", "price > 1e+06"},
+		{"hoa != -0.5", "hoa != -0.5"},
+		{"city = Portland", `city = "Portland"`},
+		{"city = ' Lake Oswego '", `city = "Lake Oswego"`},
+		{`tags = "City View"`, `tag = "city view"`},
+		{`city = "O'Fallon" || state = 'O\'R'`, `city = "O'Fallon" OR state = "O'R"`},
+		{"pool AND view OR adu", "pool AND view OR adu"},
+		{"pool AND (view OR adu)", "pool AND (view OR adu)"},
+		{"(pool OR view) AND adu", "(pool OR view) AND adu"},
+		{"pool OR (view OR adu)", "pool OR (view OR adu)"},
+		{"NOT (pool AND view)", "NOT (pool AND view)"},
+		{"not not pool", "NOT NOT pool"},
+		{"((beds > 2))", "beds > 2"},
+	}
+	for _, c := range cases {
+		e, err := Parse(c.src)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", c.src, err)
+			continue
+		}
+		got := ""
+		if e != nil {
+			got = e.String()
+		}
+		if got != c.want {
+			t.Errorf("Parse(%q).String() = %q, want %q", c.src, got, c.want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	cases := []struct {
+		src string
+		pos int
+		msg string
+	}{
+		{"bogus > 1", 0, "unknown field"},
+		{"beds >", 6, "missing value"},
+		{"beds > many", 7, "needs a number"},
+		{"city > 'a'", 5, "only supports = and !="},
+		{"pool = maybe", 7, "true or false"},
+		{"(pool", 0, "unclosed parenthesis"},
+		{"pool)", 4, "unexpected"},
+		{"pool AND", 8, "unexpected end"},
+		{"beds > 1.2.3", 10, "unexpected"},
+		{"beds > 1e", 7, "malformed number"},
+		{"city = 'open", 7, "unterminated string"},
+		{"price > 1e999", 8, "out of range"},
+		{"beds", 4, "expected an operator"},
+		{strings.Repeat("(", MaxDepth+2) + "pool" + strings.Repeat(")", MaxDepth+2), MaxDepth + 1, "nested deeper"},
+		{strings.Repeat("x", MaxLength+1), MaxLength, "longer than"},
+		{"city = \xff", 0, "not valid UTF-8"},
+	}
+	for _, c := range cases {
+		_, err := Parse(c.src)
+		var se *SyntaxError
+		if !errors.As(err, &se) {
+			t.Errorf("Parse(%.40q) = %v, want a SyntaxError", c.src, err)
+			continue
+		}
+		if se.Pos != c.pos || !strings.Contains(se.Msg, c.msg) {
+			t.Errorf("Parse(%.40q) = %q at %d, want %q at %d", c.src, se.Msg, se.Pos, c.msg, c.pos)
+		}
+	}
+}
+
+var sampleListings = []types.Listing{
+	{},
+	{Price: 450000, Beds: 3, Baths: 2.5, City: "Portland", State: "OR", HasPool: true, Tags: []string{"City View"}},
+	{Price: 1500000, Beds: 5, Baths: 4, Sqft: 4200, City: "Lake Oswego", HasView: true, HasADU: true, IsFixer: true},
+}
+
+// checkRoundTrip parses src and, when it is valid, checks that its String
+// form parses back to the same string and evaluates the same on every
+// sample listing.
+func checkRoundTrip(t *testing.T, src string) {
+	e, err := Parse(src)
+	if err != nil || e == nil {
+		return
+	}
+	s := e.String()
+	if len(s) > MaxLength {
+		return
+	}
+	back, err := Parse(s)
+	if err != nil {
+		t.Fatalf("Parse(%q).String() = %q does not parse: %v", src, s, err)
+	}
+	if back.String() != s {
+		t.Fatalf("Parse(%q) round trips to %q, then %q", src, s, back.String())
+	}
+	for i, l := range sampleListings {
+		tags := l.Tags
+		if e.Eval(l, tags) != back.Eval(l, tags) {
+			t.Fatalf("Parse(%q) and Parse(%q) disagree on listing %d", src, s, i)
+		}
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, src := range roundTripSeeds {
+		checkRoundTrip(t, src)
+	}
+}
+
+var roundTripSeeds = []string{
+	"pool",
+	"(adu OR basement) AND NOT fixer",
+	"beds >= 3 AND (sqft > 2000 OR lot_sqft > 8000)",
+	`tag = "city view" OR city = Portland`,
+	`city = "café" AND zip != '97201'`,
+	"price < 1e6 && !(view || waterfront) && baths >= 2.5",
+	"a OR b",
+	"pool OR view AND NOT (adu OR rv) OR new",
+	"NOT NOT NOT fixer",
+	`state = 'a\\b' OR source = "x\ty"`,
+}
+
+func FuzzParse(f *testing.F) {
+	for _, src := range roundTripSeeds {
+		f.Add(src)
+	}
+	f.Fuzz(checkRoundTrip)
+}
@@ -0,0 +1,155 @@
+// Package finance estimates what a listing costs per month: principal and
+// interest, property tax, insurance, PMI and HOA, under a set of financing
+// assumptions.
+package finance
+
+import (
+	"fmt"
+	"math"
+
+	"home-finder/internal/types"
+)
+
+// Defaults are the assumptions used when neither the request nor the user
+// gives any.
+var Defaults = types.Financing{
+	DownPaymentPct:   20,
+	RatePct:          6.5,
+	TermYears:        30,
+	InsuranceRatePct: 0.35,
+	PMIRatePct:       0.5,
+}
+
+// Validate rejects assumptions no loan could have.
+func Validate(f types.Financing) error {
+	switch {
+	case f.DownPaymentPct < 0 || f.DownPaymentPct > 100:
+		return fmt.Errorf("down payment must be between 0 and 100%%")
+	case f.DownPayment < 0:
+		return fmt.Errorf("down payment must not be negative")
+	case f.RatePct < 0 || f.RatePct > 30:
+		return fmt.Errorf("rate must be between 0 and 30%%")
+	case f.TermYears < 1 || f.TermYears > 50:
+		return fmt.Errorf("term must be between 1 and 50 years")
+	case f.TaxRatePct < 0 || f.TaxRatePct > 10:
+		return fmt.Errorf("tax rate must be between 0 and 10%%")
+	case f.InsuranceRatePct < 0 || f.InsuranceRatePct > 10:
+		return fmt.Errorf("insurance rate must be between 0 and 10%%")
+	case f.PMIRatePct < 0 || f.PMIRatePct > 10:
+		return fmt.Errorf("PMI rate must be between 0 and 10%%")
+	}
+	return nil
+}
+
+// Model is a Financing reduced to the factors the monthly cost is computed
+// from. The store evaluates the same formula in SQL, so max_monthly and
+// sort=monthly_cost agree with the costs shown on listings.
+type Model struct {
+	// Borrowed is the share of the price borrowed when the down payment is
+	// a percentage; with a cash Down the loan is whatever the price exceeds
+	// it by.
+	Borrowed float64
+	Down     int
+	// Payment and PMI are monthly charges per dollar borrowed.
+	Payment float64
+	PMI     float64
+	// Insurance is the annual premium as a fraction of the price, and
+	// TaxRate the annual property tax, 0 meaning it is looked up in Taxes.
+	Insurance float64
+	TaxRate   float64
+	Taxes     TaxTable
+}
+
+// NewModel resolves f against the configured tax table.
+func NewModel(f types.Financing) Model {
+	m := Model{
+		Down:      f.DownPayment,
+		Payment:   paymentFactor(f.RatePct, f.TermYears),
+		PMI:       f.PMIRatePct / 100 / 12,
+		Insurance: f.InsuranceRatePct / 100,
+		TaxRate:   f.TaxRatePct / 100,
+		Taxes:     Taxes,
+	}
+	if m.Down <= 0 {
+		m.Borrowed = 1 - f.DownPaymentPct/100
+	}
+	return m
+}
+
+// Loan is the amount borrowed on a home at price.
+func (m Model) Loan(price int) float64 {
+	if m.Down <= 0 {
+		return float64(price) * m.Borrowed
+	}
+	if price > m.Down {
+		return float64(price - m.Down)
+	}
+	return 0
+}
+
+// PMIApplies reports whether the down payment on price is under 20%.
+func (m Model) PMIApplies(price int) bool {
+	if m.Down <= 0 {
+		return m.Borrowed > 0.8
+	}
+	return m.Down*5 < price
+}
+
+// Tax is the annual property tax rate, as a fraction, for a home in state
+// and zip.
+func (m Model) Tax(state, zip string) float64 {
+	if m.TaxRate > 0 {
+		return m.TaxRate
+	}
+	return m.Taxes.Rate(state, zip)
+}
+
+// Total is the unrounded monthly cost of l.
+func (m Model) Total(l types.Listing) float64 {
+	carry := m.Payment
+	if m.PMIApplies(l.Price) {
+		carry += m.PMI
+	}
+	return m.Loan(l.Price)*carry + float64(l.Price)*(m.Tax(l.State, l.Zip)+m.Insurance)/12 + float64(l.HOAFee)
+}
+
+// Cost breaks down l's monthly cost. Total is rounded from the unrounded
+// parts, so it can differ by a dollar from their rounded sum.
+func (m Model) Cost(l types.Listing) types.MonthlyCost {
+	loan := m.Loan(l.Price)
+	tax := m.Tax(l.State, l.Zip)
+	c := types.MonthlyCost{
+		PrincipalInterest: round(loan * m.Payment),
+		PropertyTax:       round(float64(l.Price) * tax / 12),
+		Insurance:         round(float64(l.Price) * m.Insurance / 12),
+		HOA:               l.HOAFee,
+		Total:             round(m.Total(l)),
+		DownPayment:       l.Price - round(loan),
+		LoanAmount:        round(loan),
+		TaxRatePct:        math.Round(tax*10000) / 100,
+	}
+	if m.PMIApplies(l.Price) {
+		c.PMI = round(loan * m.PMI)
+	}
+	return c
+}
+
+// Within reports whether l's rounded monthly cost is at most max.
+func (m Model) Within(l types.Listing, max int) bool {
+	return m.Total(l) < float64(max)+0.5
+}
+
+// paymentFactor is the monthly payment per dollar of a fully amortizing
+// loan.
+func paymentFactor(ratePct float64, termYears int) float64 {
+	n := float64(termYears * 12)
+	r := ratePct / 100 / 12
+	if r == 0 {
+		return 1 / n
+	}
+	return r / (1 - math.Pow(1+r, -n))
+}
+
+func round(v float64) int {
+	return int(math.Round(v))
+}
@@ -0,0 +1,128 @@
+package finance
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// TaxTable holds annual property tax rates as percentages of a home's
+// value: per state, optionally refined by zip prefix for counties or cities
+// that differ from their state, and a default for everywhere else.
+type TaxTable struct {
+	Default float64            `json:"default"`
+	States  map[string]float64 `json:"states"`
+	// Zips is keyed by zip prefix; the longest matching prefix wins over
+	// the state rate.
+	Zips map[string]float64 `json:"zips"`
+}
+
+// DefaultTaxes are statewide effective rates.
+var DefaultTaxes = TaxTable{
+	Default: 1.1,
+	States: map[string]float64{
+		"AL": 0.40, "AK": 1.04, "AZ": 0.63, "AR": 0.62, "CA": 0.75, "CO": 0.55,
+		"CT": 1.79, "DE": 0.61, "DC": 0.57, "FL": 0.86, "GA": 0.92, "HI": 0.32,
+		"ID": 0.63, "IL": 2.08, "IN": 0.84, "IA": 1.52, "KS": 1.34, "KY": 0.83,
+		"LA": 0.56, "ME": 1.24, "MD": 1.05, "MA": 1.14, "MI": 1.38, "MN": 1.11,
+		"MS": 0.79, "MO": 0.97, "MT": 0.74, "NE": 1.63, "NV": 0.59, "NH": 1.86,
+		"NJ": 2.23, "NM": 0.80, "NY": 1.62, "NC": 0.80, "ND": 0.99, "OH": 1.53,
+		"OK": 0.89, "OR": 0.93, "PA": 1.49, "RI": 1.40, "SC": 0.57, "SD": 1.24,
+		"TN": 0.67, "TX": 1.68, "UT": 0.57, "VT": 1.83, "VA": 0.82, "WA": 0.87,
+		"WV": 0.58, "WI": 1.61, "WY": 0.56,
+	},
+}
+
+// Taxes is the table NewModel uses. It is replaced at startup, before any
+// request is served, by TaxTableFromEnv; replacements must pass Validate.
+var Taxes = DefaultTaxes
+
+// Rate is the annual tax rate, as a fraction, for a home in state and zip.
+func (t TaxTable) Rate(state, zip string) float64 {
+	best := -1
+	rate := t.Default
+	for prefix, r := range t.Zips {
+		if len(prefix) > best && strings.HasPrefix(strings.TrimSpace(zip), prefix) {
+			best, rate = len(prefix), r
+		}
+	}
+	if best < 0 {
+		if r, ok := t.States[strings.ToUpper(strings.TrimSpace(state))]; ok {
+			rate = r
+		}
+	}
+	return rate / 100
+}
+
+// ZipPrefixes lists the table's zip prefixes, longest first, in the order
+// Rate prefers them.
+func (t TaxTable) ZipPrefixes() []string {
+	out := make([]string, 0, len(t.Zips))
+	for p := range t.Zips {
+		out = append(out, p)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if len(out[i]) != len(out[j]) {
+			return len(out[i]) > len(out[j])
+		}
+		return out[i] < out[j]
+	})
+	return out
+}
+
+// TaxTableFromEnv reads PROPERTY_TAX_TABLE, a JSON file shaped like
+// TaxTable whose entries override DefaultTaxes, or returns DefaultTaxes
+// when it is unset.
+func TaxTableFromEnv() (TaxTable, error) {
+	path := os.Getenv("PROPERTY_TAX_TABLE")
+	if path == "" {
+		return DefaultTaxes, nil
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return TaxTable{}, err
+	}
+	var file TaxTable
+	if err := json.Unmarshal(raw, &file); err != nil {
+		return TaxTable{}, fmt.Errorf("%s: %w", path, err)
+	}
+	t := TaxTable{Default: DefaultTaxes.Default, States: map[string]float64{}, Zips: map[string]float64{}}
+	for k, v := range DefaultTaxes.States {
+		t.States[k] = v
+	}
+	if file.Default > 0 {
+		t.Default = file.Default
+	}
+	for k, v := range file.States {
+		t.States[strings.ToUpper(strings.TrimSpace(k))] = v
+	}
+	for k, v := range file.Zips {
+		t.Zips[strings.TrimSpace(k)] = v
+	}
+	if err := t.Validate(); err != nil {
+		return TaxTable{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return t, nil
+}
+
+// Validate checks that every state is a two-letter uppercase code, every
+// zip prefix one to five digits and every rate non-negative. The store
+// writes these keys into SQL, so nothing else may get through.
+func (t TaxTable) Validate() error {
+	if t.Default < 0 {
+		return fmt.Errorf("negative default rate %g", t.Default)
+	}
+	for k, v := range t.States {
+		if len(k) != 2 || strings.Trim(k, "ABCDEFGHIJKLMNOPQRSTUVWXYZ") != "" || v < 0 {
+			return fmt.Errorf("bad state entry %q", k)
+		}
+	}
+	for k, v := range t.Zips {
+		if k == "" || len(k) > 5 || strings.Trim(k, "0123456789") != "" || v < 0 {
+			return fmt.Errorf("bad zip prefix %q", k)
+		}
+	}
+	return nil
+}
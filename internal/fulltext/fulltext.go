@@ -0,0 +1,355 @@
+// Package fulltext ranks listings against free-text queries. Text is split
+// into per-field token streams (lowercased, stop words dropped, Porter
+// stemmed) and scored with BM25, weighted by field. Quoted phrases must match
+// consecutive tokens within one field.
+//
+// The in-memory Index and the SQL store share Analyze, ParseQuery and ScoreMatch,
+// so a query ranks identically whichever backend serves it.
+package fulltext
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+
+	"home-finder/internal/types"
+)
+
+// Indexed fields, in Doc order.
+const (
+	FieldTitle = iota
+	FieldAddress
+	FieldTags
+	FieldVisionTags
+	FieldDescription
+	NumFields
+)
+
+// FieldNames are the stored names of the indexed fields.
+var FieldNames = [NumFields]string{"title", "address", "tags", "vision_tags", "description"}
+
+// Boosts weight a match in each field; a title hit counts three times a
+// description hit.
+var Boosts = [NumFields]float64{3, 1.5, 2, 1, 1}
+
+// BM25 parameters.
+const (
+	k1 = 1.2
+	b  = 0.75
+)
+
+var (
+	stopWords  = map[string]bool{}
+	apostrophe = strings.NewReplacer("’s", "", "'s", "", "'", "", "’", "")
+)
+
+func init() {
+	for _, w := range strings.Fields(`a an and are as at be but by for from has have if in into is it its
+		no not of on or our such that the their then there these they this to was will with you your`) {
+		stopWords[w] = true
+	}
+}
+
+// Tokenize lowercases s, splits it on anything but letters, digits and
+// apostrophes, drops stop words and stems what is left.
+func Tokenize(s string) []string {
+	words := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '\'' && r != '’'
+	})
+	out := make([]string, 0, len(words))
+	for _, w := range words {
+		w = apostrophe.Replace(w)
+		if w == "" || stopWords[w] || (len(w) == 1 && !unicode.IsDigit(rune(w[0]))) {
+			continue
+		}
+		if isASCIILetters(w) {
+			w = Stem(w)
+		}
+		out = append(out, w)
+	}
+	return out
+}
+
+func isASCIILetters(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < 'a' || s[i] > 'z' {
+			return false
+		}
+	}
+	return true
+}
+
+// Doc holds the token stream of each field of one listing.
+type Doc [NumFields][]string
+
+// Analyze tokenizes the searchable fields of l. City, state, zip and property
+// type ride along with the address so place and type searches keep working.
+func Analyze(l types.Listing) Doc {
+	var d Doc
+	d[FieldTitle] = Tokenize(l.Title)
+	d[FieldAddress] = Tokenize(strings.Join([]string{l.Address, l.City, l.State, l.Zip, l.PropertyType}, " "))
+	d[FieldTags] = Tokenize(strings.Join(l.Tags, " "))
+	d[FieldVisionTags] = Tokenize(strings.Join(l.VisionTags, " "))
+	d[FieldDescription] = Tokenize(l.Description)
+	return d
+}
+
+// Positions maps each term of field f to the token offsets it occurs at,
+// ascending. Its lengths are the term frequencies.
+func (d Doc) Positions(f int) map[string][]int {
+	positions := make(map[string][]int, len(d[f]))
+	for i, t := range d[f] {
+		positions[t] = append(positions[t], i)
+	}
+	return positions
+}
+
+// Match is what scoring needs of one document: the offsets of each query
+// term in each field, and the token length of every field a term occurs in.
+// The SQL store reads it from its term index instead of re-analyzing
+// listings.
+type Match struct {
+	Positions map[string]*[NumFields][]int
+	Lengths   [NumFields]int
+}
+
+// Add records that term occurs at positions in field f, which is length
+// tokens long.
+func (m *Match) Add(term string, f int, positions []int, length int) {
+	if m.Positions == nil {
+		m.Positions = map[string]*[NumFields][]int{}
+	}
+	p := m.Positions[term]
+	if p == nil {
+		p = new([NumFields][]int)
+		m.Positions[term] = p
+	}
+	p[f] = positions
+	m.Lengths[f] = length
+}
+
+func (m Match) positions(term string, f int) []int {
+	if p := m.Positions[term]; p != nil {
+		return p[f]
+	}
+	return nil
+}
+
+// Match collects where terms occur in d.
+func (d Doc) Match(terms []string) Match {
+	want := make(map[string]bool, len(terms))
+	for _, t := range terms {
+		want[t] = true
+	}
+	var m Match
+	for f := 0; f < NumFields; f++ {
+		for t, pos := range d.Positions(f) {
+			if want[t] {
+				m.Add(t, f, pos, len(d[f]))
+			}
+		}
+	}
+	return m
+}
+
+// Query is a parsed search string: loose terms plus quoted phrases. Every
+// term and every phrase must match for a listing to be returned.
+type Query struct {
+	Terms   []string
+	Phrases [][]string
+}
+
+// ParseQuery splits raw on double quotes; quoted runs become phrases and an
+// unterminated quote runs to the end. Single-token phrases become terms.
+func ParseQuery(raw string) Query {
+	var q Query
+	seen := map[string]bool{}
+	addTerm := func(t string) {
+		if !seen[t] {
+			seen[t] = true
+			q.Terms = append(q.Terms, t)
+		}
+	}
+	for i, part := range strings.Split(raw, `"`) {
+		tokens := Tokenize(part)
+		if i%2 == 1 && len(tokens) > 1 {
+			q.Phrases = append(q.Phrases, tokens)
+			continue
+		}
+		for _, t := range tokens {
+			addTerm(t)
+		}
+	}
+	return q
+}
+
+// Empty reports whether the query has nothing to match, e.g. only stop words.
+func (q Query) Empty() bool {
+	return len(q.Terms) == 0 && len(q.Phrases) == 0
+}
+
+// AllTerms lists every distinct token in the query, phrases included.
+func (q Query) AllTerms() []string {
+	seen := map[string]bool{}
+	var out []string
+	for _, t := range q.Terms {
+		if !seen[t] {
+			seen[t] = true
+			out = append(out, t)
+		}
+	}
+	for _, p := range q.Phrases {
+		for _, t := range p {
+			if !seen[t] {
+				seen[t] = true
+				out = append(out, t)
+			}
+		}
+	}
+	return out
+}
+
+// Stats are the corpus statistics BM25 needs: document count, mean field
+// lengths in tokens, and the number of documents containing each term.
+type Stats struct {
+	Docs   int
+	AvgLen [NumFields]float64
+	DF     map[string]int
+}
+
+func (s Stats) idf(term string) float64 {
+	n, df := float64(s.Docs), float64(s.DF[term])
+	return math.Log(1 + (n-df+0.5)/(df+0.5))
+}
+
+func (s Stats) weight(f, tf, length int) float64 {
+	norm := 1.0
+	if s.AvgLen[f] > 0 {
+		norm = 1 - b + b*float64(length)/s.AvgLen[f]
+	}
+	return float64(tf) * (k1 + 1) / (float64(tf) + k1*norm)
+}
+
+// Score returns the BM25 score of d for q and whether d matches at all.
+func Score(q Query, d Doc, st Stats) (float64, bool) {
+	return ScoreMatch(q, d.Match(q.AllTerms()), st)
+}
+
+// ScoreMatch is Score over a document's Match. A phrase scores as one unit
+// whose IDF is the sum of its terms'.
+func ScoreMatch(q Query, m Match, st Stats) (float64, bool) {
+	var score float64
+	for _, t := range q.Terms {
+		idf := st.idf(t)
+		found := false
+		for f := 0; f < NumFields; f++ {
+			if tf := len(m.positions(t, f)); tf > 0 {
+				found = true
+				score += Boosts[f] * idf * st.weight(f, tf, m.Lengths[f])
+			}
+		}
+		if !found {
+			return 0, false
+		}
+	}
+	for _, p := range q.Phrases {
+		var idf float64
+		for _, t := range p {
+			idf += st.idf(t)
+		}
+		found := false
+		for f := 0; f < NumFields; f++ {
+			if tf := m.phraseCount(p, f); tf > 0 {
+				found = true
+				score += Boosts[f] * idf * st.weight(f, tf, m.Lengths[f])
+			}
+		}
+		if !found {
+			return 0, false
+		}
+	}
+	return score, true
+}
+
+// phraseCount counts the offsets in field f where phrase starts.
+func (m Match) phraseCount(phrase []string, f int) int {
+	n := 0
+	for _, start := range m.positions(phrase[0], f) {
+		match := true
+		for j, t := range phrase[1:] {
+			pos := m.positions(t, f)
+			if i := sort.SearchInts(pos, start+j+1); i == len(pos) || pos[i] != start+j+1 {
+				match = false
+				break
+			}
+		}
+		if match {
+			n++
+		}
+	}
+	return n
+}
+
+// Index is an in-memory inverted index over a slice of listings. Postings
+// hold document positions in that slice.
+type Index struct {
+	docs     []Doc
+	postings map[string][]int
+	stats    Stats
+}
+
+// NewIndex analyzes listings and collects corpus statistics over all of them.
+func NewIndex(listings []types.Listing) *Index {
+	ix := &Index{
+		docs:     make([]Doc, len(listings)),
+		postings: map[string][]int{},
+		stats:    Stats{Docs: len(listings), DF: map[string]int{}},
+	}
+	var total [NumFields]int
+	for i, l := range listings {
+		d := Analyze(l)
+		ix.docs[i] = d
+		seen := map[string]bool{}
+		for f := 0; f < NumFields; f++ {
+			total[f] += len(d[f])
+			for _, t := range d[f] {
+				if !seen[t] {
+					seen[t] = true
+					ix.postings[t] = append(ix.postings[t], i)
+				}
+			}
+		}
+	}
+	for t, docs := range ix.postings {
+		ix.stats.DF[t] = len(docs)
+	}
+	if len(listings) > 0 {
+		for f := range total {
+			ix.stats.AvgLen[f] = float64(total[f]) / float64(len(listings))
+		}
+	}
+	return ix
+}
+
+// Search scores every document matching q, keyed by position. Candidates come
+// from the shortest posting list so most documents are never scored.
+func (ix *Index) Search(q Query) map[int]float64 {
+	terms := q.AllTerms()
+	if len(terms) == 0 {
+		return nil
+	}
+	shortest := ix.postings[terms[0]]
+	for _, t := range terms[1:] {
+		if p := ix.postings[t]; len(p) < len(shortest) {
+			shortest = p
+		}
+	}
+	hits := map[int]float64{}
+	for _, i := range shortest {
+		if score, ok := Score(q, ix.docs[i], ix.stats); ok {
+			hits[i] = score
+		}
+	}
+	return hits
+}
@@ -0,0 +1,209 @@
+package fulltext
+
+import (
+	"math"
+	"reflect"
+	"sort"
+	"testing"
+
+	"home-finder/internal/types"
+)
+
+func TestTokenize(t *testing.T) {
+	cases := []struct {
+		name string
+		in   string
+		want []string
+	}{
+		{"lowercase and stem", "Renovated Kitchens", []string{"renov", "kitchen"}},
+		{"stop words", "the house with a view of the lake", []string{"hous", "view", "lake"}},
+		{"only stop words", "and the of", []string{}},
+		{"punctuation splits", "wood-burning fireplace, 2-car garage!", []string{"wood", "burn", "fireplac", "2", "car", "garag"}},
+		{"possessive", "Owner's suite, owner’s bath", []string{"owner", "suit", "owner", "bath"}},
+		{"single letters", "unit b, 3 bd", []string{"unit", "3", "bd"}},
+		{"digits unstemmed", "1928 bungalows", []string{"1928", "bungalow"}},
+		{"non ascii unstemmed", "Café patios", []string{"café", "patio"}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := Tokenize(c.in); !reflect.DeepEqual(got, c.want) {
+				t.Errorf("Tokenize(%q) = %q, want %q", c.in, got, c.want)
+			}
+		})
+	}
+}
+
+func TestParseQuery(t *testing.T) {
+	cases := []struct {
+		name string
+		in   string
+		want Query
+	}{
+		{"terms", "fireplace basement", Query{Terms: []string{"fireplac", "basement"}}},
+		{"repeated term", "pool pools", Query{Terms: []string{"pool"}}},
+		{"phrase", `"wood burning" fireplace`, Query{Terms: []string{"fireplac"}, Phrases: [][]string{{"wood", "burn"}}}},
+		{"single token phrase", `"pool" house`, Query{Terms: []string{"pool", "hous"}}},
+		{"unterminated quote", `view "rv parking`, Query{Terms: []string{"view"}, Phrases: [][]string{{"rv", "park"}}}},
+		{"stop words inside phrase", `"view of the lake"`, Query{Phrases: [][]string{{"view", "lake"}}}},
+		{"empty", `the "a"`, Query{}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := ParseQuery(c.in)
+			if !reflect.DeepEqual(got, c.want) {
+				t.Errorf("ParseQuery(%q) = %+v, want %+v", c.in, got, c.want)
+			}
+			if got.Empty() != c.want.Empty() {
+				t.Errorf("Empty() = %v", got.Empty())
+			}
+		})
+	}
+}
+
+// rank searches listings for query and returns the matching IDs, best first.
+func rank(listings []types.Listing, query string) []string {
+	hits := NewIndex(listings).Search(ParseQuery(query))
+	var ids []string
+	for i := range hits {
+		ids = append(ids, listings[i].ID)
+	}
+	pos := map[string]int{}
+	for i, l := range listings {
+		pos[l.ID] = i
+	}
+	sort.Slice(ids, func(a, b int) bool { return hits[pos[ids[a]]] > hits[pos[ids[b]]] })
+	return ids
+}
+
+func TestSearch(t *testing.T) {
+	cases := []struct {
+		name     string
+		listings []types.Listing
+		query    string
+		want     []string
+	}{
+		{
+			"every term required",
+			[]types.Listing{
+				{ID: "both", Description: "fireplace and basement"},
+				{ID: "one", Description: "fireplace only"},
+			},
+			"fireplace basement", []string{"both"},
+		},
+		{
+			"higher term frequency first",
+			[]types.Listing{
+				{ID: "once", Description: "pool deck garden shed"},
+				{ID: "twice", Description: "pool deck pool shed"},
+			},
+			"pool", []string{"twice", "once"},
+		},
+		{
+			"shorter field first",
+			[]types.Listing{
+				{ID: "long", Description: "garden with mature trees, raised beds, a greenhouse and a shed"},
+				{ID: "short", Description: "garden shed"},
+			},
+			"garden", []string{"short", "long"},
+		},
+		{
+			"title beats description",
+			[]types.Listing{
+				{ID: "description", Description: "craftsman"},
+				{ID: "title", Title: "craftsman"},
+			},
+			"craftsman", []string{"title", "description"},
+		},
+		{
+			"tags beat address",
+			[]types.Listing{
+				{ID: "address", Address: "1 Garden Way"},
+				{ID: "tags", Tags: []string{"garden"}},
+			},
+			"garden", []string{"tags", "address"},
+		},
+		{
+			"phrase needs adjacent tokens",
+			[]types.Listing{
+				{ID: "adjacent", Description: "a wood-burning fireplace"},
+				{ID: "apart", Description: "wood floors and a burning bush"},
+			},
+			`"wood burning"`, []string{"adjacent"},
+		},
+		{
+			"phrase skips stop words",
+			[]types.Listing{
+				{ID: "view", Description: "a view of the lake"},
+				{ID: "reversed", Description: "lake view"},
+			},
+			`"view of the lake"`, []string{"view"},
+		},
+		{
+			"phrase stays within a field",
+			[]types.Listing{
+				{ID: "split", Title: "rooftop", Description: "deck"},
+				{ID: "whole", Description: "rooftop deck"},
+			},
+			`"rooftop deck"`, []string{"whole"},
+		},
+		{
+			"stop words only",
+			[]types.Listing{{ID: "any", Description: "the house"}},
+			"the", nil,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := rank(c.listings, c.query)
+			if len(got) == 0 && len(c.want) == 0 {
+				return
+			}
+			if !reflect.DeepEqual(got, c.want) {
+				t.Errorf("rank(%q) = %v, want %v", c.query, got, c.want)
+			}
+		})
+	}
+}
+
+func TestRarerTermRanksHigher(t *testing.T) {
+	listings := []types.Listing{
+		{ID: "common", Description: "deck"},
+		{ID: "rare", Description: "dock"},
+		{ID: "filler-1", Description: "deck"},
+		{ID: "filler-2", Description: "deck"},
+	}
+	ix := NewIndex(listings)
+	common := ix.Search(ParseQuery("deck"))[0]
+	rare := ix.Search(ParseQuery("dock"))[1]
+	if rare <= common {
+		t.Errorf("rare term scored %v, common term %v", rare, common)
+	}
+}
+
+func TestScoreMatchesStoredPositions(t *testing.T) {
+	l := types.Listing{
+		Title:       "Craftsman with a wood-burning fireplace",
+		Tags:        []string{"fireplace", "garden"},
+		Description: "Wood floors, a wood-burning fireplace and a second fireplace downstairs.",
+	}
+	st := Stats{Docs: 10, AvgLen: [NumFields]float64{4, 5, 2, 1, 12}, DF: map[string]int{"wood": 3, "burn": 2, "fireplac": 6}}
+	q := ParseQuery(`fireplace "wood burning"`)
+	d := Analyze(l)
+	want, ok := Score(q, d, st)
+	if !ok || want <= 0 {
+		t.Fatalf("Score = %v, %v", want, ok)
+	}
+
+	// Rebuild the Match the way the SQL store does, from per-field
+	// positions and lengths.
+	var m Match
+	for f := 0; f < NumFields; f++ {
+		for term, pos := range d.Positions(f) {
+			m.Add(term, f, pos, len(d[f]))
+		}
+	}
+	got, ok := ScoreMatch(q, m, st)
+	if !ok || math.Abs(got-want) > 1e-9 {
+		t.Errorf("ScoreMatch = %v, %v; want %v", got, ok, want)
+	}
+}
@@ -0,0 +1,259 @@
+package fulltext
+
+// Stem reduces an ASCII lowercase word to its Porter stem (M.F. Porter, 1980)
+// so "pools", "pooled" and "pooling" index as one term. Words of two letters
+// or fewer are returned unchanged.
+func Stem(word string) string {
+	if len(word) <= 2 {
+		return word
+	}
+	z := &stemmer{b: []byte(word), k: len(word) - 1}
+	z.step1ab()
+	if z.k > 0 {
+		z.step1c()
+		z.step2()
+		z.step3()
+		z.step4()
+		z.step5()
+	}
+	return string(z.b[:z.k+1])
+}
+
+// stemmer follows the reference implementation: b[0..k] is the word being
+// stemmed and j marks the end of the stem once ends has matched a suffix.
+type stemmer struct {
+	b    []byte
+	k, j int
+}
+
+func (z *stemmer) cons(i int) bool {
+	switch z.b[i] {
+	case 'a', 'e', 'i', 'o', 'u':
+		return false
+	case 'y':
+		if i == 0 {
+			return true
+		}
+		return !z.cons(i - 1)
+	}
+	return true
+}
+
+// m counts the vowel-consonant sequences in b[0..j].
+func (z *stemmer) m() int {
+	n, i := 0, 0
+	for {
+		if i > z.j {
+			return n
+		}
+		if !z.cons(i) {
+			break
+		}
+		i++
+	}
+	i++
+	for {
+		for {
+			if i > z.j {
+				return n
+			}
+			if z.cons(i) {
+				break
+			}
+			i++
+		}
+		i++
+		n++
+		for {
+			if i > z.j {
+				return n
+			}
+			if !z.cons(i) {
+				break
+			}
+			i++
+		}
+		i++
+	}
+}
+
+func (z *stemmer) vowelInStem() bool {
+	for i := 0; i <= z.j; i++ {
+		if !z.cons(i) {
+			return true
+		}
+	}
+	return false
+}
+
+func (z *stemmer) doublec(j int) bool {
+	if j < 1 || z.b[j] != z.b[j-1] {
+		return false
+	}
+	return z.cons(j)
+}
+
+// cvc reports a consonant-vowel-consonant ending at i where the last
+// consonant is not w, x or y, as in "hop" but not "snow".
+func (z *stemmer) cvc(i int) bool {
+	if i < 2 || !z.cons(i) || z.cons(i-1) || !z.cons(i-2) {
+		return false
+	}
+	switch z.b[i] {
+	case 'w', 'x', 'y':
+		return false
+	}
+	return true
+}
+
+func (z *stemmer) ends(s string) bool {
+	n := len(s)
+	if n > z.k+1 || string(z.b[z.k-n+1:z.k+1]) != s {
+		return false
+	}
+	z.j = z.k - n
+	return true
+}
+
+func (z *stemmer) setTo(s string) {
+	z.b = append(z.b[:z.j+1], s...)
+	z.k = z.j + len(s)
+}
+
+func (z *stemmer) r(s string) {
+	if z.m() > 0 {
+		z.setTo(s)
+	}
+}
+
+// step1ab removes plurals and -ed or -ing.
+func (z *stemmer) step1ab() {
+	if z.b[z.k] == 's' {
+		switch {
+		case z.ends("sses"):
+			z.k -= 2
+		case z.ends("ies"):
+			z.setTo("i")
+		case z.b[z.k-1] != 's':
+			z.k--
+		}
+	}
+	if z.ends("eed") {
+		if z.m() > 0 {
+			z.k--
+		}
+		return
+	}
+	if (z.ends("ed") || z.ends("ing")) && z.vowelInStem() {
+		z.k = z.j
+		switch {
+		case z.ends("at"):
+			z.setTo("ate")
+		case z.ends("bl"):
+			z.setTo("ble")
+		case z.ends("iz"):
+			z.setTo("ize")
+		case z.doublec(z.k):
+			z.k--
+			switch z.b[z.k] {
+			case 'l', 's', 'z':
+				z.k++
+			}
+		case z.m() == 1 && z.cvc(z.k):
+			z.setTo("e")
+		}
+	}
+}
+
+// step1c turns a terminal y into i when there is another vowel in the stem.
+func (z *stemmer) step1c() {
+	if z.ends("y") && z.vowelInStem() {
+		z.b[z.k] = 'i'
+	}
+}
+
+// suffixRule replaces a suffix when the remaining stem is long enough.
+type suffixRule struct{ suffix, repl string }
+
+func (z *stemmer) apply(rules []suffixRule) {
+	for _, rule := range rules {
+		if z.ends(rule.suffix) {
+			z.r(rule.repl)
+			return
+		}
+	}
+}
+
+var step2Rules = map[byte][]suffixRule{
+	'a': {{"ational", "ate"}, {"tional", "tion"}},
+	'c': {{"enci", "ence"}, {"anci", "ance"}},
+	'e': {{"izer", "ize"}},
+	'l': {{"bli", "ble"}, {"alli", "al"}, {"entli", "ent"}, {"eli", "e"}, {"ousli", "ous"}},
+	'o': {{"ization", "ize"}, {"ation", "ate"}, {"ator", "ate"}},
+	's': {{"alism", "al"}, {"iveness", "ive"}, {"fulness", "ful"}, {"ousness", "ous"}},
+	't': {{"aliti", "al"}, {"iviti", "ive"}, {"biliti", "ble"}},
+	'g': {{"logi", "log"}},
+}
+
+// step2 maps double suffixes to single ones, e.g. -ization to -ize.
+func (z *stemmer) step2() {
+	z.apply(step2Rules[z.b[z.k-1]])
+}
+
+var step3Rules = map[byte][]suffixRule{
+	'e': {{"icate", "ic"}, {"ative", ""}, {"alize", "al"}},
+	'i': {{"iciti", "ic"}},
+	'l': {{"ical", "ic"}, {"ful", ""}},
+	's': {{"ness", ""}},
+}
+
+// step3 handles -ic-, -full, -ness and similar.
+func (z *stemmer) step3() {
+	z.apply(step3Rules[z.b[z.k]])
+}
+
+var step4Suffixes = map[byte][]string{
+	'a': {"al"},
+	'c': {"ance", "ence"},
+	'e': {"er"},
+	'i': {"ic"},
+	'l': {"able", "ible"},
+	'n': {"ant", "ement", "ment", "ent"},
+	's': {"ism"},
+	't': {"ate", "iti"},
+	'u': {"ous"},
+	'v': {"ive"},
+	'z': {"ize"},
+}
+
+// step4 removes -ant, -ence and friends when the stem has m > 1.
+func (z *stemmer) step4() {
+	matched := false
+	if z.b[z.k-1] == 'o' {
+		matched = (z.ends("ion") && z.j >= 0 && (z.b[z.j] == 's' || z.b[z.j] == 't')) || z.ends("ou")
+	} else {
+		for _, s := range step4Suffixes[z.b[z.k-1]] {
+			if z.ends(s) {
+				matched = true
+				break
+			}
+		}
+	}
+	if matched && z.m() > 1 {
+		z.k = z.j
+	}
+}
+
+// step5 removes a final -e and reduces -ll when m > 1.
+func (z *stemmer) step5() {
+	z.j = z.k
+	if z.b[z.k] == 'e' {
+		a := z.m()
+		if a > 1 || a == 1 && !z.cvc(z.k-1) {
+			z.k--
+		}
+	}
+	if z.b[z.k] == 'l' && z.doublec(z.k) && z.m() > 1 {
+		z.k--
+	}
+}
@@ -0,0 +1,137 @@
+package geo
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"home-finder/internal/types"
+)
+
+// EarthRadiusMi is the mean Earth radius used for haversine distances.
+const EarthRadiusMi = 3958.8
+
+// DistanceMi returns the great-circle distance between two points in miles.
+func DistanceMi(a, b types.GeoPoint) float64 {
+	dLat := radians(b.Lat - a.Lat)
+	dLng := radians(b.Lng - a.Lng)
+	h := math.Pow(math.Sin(dLat/2), 2) + math.Cos(radians(a.Lat))*math.Cos(radians(b.Lat))*math.Pow(math.Sin(dLng/2), 2)
+	return EarthRadiusMi * 2 * math.Asin(math.Sqrt(math.Min(1, h)))
+}
+
+// Around returns a box that contains every point within radiusMi of center.
+// It is used as an index-friendly prefilter before the exact distance check.
+func Around(center types.GeoPoint, radiusMi float64) types.BBox {
+	dLat := radiusMi / EarthRadiusMi * 180 / math.Pi
+	cos := math.Cos(radians(center.Lat))
+	dLng := 180.0
+	if cos > 1e-9 {
+		dLng = math.Min(180, dLat/cos)
+	}
+	box := types.BBox{
+		MinLat: math.Max(-90, center.Lat-dLat),
+		MaxLat: math.Min(90, center.Lat+dLat),
+		MinLng: center.Lng - dLng,
+		MaxLng: center.Lng + dLng,
+	}
+	// A circle reaching a pole covers every longitude near it.
+	if dLng >= 180 || box.MinLat == -90 || box.MaxLat == 90 {
+		box.MinLng, box.MaxLng = -180, 180
+		return box
+	}
+	box.MinLng = wrapLng(box.MinLng)
+	box.MaxLng = wrapLng(box.MaxLng)
+	return box
+}
+
+// Contains reports whether p lies inside the box, honoring antimeridian wrap.
+func Contains(b types.BBox, p types.GeoPoint) bool {
+	if p.Lat < b.MinLat || p.Lat > b.MaxLat {
+		return false
+	}
+	if b.MinLng <= b.MaxLng {
+		return p.Lng >= b.MinLng && p.Lng <= b.MaxLng
+	}
+	return p.Lng >= b.MinLng || p.Lng <= b.MaxLng
+}
+
+// Point returns the listing's coordinates.
+func Point(l types.Listing) types.GeoPoint {
+	return types.GeoPoint{Lat: l.Latitude, Lng: l.Longitude}
+}
+
+// ParsePoint parses "lat,lng".
+func ParsePoint(raw string) (types.GeoPoint, error) {
+	vals, err := parseFloats(raw, 2)
+	if err != nil {
+		return types.GeoPoint{}, err
+	}
+	p := types.GeoPoint{Lat: vals[0], Lng: vals[1]}
+	if err := ValidatePoint(p); err != nil {
+		return types.GeoPoint{}, err
+	}
+	return p, nil
+}
+
+// ValidatePoint rejects coordinates outside [-90, 90] and [-180, 180].
+func ValidatePoint(p types.GeoPoint) error {
+	if !validLat(p.Lat) || !validLng(p.Lng) {
+		return fmt.Errorf("coordinates out of range")
+	}
+	return nil
+}
+
+// ParseBBox parses "minLng,minLat,maxLng,maxLat", the GeoJSON bbox order.
+func ParseBBox(raw string) (types.BBox, error) {
+	vals, err := parseFloats(raw, 4)
+	if err != nil {
+		return types.BBox{}, err
+	}
+	b := types.BBox{MinLng: vals[0], MinLat: vals[1], MaxLng: vals[2], MaxLat: vals[3]}
+	if err := ValidateBBox(b); err != nil {
+		return types.BBox{}, err
+	}
+	return b, nil
+}
+
+// ValidateBBox rejects boxes with out-of-range corners or MinLat > MaxLat.
+// MinLng > MaxLng is allowed and crosses the antimeridian.
+func ValidateBBox(b types.BBox) error {
+	if !validLng(b.MinLng) || !validLng(b.MaxLng) || !validLat(b.MinLat) || !validLat(b.MaxLat) || b.MinLat > b.MaxLat {
+		return fmt.Errorf("bbox out of range")
+	}
+	return nil
+}
+
+func parseFloats(raw string, n int) ([]float64, error) {
+	parts := strings.Split(raw, ",")
+	if len(parts) != n {
+		return nil, fmt.Errorf("expected %d comma-separated numbers", n)
+	}
+	out := make([]float64, n)
+	for i, p := range parts {
+		f, err := strconv.ParseFloat(strings.TrimSpace(p), 64)
+		if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+			return nil, fmt.Errorf("invalid number %q", p)
+		}
+		out[i] = f
+	}
+	return out, nil
+}
+
+// validLat and validLng are also false for NaN.
+func validLat(v float64) bool { return v >= -90 && v <= 90 }
+func validLng(v float64) bool { return v >= -180 && v <= 180 }
+
+func radians(deg float64) float64 { return deg * math.Pi / 180 }
+
+func wrapLng(lng float64) float64 {
+	for lng > 180 {
+		lng -= 360
+	}
+	for lng < -180 {
+		lng += 360
+	}
+	return lng
+}
@@ -0,0 +1,265 @@
+package geo
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+
+	"home-finder/internal/types"
+)
+
+// Limits that keep drawn shapes cheap to evaluate, including in SQL where
+// every edge becomes a term of the point-in-polygon expression.
+const (
+	MaxPolygonVertices = 1000
+	MaxPolygonAreaSqMi = 10000
+)
+
+// ErrInvalidPolygon wraps every polygon validation failure.
+var ErrInvalidPolygon = errors.New("invalid polygon")
+
+// ParseGeoJSON decodes a GeoJSON Polygon or MultiPolygon, bare or wrapped in a
+// Feature, and validates it.
+func ParseGeoJSON(data []byte) ([]types.Polygon, error) {
+	var obj struct {
+		Type        string          `json:"type"`
+		Coordinates json.RawMessage `json:"coordinates"`
+		Geometry    json.RawMessage `json:"geometry"`
+	}
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPolygon, err)
+	}
+	if obj.Type == "Feature" {
+		if len(obj.Geometry) == 0 {
+			return nil, fmt.Errorf("%w: feature has no geometry", ErrInvalidPolygon)
+		}
+		return ParseGeoJSON(obj.Geometry)
+	}
+
+	var raw [][][][]float64
+	switch obj.Type {
+	case "Polygon":
+		var poly [][][]float64
+		if err := json.Unmarshal(obj.Coordinates, &poly); err != nil {
+			return nil, fmt.Errorf("%w: coordinates: %v", ErrInvalidPolygon, err)
+		}
+		raw = [][][][]float64{poly}
+	case "MultiPolygon":
+		if err := json.Unmarshal(obj.Coordinates, &raw); err != nil {
+			return nil, fmt.Errorf("%w: coordinates: %v", ErrInvalidPolygon, err)
+		}
+	default:
+		return nil, fmt.Errorf("%w: type must be Polygon or MultiPolygon, got %q", ErrInvalidPolygon, obj.Type)
+	}
+
+	var out []types.Polygon
+	for pi, poly := range raw {
+		var p types.Polygon
+		for ri, ring := range poly {
+			var r types.Ring
+			for _, pos := range ring {
+				if len(pos) < 2 {
+					return nil, fmt.Errorf("%w: polygon %d ring %d: position needs [lng, lat]", ErrInvalidPolygon, pi, ri)
+				}
+				r = append(r, types.GeoPoint{Lng: pos[0], Lat: pos[1]})
+			}
+			p = append(p, r)
+		}
+		out = append(out, p)
+	}
+	if err := ValidatePolygons(out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ValidatePolygons rejects empty, unclosed, out-of-range, self-intersecting
+// and oversized shapes.
+func ValidatePolygons(polys []types.Polygon) error {
+	if len(polys) == 0 {
+		return fmt.Errorf("%w: no polygons", ErrInvalidPolygon)
+	}
+	vertices := 0
+	area := 0.0
+	for pi, poly := range polys {
+		if len(poly) == 0 {
+			return fmt.Errorf("%w: polygon %d has no rings", ErrInvalidPolygon, pi)
+		}
+		for ri, ring := range poly {
+			if len(ring) < 4 {
+				return fmt.Errorf("%w: polygon %d ring %d needs at least 4 positions", ErrInvalidPolygon, pi, ri)
+			}
+			if ring[0] != ring[len(ring)-1] {
+				return fmt.Errorf("%w: polygon %d ring %d is not closed", ErrInvalidPolygon, pi, ri)
+			}
+			for _, p := range ring {
+				if !validLat(p.Lat) || !validLng(p.Lng) {
+					return fmt.Errorf("%w: polygon %d ring %d has coordinates out of range", ErrInvalidPolygon, pi, ri)
+				}
+			}
+			if i, j, ok := selfIntersection(ring); ok {
+				return fmt.Errorf("%w: polygon %d ring %d is self-intersecting (edges %d and %d cross)", ErrInvalidPolygon, pi, ri, i, j)
+			}
+			vertices += len(ring) - 1
+		}
+		area += ringAreaSqMi(poly[0])
+		for _, hole := range poly[1:] {
+			area -= ringAreaSqMi(hole)
+		}
+	}
+	if vertices > MaxPolygonVertices {
+		return fmt.Errorf("%w: %d vertices exceeds the limit of %d", ErrInvalidPolygon, vertices, MaxPolygonVertices)
+	}
+	if area > MaxPolygonAreaSqMi {
+		return fmt.Errorf("%w: area of %.0f sq mi exceeds the limit of %d", ErrInvalidPolygon, area, MaxPolygonAreaSqMi)
+	}
+	return nil
+}
+
+// ContainsPolygons reports whether p is inside any polygon. Holes are handled
+// by the even-odd rule across all rings of a polygon.
+func ContainsPolygons(polys []types.Polygon, p types.GeoPoint) bool {
+	for _, poly := range polys {
+		crossings := 0
+		for _, ring := range poly {
+			for _, e := range Edges(ring) {
+				if e.Crosses(p) {
+					crossings++
+				}
+			}
+		}
+		if crossings%2 == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// Edge is one non-horizontal ring edge prepared for ray casting: a ray cast
+// west from a point at latitude lat with MinLat <= lat < MaxLat crosses the
+// edge when the point's longitude is less than Slope*(lat-Lat0)+Lng0.
+type Edge struct {
+	MinLat, MaxLat float64
+	Lat0, Lng0     float64
+	Slope          float64
+}
+
+// Crosses reports whether a ray from p crosses the edge. The store evaluates
+// the same arithmetic in SQL so both paths agree on boundary points.
+func (e Edge) Crosses(p types.GeoPoint) bool {
+	// The explicit conversion stops the compiler fusing this into an FMA,
+	// which SQL engines do not do.
+	return p.Lat >= e.MinLat && p.Lat < e.MaxLat && p.Lng < float64(e.Slope*(p.Lat-e.Lat0))+e.Lng0
+}
+
+// Edges returns the ray-casting edges of a closed ring, skipping horizontal
+// edges, which a horizontal ray can never cross.
+func Edges(ring types.Ring) []Edge {
+	var out []Edge
+	for i := 0; i+1 < len(ring); i++ {
+		a, b := ring[i], ring[i+1]
+		if a.Lat == b.Lat {
+			continue
+		}
+		out = append(out, Edge{
+			MinLat: math.Min(a.Lat, b.Lat),
+			MaxLat: math.Max(a.Lat, b.Lat),
+			Lat0:   a.Lat,
+			Lng0:   a.Lng,
+			Slope:  (b.Lng - a.Lng) / (b.Lat - a.Lat),
+		})
+	}
+	return out
+}
+
+// PolygonBounds is the bounding box of every outer ring.
+func PolygonBounds(polys []types.Polygon) types.BBox {
+	b := types.BBox{MinLng: 180, MinLat: 90, MaxLng: -180, MaxLat: -90}
+	for _, poly := range polys {
+		if len(poly) == 0 {
+			continue
+		}
+		for _, p := range poly[0] {
+			b.MinLng = math.Min(b.MinLng, p.Lng)
+			b.MaxLng = math.Max(b.MaxLng, p.Lng)
+			b.MinLat = math.Min(b.MinLat, p.Lat)
+			b.MaxLat = math.Max(b.MaxLat, p.Lat)
+		}
+	}
+	return b
+}
+
+// ToGeoJSON renders polygons as a GeoJSON MultiPolygon for forwarding upstream.
+func ToGeoJSON(polys []types.Polygon) []byte {
+	coords := make([][][][2]float64, len(polys))
+	for i, poly := range polys {
+		for _, ring := range poly {
+			var r [][2]float64
+			for _, p := range ring {
+				r = append(r, [2]float64{p.Lng, p.Lat})
+			}
+			coords[i] = append(coords[i], r)
+		}
+	}
+	b, _ := json.Marshal(map[string]any{"type": "MultiPolygon", "coordinates": coords})
+	return b
+}
+
+// selfIntersection finds two non-adjacent edges of a closed ring that touch.
+func selfIntersection(ring types.Ring) (int, int, bool) {
+	n := len(ring) - 1 // edges
+	for i := 0; i < n; i++ {
+		for j := i + 2; j < n; j++ {
+			if i == 0 && j == n-1 {
+				continue // first and last edges share the closing vertex
+			}
+			if segmentsIntersect(ring[i], ring[i+1], ring[j], ring[j+1]) {
+				return i, j, true
+			}
+		}
+	}
+	return 0, 0, false
+}
+
+func segmentsIntersect(p1, p2, p3, p4 types.GeoPoint) bool {
+	d1 := orientation(p3, p4, p1)
+	d2 := orientation(p3, p4, p2)
+	d3 := orientation(p1, p2, p3)
+	d4 := orientation(p1, p2, p4)
+	if ((d1 > 0 && d2 < 0) || (d1 < 0 && d2 > 0)) && ((d3 > 0 && d4 < 0) || (d3 < 0 && d4 > 0)) {
+		return true
+	}
+	return (d1 == 0 && onSegment(p3, p4, p1)) || (d2 == 0 && onSegment(p3, p4, p2)) ||
+		(d3 == 0 && onSegment(p1, p2, p3)) || (d4 == 0 && onSegment(p1, p2, p4))
+}
+
+func orientation(a, b, c types.GeoPoint) float64 {
+	return (b.Lng-a.Lng)*(c.Lat-a.Lat) - (b.Lat-a.Lat)*(c.Lng-a.Lng)
+}
+
+func onSegment(a, b, p types.GeoPoint) bool {
+	return p.Lng >= math.Min(a.Lng, b.Lng) && p.Lng <= math.Max(a.Lng, b.Lng) &&
+		p.Lat >= math.Min(a.Lat, b.Lat) && p.Lat <= math.Max(a.Lat, b.Lat)
+}
+
+// ringAreaSqMi approximates a ring's area with an equirectangular projection
+// around its mean latitude, which is plenty for a size limit.
+func ringAreaSqMi(ring types.Ring) float64 {
+	if len(ring) < 4 {
+		return 0
+	}
+	meanLat := 0.0
+	for _, p := range ring[:len(ring)-1] {
+		meanLat += p.Lat
+	}
+	meanLat /= float64(len(ring) - 1)
+	milesPerDeg := EarthRadiusMi * math.Pi / 180
+	kx := milesPerDeg * math.Cos(radians(meanLat))
+	sum := 0.0
+	for i := 0; i+1 < len(ring); i++ {
+		a, b := ring[i], ring[i+1]
+		sum += (a.Lng*kx)*(b.Lat*milesPerDeg) - (b.Lng*kx)*(a.Lat*milesPerDeg)
+	}
+	return math.Abs(sum) / 2
+}
@@ -0,0 +1,118 @@
+// Package history tracks a listing's price and status over time. The store
+// records an event whenever an upsert changes either, and the summary
+// fields on types.Listing (OriginalPrice, PriceChangePct, LastPriceDropAt,
+// DaysOnMarket) are derived from those events.
+package history
+
+import (
+	"math"
+	"time"
+
+	"home-finder/internal/types"
+)
+
+// Changes returns the events to append to prev, the listing's recorded
+// history in order, now that l has been seen. onMarket reports whether the
+// listing was still stored; one that had expired and reappears is
+// relisted.
+func Changes(prev []types.HistoryEvent, l types.Listing, onMarket bool, now time.Time) []types.HistoryEvent {
+	status := types.NormalizeStatus(l.Status)
+	ev := types.HistoryEvent{At: now, Price: l.Price, Status: status}
+	if len(prev) == 0 {
+		ev.Event = types.EventListed
+		return []types.HistoryEvent{ev}
+	}
+	last := prev[len(prev)-1]
+	ev.PriceChange = l.Price - last.Price
+	if !onMarket || (offMarket(last.Status) && !offMarket(status)) {
+		ev.Event = types.EventRelisted
+		return []types.HistoryEvent{ev}
+	}
+	var out []types.HistoryEvent
+	if status != last.Status {
+		out = append(out, types.HistoryEvent{At: now, Event: types.EventStatusChange, Price: last.Price, Status: status})
+	}
+	// A price of zero means the source stopped reporting one, not that the
+	// home became free.
+	if ev.PriceChange != 0 && l.Price > 0 {
+		ev.Event = types.EventPriceChange
+		out = append(out, ev)
+	}
+	return out
+}
+
+// Relisted reports whether events start a new marketing period.
+func Relisted(events []types.HistoryEvent) bool {
+	for _, e := range events {
+		if e.Event == types.EventRelisted {
+			return true
+		}
+	}
+	return false
+}
+
+// Summarize fills in l's OriginalPrice, PriceChangePct and LastPriceDropAt
+// from its full history, looking only at the current marketing period. An
+// OriginalPrice reported by the source is kept. Without events only
+// PriceChangePct is derived, from whatever the source reported.
+func Summarize(l *types.Listing, events []types.HistoryEvent) {
+	if len(events) == 0 {
+		l.PriceChangePct = ChangePct(l.OriginalPrice, l.Price)
+		return
+	}
+	start := 0
+	for i, e := range events {
+		if e.Event == types.EventListed || e.Event == types.EventRelisted {
+			start = i
+		}
+	}
+	l.LastPriceDropAt = nil
+	var original int
+	for _, e := range events[start:] {
+		if original == 0 {
+			original = e.Price
+		}
+		if e.Event == types.EventPriceChange && e.PriceChange < 0 {
+			at := e.At
+			l.LastPriceDropAt = &at
+		}
+	}
+	if l.OriginalPrice == 0 {
+		l.OriginalPrice = original
+	}
+	l.PriceChangePct = ChangePct(l.OriginalPrice, l.Price)
+}
+
+// ChangePct is the percentage change from original to price, rounded to two
+// decimals, or 0 when either is unknown.
+func ChangePct(original, price int) float64 {
+	if original <= 0 || price <= 0 {
+		return 0
+	}
+	return math.Round(float64(price-original)/float64(original)*10000) / 100
+}
+
+// Reduced reports whether l is asking less than its original price.
+func Reduced(l types.Listing) bool {
+	return l.OriginalPrice > 0 && l.Price > 0 && l.Price < l.OriginalPrice
+}
+
+// DaysOnMarket counts whole days from since to now.
+func DaysOnMarket(since, now time.Time) int {
+	if !now.After(since) {
+		return 0
+	}
+	return int(now.Sub(since) / (24 * time.Hour))
+}
+
+// SetDaysOnMarket recomputes l.DaysOnMarket from ListDate as of now. Without
+// a ListDate the source's own count, if any, is left alone.
+func SetDaysOnMarket(l *types.Listing, now time.Time) {
+	if l.ListDate != nil {
+		l.DaysOnMarket = DaysOnMarket(*l.ListDate, now)
+	}
+}
+
+func offMarket(status string) bool {
+	return status == types.StatusSold || status == types.StatusWithdrawn
+}
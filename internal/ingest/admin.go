@@ -0,0 +1,128 @@
+package ingest
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+
+	"home-finder/internal/store"
+	"home-finder/internal/vision"
+)
+
+// NewAdminRouter exposes the worker's manual trigger and run history, plus
+// vision cache stats and invalidation when visionCache is non-nil. Admin
+// routes require "Authorization: Bearer <token>"; with an empty token they
+// answer 403, so only /health is open.
+func NewAdminRouter(w *Worker, runs store.IngestRunLog, visionCache *vision.CachedClient, token string) http.Handler {
+	r := chi.NewRouter()
+	r.Use(middleware.RequestID)
+	r.Use(middleware.RealIP)
+	r.Use(middleware.Logger)
+	r.Use(middleware.Recoverer)
+
+	r.Get("/health", func(rw http.ResponseWriter, _ *http.Request) {
+		writeJSON(rw, http.StatusOK, map[string]string{"status": "ok"})
+	})
+
+	r.Group(func(r chi.Router) {
+		r.Use(requireToken(token))
+
+		r.Post("/admin/ingest", func(rw http.ResponseWriter, req *http.Request) {
+			var names []string
+			for _, n := range strings.Split(req.URL.Query().Get("job"), ",") {
+				if n = strings.TrimSpace(n); n != "" {
+					names = append(names, n)
+				}
+			}
+			jobs, err := w.Trigger("manual", names...)
+			switch {
+			case errors.Is(err, ErrBusy):
+				writeJSON(rw, http.StatusConflict, map[string]string{"error": err.Error()})
+				return
+			case errors.Is(err, ErrStopped):
+				writeJSON(rw, http.StatusServiceUnavailable, map[string]string{"error": err.Error()})
+				return
+			case err != nil:
+				writeJSON(rw, http.StatusBadRequest, map[string]string{"error": err.Error()})
+				return
+			}
+			started := make([]string, 0, len(jobs))
+			for _, j := range jobs {
+				started = append(started, j.Name)
+			}
+			writeJSON(rw, http.StatusAccepted, map[string]any{"status": "started", "jobs": started})
+		})
+
+		r.Get("/admin/ingest/runs", func(rw http.ResponseWriter, req *http.Request) {
+			limit, _ := strconv.Atoi(req.URL.Query().Get("limit"))
+			recent, err := runs.RecentIngestRuns(req.Context(), limit)
+			if err != nil {
+				writeJSON(rw, http.StatusInternalServerError, map[string]string{"error": "could not load ingest runs"})
+				return
+			}
+			writeJSON(rw, http.StatusOK, map[string]any{"runs": recent})
+		})
+
+		if visionCache != nil {
+			r.Get("/admin/vision/cache", func(rw http.ResponseWriter, _ *http.Request) {
+				writeJSON(rw, http.StatusOK, map[string]any{"version": visionCache.Version(), "stats": visionCache.Stats()})
+			})
+
+			// DELETE ?url= drops one photo's answers, ?hash= one image's, and
+			// ?all=1 the whole cache; the answers were paid for, so there is
+			// no default.
+			r.Delete("/admin/vision/cache", func(rw http.ResponseWriter, req *http.Request) {
+				q := req.URL.Query()
+				var n int
+				var err error
+				switch {
+				case q.Get("url") != "":
+					n, err = visionCache.Invalidate(req.Context(), q.Get("url"))
+				case q.Get("hash") != "":
+					n, err = visionCache.InvalidateHash(req.Context(), q.Get("hash"))
+				case q.Get("all") == "1":
+					n, err = visionCache.Clear(req.Context())
+				default:
+					writeJSON(rw, http.StatusBadRequest, map[string]string{"error": "give url, hash or all=1"})
+					return
+				}
+				if err != nil {
+					writeJSON(rw, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+					return
+				}
+				writeJSON(rw, http.StatusOK, map[string]int{"deleted": n})
+			})
+		}
+	})
+
+	return r
+}
+
+func requireToken(token string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if token == "" {
+				writeJSON(w, http.StatusForbidden, map[string]string{"error": "admin routes are disabled; set ADMIN_TOKEN"})
+				return
+			}
+			got := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+				writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
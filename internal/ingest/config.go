@@ -0,0 +1,172 @@
+package ingest
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"home-finder/internal/dedup"
+	"home-finder/internal/types"
+)
+
+// Job is one region pulled from the upstream on every ingest cycle.
+type Job struct {
+	Name    string
+	Filters types.SearchFilters
+}
+
+// Config controls how often the worker runs and how far it pages.
+type Config struct {
+	Jobs         []Job
+	Interval     time.Duration
+	PageSize     int
+	MaxPages     int
+	FetchTimeout time.Duration
+	// ExpireAfter removes listings not refreshed within this window after a
+	// fully successful cycle. Zero keeps everything.
+	ExpireAfter time.Duration
+	RunOnStart  bool
+	// SourcePriority ranks sources when a listing is merged with duplicates,
+	// normally the provider registry's order.
+	SourcePriority dedup.Priority
+}
+
+// ConfigFromEnv reads the INGEST_* variables:
+//
+//	INGEST_REGIONS           semicolon-separated regions: "Portland,OR;98101;TX" (default: one unfiltered job)
+//	INGEST_INTERVAL          time between cycles (default 6h)
+//	INGEST_PAGE_SIZE         results requested per page (default 50)
+//	INGEST_MAX_PAGES         page cap per region (default 20)
+//	INGEST_FETCH_TIMEOUT     per-page upstream timeout (default 90s; scrapers are slow)
+//	INGEST_EXPIRE_AFTER      drop listings unseen for this long (default 0, disabled)
+//	INGEST_RUN_ON_START      run a cycle immediately at startup (default true)
+//	INGEST_STATUSES          statuses fetched per region (default active,contingent,pending,sold)
+//	INGEST_SOLD_WITHIN_DAYS  how far back sold listings are fetched (default 365, 0 unlimited)
+func ConfigFromEnv() (Config, error) {
+	cfg := Config{
+		Interval:     6 * time.Hour,
+		PageSize:     50,
+		MaxPages:     20,
+		FetchTimeout: 90 * time.Second,
+		RunOnStart:   true,
+	}
+	var err error
+	if cfg.Interval, err = durationEnv("INGEST_INTERVAL", cfg.Interval); err != nil {
+		return cfg, err
+	}
+	if cfg.FetchTimeout, err = durationEnv("INGEST_FETCH_TIMEOUT", cfg.FetchTimeout); err != nil {
+		return cfg, err
+	}
+	if cfg.ExpireAfter, err = durationEnv("INGEST_EXPIRE_AFTER", 0); err != nil {
+		return cfg, err
+	}
+	if cfg.PageSize, err = intEnv("INGEST_PAGE_SIZE", cfg.PageSize); err != nil {
+		return cfg, err
+	}
+	if cfg.MaxPages, err = intEnv("INGEST_MAX_PAGES", cfg.MaxPages); err != nil {
+		return cfg, err
+	}
+	if v := os.Getenv("INGEST_RUN_ON_START"); v != "" {
+		if cfg.RunOnStart, err = strconv.ParseBool(v); err != nil {
+			return cfg, fmt.Errorf("INGEST_RUN_ON_START: %w", err)
+		}
+	}
+	if cfg.Interval <= 0 {
+		return cfg, fmt.Errorf("INGEST_INTERVAL must be positive")
+	}
+	if cfg.PageSize <= 0 || cfg.MaxPages <= 0 {
+		return cfg, fmt.Errorf("INGEST_PAGE_SIZE and INGEST_MAX_PAGES must be positive")
+	}
+	cfg.Jobs = ParseRegions(os.Getenv("INGEST_REGIONS"))
+	// Pending and sold listings are ingested too so history sees homes
+	// leave the market and recent sales are on hand.
+	spec := os.Getenv("INGEST_STATUSES")
+	if spec == "" {
+		spec = "active,contingent,pending,sold"
+	}
+	var statuses []string
+	for _, st := range strings.Split(spec, ",") {
+		if st = strings.TrimSpace(st); st != "" {
+			statuses = append(statuses, st)
+		}
+	}
+	soldWithin, err := intEnv("INGEST_SOLD_WITHIN_DAYS", 365)
+	if err != nil {
+		return cfg, err
+	}
+	for i := range cfg.Jobs {
+		cfg.Jobs[i].Filters.Statuses = statuses
+		cfg.Jobs[i].Filters.SoldWithinDays = soldWithin
+	}
+	return cfg, nil
+}
+
+// ParseRegions turns "Portland,OR;98101;TX" into one job per region. A bare
+// number is a zip prefix, a bare two-letter code a state, and "City,ST" a city
+// within a state. An empty spec yields a single unfiltered job.
+func ParseRegions(spec string) []Job {
+	var jobs []Job
+	for _, raw := range strings.Split(spec, ";") {
+		raw = strings.TrimSpace(raw)
+		if raw == "" {
+			continue
+		}
+		var f types.SearchFilters
+		city, state, hasState := strings.Cut(raw, ",")
+		city = strings.TrimSpace(city)
+		switch {
+		case hasState:
+			f.City = city
+			f.State = strings.ToUpper(strings.TrimSpace(state))
+		case isDigits(city):
+			f.Zip = city
+		case len(city) == 2:
+			f.State = strings.ToUpper(city)
+		default:
+			f.City = city
+		}
+		jobs = append(jobs, Job{Name: raw, Filters: f})
+	}
+	if len(jobs) == 0 {
+		jobs = append(jobs, Job{Name: "all"})
+	}
+	return jobs
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func durationEnv(key string, fallback time.Duration) (time.Duration, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", key, err)
+	}
+	return d, nil
+}
+
+func intEnv(key string, fallback int) (int, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", key, err)
+	}
+	return n, nil
+}
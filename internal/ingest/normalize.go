@@ -0,0 +1,105 @@
+package ingest
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"sort"
+	"strings"
+
+	"home-finder/internal/types"
+)
+
+// Normalize cleans an upstream listing into the unified schema. Listings with
+// neither an ID nor an address cannot be tracked across runs and are rejected.
+func Normalize(l types.Listing, source string) (types.Listing, bool) {
+	l.ID = strings.TrimSpace(l.ID)
+	l.Title = strings.TrimSpace(l.Title)
+	l.Description = strings.TrimSpace(l.Description)
+	l.Address = strings.TrimSpace(l.Address)
+	l.City = strings.TrimSpace(l.City)
+	l.State = strings.ToUpper(strings.TrimSpace(l.State))
+	l.Zip = strings.TrimSpace(l.Zip)
+	l.PropertyType = strings.TrimSpace(l.PropertyType)
+	l.Status = types.NormalizeStatus(l.Status)
+	l.PhotoURL = strings.TrimSpace(l.PhotoURL)
+	l.Photos = normalizePhotos(l.Photos)
+	switch {
+	case l.PhotoURL == "" && len(l.Photos) > 0:
+		l.PhotoURL = l.Photos[0].URL
+	case l.PhotoURL != "" && len(l.Photos) == 0:
+		// A lone hero shot is still a gallery to cache and analyze.
+		l.Photos = []types.Photo{{URL: l.PhotoURL}}
+	}
+	if l.Source == "" {
+		l.Source = source
+	}
+	if l.ID == "" {
+		if l.Address == "" {
+			return l, false
+		}
+		l.ID = syntheticID(l)
+	}
+	if l.Price < 0 {
+		l.Price = 0
+	}
+	if l.Title == "" {
+		l.Title = l.Address
+	}
+	l.Tags = dedupeTags(l.Tags)
+	l.VisionTags = dedupeTags(l.VisionTags)
+	return l, true
+}
+
+// syntheticID derives a stable ID from the source and address for upstreams
+// that do not return one.
+func syntheticID(l types.Listing) string {
+	key := strings.ToLower(strings.Join([]string{l.Source, l.Address, l.City, l.State, l.Zip}, "|"))
+	sum := sha1.Sum([]byte(key))
+	return l.Source + "-" + hex.EncodeToString(sum[:6])
+}
+
+// normalizePhotos trims the gallery, drops blank and repeated URLs and puts
+// it in display order.
+func normalizePhotos(photos []types.Photo) []types.Photo {
+	if len(photos) == 0 {
+		return photos
+	}
+	seen := make(map[string]struct{}, len(photos))
+	out := make([]types.Photo, 0, len(photos))
+	for _, p := range photos {
+		p.URL = strings.TrimSpace(p.URL)
+		p.Caption = strings.TrimSpace(p.Caption)
+		p.Room = strings.TrimSpace(p.Room)
+		if p.URL == "" {
+			continue
+		}
+		if _, ok := seen[p.URL]; ok {
+			continue
+		}
+		seen[p.URL] = struct{}{}
+		out = append(out, p)
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Order < out[j].Order })
+	return out
+}
+
+func dedupeTags(tags []string) []string {
+	if len(tags) == 0 {
+		return tags
+	}
+	seen := make(map[string]struct{}, len(tags))
+	out := make([]string, 0, len(tags))
+	for _, t := range tags {
+		t = strings.TrimSpace(t)
+		key := strings.ToLower(t)
+		if key == "" {
+			continue
+		}
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+		out = append(out, t)
+	}
+	return out
+}
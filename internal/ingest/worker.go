@@ -0,0 +1,377 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"home-finder/internal/dedup"
+	"home-finder/internal/enrich"
+	"home-finder/internal/photo"
+	"home-finder/internal/provider"
+	"home-finder/internal/store"
+	"home-finder/internal/types"
+)
+
+// ErrBusy is returned when a cycle is requested while another is still running.
+var ErrBusy = errors.New("ingest already running")
+
+// ErrStopped is returned when a manual run is requested before Run has
+// started or after its context is done.
+var ErrStopped = errors.New("ingest worker is not running")
+
+// Worker pulls each configured region from the upstream into the listing store
+// and records a run per job.
+type Worker struct {
+	cfg      Config
+	source   string
+	fetcher  provider.Pager
+	listings store.ListingRepository
+	runs     store.IngestRunLog
+	photos   *photo.Cache     // nil leaves photos uncached
+	enricher *enrich.Enricher // nil skips vision enrichment
+
+	mu sync.Mutex // held for the duration of a cycle
+
+	// life is the context Run was started with. Manual runs use it, so
+	// shutting the worker down stops them like scheduled ones.
+	lifeMu sync.Mutex
+	life   context.Context
+}
+
+func NewWorker(cfg Config, source string, fetcher provider.Pager, listings store.ListingRepository, runs store.IngestRunLog, photos *photo.Cache, enricher *enrich.Enricher) *Worker {
+	return &Worker{cfg: cfg, source: source, fetcher: fetcher, listings: listings, runs: runs, photos: photos, enricher: enricher}
+}
+
+// Run executes a cycle every Interval until ctx is cancelled, then waits for
+// any cycle still running, manual ones included, to wind down. A cycle that
+// is still running when the next tick fires is not overlapped.
+func (w *Worker) Run(ctx context.Context) {
+	w.lifeMu.Lock()
+	w.life = ctx
+	w.lifeMu.Unlock()
+	defer func() {
+		w.mu.Lock()
+		w.mu.Unlock()
+	}()
+	if w.cfg.RunOnStart {
+		w.runScheduled(ctx)
+	}
+	ticker := time.NewTicker(w.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			w.runScheduled(ctx)
+		}
+	}
+}
+
+func (w *Worker) runScheduled(ctx context.Context) {
+	if _, err := w.RunAll(ctx, "schedule"); err != nil {
+		log.Printf("scheduled ingest: %v", err)
+	}
+}
+
+// RunAll runs every configured job synchronously.
+func (w *Worker) RunAll(ctx context.Context, trigger string) ([]store.IngestRun, error) {
+	if !w.mu.TryLock() {
+		return nil, ErrBusy
+	}
+	defer w.mu.Unlock()
+	return w.runJobs(ctx, trigger, w.cfg.Jobs, true), nil
+}
+
+// Trigger starts the named jobs (all jobs when names is empty) in the
+// background under Run's context and returns once the run has been claimed.
+func (w *Worker) Trigger(trigger string, names ...string) ([]Job, error) {
+	jobs, err := w.selectJobs(names)
+	if err != nil {
+		return nil, err
+	}
+	w.lifeMu.Lock()
+	ctx := w.life
+	w.lifeMu.Unlock()
+	if ctx == nil || ctx.Err() != nil {
+		return nil, ErrStopped
+	}
+	if !w.mu.TryLock() {
+		return nil, ErrBusy
+	}
+	go func() {
+		defer w.mu.Unlock()
+		w.runJobs(ctx, trigger, jobs, len(names) == 0)
+	}()
+	return jobs, nil
+}
+
+// Jobs lists the configured jobs.
+func (w *Worker) Jobs() []Job {
+	return w.cfg.Jobs
+}
+
+func (w *Worker) selectJobs(names []string) ([]Job, error) {
+	if len(names) == 0 {
+		return w.cfg.Jobs, nil
+	}
+	byName := make(map[string]Job, len(w.cfg.Jobs))
+	for _, j := range w.cfg.Jobs {
+		byName[j.Name] = j
+	}
+	var out []Job
+	for _, n := range names {
+		j, ok := byName[n]
+		if !ok {
+			return nil, fmt.Errorf("unknown ingest job %q", n)
+		}
+		out = append(out, j)
+	}
+	return out, nil
+}
+
+// runJobs must be called with w.mu held. Expiry only happens after a complete,
+// error-free cycle so a flaky upstream cannot empty the store.
+func (w *Worker) runJobs(ctx context.Context, trigger string, jobs []Job, fullCycle bool) []store.IngestRun {
+	started := time.Now()
+	var runs []store.IngestRun
+	failed := false
+	for _, job := range jobs {
+		run := w.runJob(ctx, job, trigger)
+		if run.Error != "" {
+			failed = true
+		}
+		if err := w.runs.RecordIngestRun(ctx, run); err != nil {
+			log.Printf("record ingest run %s: %v", job.Name, err)
+		}
+		log.Printf("ingest %s: pages=%d fetched=%d upserted=%d skipped=%d err=%q",
+			job.Name, run.Pages, run.Fetched, run.Upserted, run.Skipped, run.Error)
+		runs = append(runs, run)
+	}
+	if fullCycle && !failed && w.cfg.ExpireAfter > 0 {
+		n, err := w.listings.ExpireBefore(ctx, started.Add(-w.cfg.ExpireAfter))
+		if err != nil {
+			log.Printf("expire listings: %v", err)
+		} else if n > 0 {
+			log.Printf("expired %d listings not seen in %s", n, w.cfg.ExpireAfter)
+		}
+	}
+	return runs
+}
+
+func (w *Worker) runJob(ctx context.Context, job Job, trigger string) store.IngestRun {
+	run := store.IngestRun{
+		Job:         job.Name,
+		Source:      w.source,
+		TriggeredBy: trigger,
+		StartedAt:   time.Now(),
+	}
+	seen := make(map[string]struct{})
+	var enriched enrich.Stats
+	for page := 1; page <= w.cfg.MaxPages; page++ {
+		pageCtx, cancel := context.WithTimeout(ctx, w.cfg.FetchTimeout)
+		batch, err := w.fetcher.FetchPage(pageCtx, job.Filters, page, w.cfg.PageSize)
+		cancel()
+		if err != nil {
+			run.Error = fmt.Sprintf("page %d: %v", page, err)
+			break
+		}
+		run.Pages++
+		run.Fetched += len(batch)
+
+		var fresh []types.Listing
+		for _, raw := range batch {
+			l, ok := Normalize(raw, w.source)
+			if !ok {
+				run.Skipped++
+				continue
+			}
+			if _, dup := seen[l.ID]; dup {
+				continue
+			}
+			seen[l.ID] = struct{}{}
+			fresh = append(fresh, l)
+		}
+		if len(fresh) > 0 {
+			fresh = dedup.Dedupe(fresh, w.cfg.SourcePriority)
+			for i, l := range fresh {
+				merged, err := w.mergeStored(ctx, l)
+				if err != nil {
+					log.Printf("ingest %s: duplicate lookup for %s: %v", job.Name, l.ID, err)
+					continue
+				}
+				fresh[i] = merged
+			}
+			if w.photos != nil {
+				w.cachePhotos(ctx, job.Name, fresh)
+			}
+			if w.enricher != nil {
+				stats := w.enricher.Enrich(ctx, fresh)
+				enriched.Analyzed += stats.Analyzed
+				enriched.Reused += stats.Reused
+				enriched.Failed += stats.Failed
+			}
+			if err := w.listings.Upsert(ctx, fresh...); err != nil {
+				run.Error = fmt.Sprintf("page %d: upsert: %v", page, err)
+				break
+			}
+			run.Upserted += len(fresh)
+		}
+		// A short page ends the region. So does a page with nothing new, which
+		// is what upstreams that ignore page/page_size keep returning.
+		if len(batch) < w.cfg.PageSize || len(fresh) == 0 {
+			break
+		}
+	}
+	if w.enricher != nil {
+		log.Printf("ingest %s: vision analyzed=%d reused=%d failed=%d",
+			job.Name, enriched.Analyzed, enriched.Reused, enriched.Failed)
+	}
+	run.FinishedAt = time.Now()
+	return run
+}
+
+// stockPhotoListings is how many other stored listings must show the same
+// picture before it is treated as a stock or placeholder shot.
+const stockPhotoListings = 3
+
+// photoWorkers bounds concurrent photo downloads per page.
+const photoWorkers = 4
+
+// relistPhotoMatches is how many of a listing's own photos an earlier
+// listing must share for both to count as the same home. Listings with
+// fewer photos must share all of them, and at least two.
+const relistPhotoMatches = 3
+
+// cachePhotos stores each gallery photo locally, flags pictures that already
+// appear on several other listings as stock and links listings whose photos
+// were first shown under another ID to it.
+func (w *Worker) cachePhotos(ctx context.Context, job string, listings []types.Listing) {
+	if failed := w.photos.FetchAll(ctx, listings, photoWorkers); failed > 0 {
+		log.Printf("ingest %s: %d photo(s) could not be cached", job, failed)
+	}
+	for i := range listings {
+		l := &listings[i]
+		for k := range l.Photos {
+			p := &l.Photos[k]
+			if p.DHash == "" {
+				continue
+			}
+			matches, err := w.listings.SimilarPhotos(ctx, p.DHash, photo.MaxBandDistance)
+			if err != nil {
+				log.Printf("ingest %s: similar photos for %s: %v", job, l.ID, err)
+				continue
+			}
+			others := make(map[string]bool)
+			for _, m := range matches {
+				if m.ListingID != l.ID {
+					others[m.ListingID] = true
+				}
+			}
+			p.Stock = len(others) >= stockPhotoListings
+		}
+		if err := w.detectRelist(ctx, l); err != nil {
+			log.Printf("ingest %s: relist check for %s: %v", job, l.ID, err)
+		}
+	}
+}
+
+// detectRelist sets l.RelistedFrom when an earlier listing, possibly long
+// expired, showed enough of l's photos: the same home back on the market
+// under a new ID. Stock shots say nothing about the home and are ignored.
+// The most recent such listing wins.
+func (w *Worker) detectRelist(ctx context.Context, l *types.Listing) error {
+	type candidate struct {
+		photos    map[string]bool
+		firstSeen time.Time
+	}
+	candidates := make(map[string]*candidate)
+	own := time.Now()
+	photos := make(map[string]bool)
+	for _, p := range l.Photos {
+		if p.DHash == "" || p.Stock || photos[p.DHash] {
+			continue
+		}
+		photos[p.DHash] = true
+		matches, err := w.listings.PhotoSightings(ctx, p.DHash, photo.MaxBandDistance)
+		if err != nil {
+			return err
+		}
+		for _, m := range matches {
+			if m.ListingID == l.ID {
+				if m.FirstSeen.Before(own) {
+					own = m.FirstSeen
+				}
+				continue
+			}
+			c := candidates[m.ListingID]
+			if c == nil {
+				c = &candidate{photos: make(map[string]bool), firstSeen: m.FirstSeen}
+				candidates[m.ListingID] = c
+			}
+			c.photos[p.DHash] = true
+			if m.FirstSeen.Before(c.firstSeen) {
+				c.firstSeen = m.FirstSeen
+			}
+		}
+	}
+	need := min(relistPhotoMatches, len(photos))
+	if need < 2 {
+		return nil
+	}
+	for _, alt := range l.Alternates {
+		delete(candidates, alt.ID)
+	}
+	best := ""
+	for id, c := range candidates {
+		if len(c.photos) < need || !c.firstSeen.Before(own) {
+			continue
+		}
+		if b := candidates[best]; b == nil || c.firstSeen.After(b.firstSeen) || c.firstSeen.Equal(b.firstSeen) && id < best {
+			best = id
+		}
+	}
+	if best != "" {
+		l.RelistedFrom = best
+	}
+	return nil
+}
+
+// mergeStored folds l into a stored record of the same property, if any. The
+// stored ID is kept so links stay stable, while l's fresher values win.
+func (w *Worker) mergeStored(ctx context.Context, l types.Listing) (types.Listing, error) {
+	addr := dedup.NormalizeAddress(l.Address, l.City, l.State, l.Zip)
+	if addr.Number == "" {
+		return l, nil
+	}
+	// Narrow candidates to the same location and street number, whatever
+	// their status.
+	f := types.SearchFilters{Query: addr.Number, Statuses: types.Statuses}
+	switch {
+	case addr.Zip5 != "":
+		f.Zip = addr.Zip5
+	case l.City != "":
+		f.City, f.State = l.City, l.State
+	default:
+		return l, nil
+	}
+	candidates, err := w.listings.Search(ctx, f, types.PageRequest{})
+	if err != nil {
+		return l, err
+	}
+	for _, c := range candidates.Results {
+		if c.ID == l.ID && len(c.Alternates) == 0 {
+			return l, nil
+		}
+		if c.ID == l.ID || dedup.Match(c, l) {
+			merged := dedup.Canonical([]types.Listing{l, c}, w.cfg.SourcePriority)
+			merged.ID = c.ID
+			return merged, nil
+		}
+	}
+	return l, nil
+}
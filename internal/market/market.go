@@ -0,0 +1,159 @@
+// Package market summarizes a set of listings over time: inventory, median
+// prices, days on market and the share with price cuts, per week or month.
+// Each bucket is a snapshot of the market at its end, replayed from the
+// listings' price and status history.
+package market
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"home-finder/internal/history"
+	"home-finder/internal/types"
+)
+
+// Periods a series can be bucketed by.
+const (
+	Week  = "week"
+	Month = "month"
+)
+
+// Series is one listing's history as the store recorded it.
+type Series struct {
+	ID   string
+	Sqft int
+	// ListedAt is when the listing's current marketing period began, which
+	// a source-reported list date can put before its first event.
+	ListedAt time.Time
+	Events   []types.HistoryEvent
+}
+
+// Bucket describes the active listings at the end of one period. Medians
+// are 0 when there were none.
+type Bucket struct {
+	Start              time.Time `json:"start"`
+	End                time.Time `json:"end"`
+	Inventory          int       `json:"inventory"`
+	MedianListPrice    int       `json:"medianListPrice"`
+	MedianPricePerSqft int       `json:"medianPricePerSqft"`
+	MedianDaysOnMarket int       `json:"medianDaysOnMarket"`
+	// PriceCutShare is the fraction of the inventory asking less than it
+	// was listed for.
+	PriceCutShare float64 `json:"priceCutShare"`
+}
+
+// ParsePeriod accepts week, month or their -ly forms; empty means month.
+func ParsePeriod(s string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", Month, "monthly":
+		return Month, nil
+	case Week, "weekly":
+		return Week, nil
+	}
+	return "", fmt.Errorf("period must be week or month, got %q", s)
+}
+
+// Buckets returns the n periods up to and including the one containing now,
+// oldest first, in UTC. Weeks start on Monday.
+func Buckets(period string, n int, now time.Time) []Bucket {
+	now = now.UTC()
+	y, m, d := now.Date()
+	var start time.Time
+	step := func(t time.Time, k int) time.Time { return t.AddDate(0, k, 0) }
+	if period == Week {
+		start = time.Date(y, m, d-(int(now.Weekday())+6)%7, 0, 0, 0, 0, time.UTC)
+		step = func(t time.Time, k int) time.Time { return t.AddDate(0, 0, 7*k) }
+	} else {
+		start = time.Date(y, m, 1, 0, 0, 0, 0, time.UTC)
+	}
+	out := make([]Bucket, n)
+	for i := range out {
+		s := step(start, i-n+1)
+		out[i] = Bucket{Start: s, End: step(s, 1)}
+	}
+	return out
+}
+
+// Compute fills in each bucket from series as of the bucket's end, or now
+// for the current one.
+func Compute(series []Series, buckets []Bucket, now time.Time) []Bucket {
+	out := append([]Bucket(nil), buckets...)
+	for i := range out {
+		at := out[i].End
+		if now.Before(at) {
+			at = now
+		}
+		var prices, perSqft, days []int
+		cuts := 0
+		for _, s := range series {
+			st, ok := snapshot(s, at)
+			if !ok {
+				continue
+			}
+			prices = append(prices, st.price)
+			if s.Sqft > 0 {
+				perSqft = append(perSqft, int(math.Round(float64(st.price)/float64(s.Sqft))))
+			}
+			days = append(days, history.DaysOnMarket(st.listedAt, at))
+			if st.price < st.original {
+				cuts++
+			}
+		}
+		out[i].Inventory = len(prices)
+		out[i].MedianListPrice = median(prices)
+		out[i].MedianPricePerSqft = median(perSqft)
+		out[i].MedianDaysOnMarket = median(days)
+		if len(prices) > 0 {
+			out[i].PriceCutShare = math.Round(float64(cuts)/float64(len(prices))*1000) / 1000
+		}
+	}
+	return out
+}
+
+// state is a listing as of some moment.
+type state struct {
+	price    int
+	original int
+	listedAt time.Time
+}
+
+// snapshot replays s's events up to at and reports the listing's state,
+// or false when it was not an active listing with a price at that moment.
+func snapshot(s Series, at time.Time) (state, bool) {
+	var st state
+	status := ""
+	for i, e := range s.Events {
+		if e.At.After(at) {
+			break
+		}
+		if e.Event == types.EventListed || e.Event == types.EventRelisted {
+			st.original, st.listedAt = e.Price, e.At
+			if i == 0 && !s.ListedAt.IsZero() && s.ListedAt.Before(e.At) {
+				st.listedAt = s.ListedAt
+			}
+		}
+		if e.Price > 0 {
+			st.price = e.Price
+		}
+		if st.original == 0 {
+			st.original = e.Price
+		}
+		status = e.Status
+	}
+	return st, status == types.StatusActive && st.price > 0
+}
+
+func median(vals []int) int {
+	if len(vals) == 0 {
+		return 0
+	}
+	sort.Ints(vals)
+	mid := len(vals) / 2
+	if len(vals)%2 == 1 {
+		return vals[mid]
+	}
+	return (vals[mid-1] + vals[mid]) / 2
+}
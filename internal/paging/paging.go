@@ -0,0 +1,212 @@
+package paging
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"home-finder/internal/types"
+)
+
+const (
+	DefaultLimit = 50
+	MaxLimit     = 200
+)
+
+// Missing values sort last in either direction.
+const (
+	missingAsc  = 1e300
+	missingDesc = -1e300
+)
+
+// ErrInvalidCursor is returned for cursors that are malformed or were issued
+// for a different sort order.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+var sortKeys = map[string]bool{
+	"price":          true,
+	"sqft":           true,
+	"price_per_sqft": true,
+	"year_built":     true,
+	"newest":         true,
+	"relevance":      true,
+	"distance":       true,
+	"monthly_cost":   true,
+}
+
+// Sort is a parsed sort order. Ties are always broken by ascending ID so the
+// order is total and cursors are stable.
+type Sort struct {
+	Key  string
+	Desc bool
+}
+
+// ParseSort accepts price|-price|sqft|price_per_sqft|year_built|newest|relevance|distance|monthly_cost,
+// each optionally prefixed with "-" to reverse it. newest and relevance are
+// descending by default. An empty value means relevance.
+func ParseSort(raw string) (Sort, error) {
+	raw = strings.ToLower(strings.TrimSpace(raw))
+	if raw == "" {
+		raw = "relevance"
+	}
+	key := strings.TrimPrefix(raw, "-")
+	if !sortKeys[key] {
+		return Sort{}, fmt.Errorf("unknown sort %q", raw)
+	}
+	desc := strings.HasPrefix(raw, "-")
+	if key == "newest" || key == "relevance" {
+		desc = !desc
+	}
+	return Sort{Key: key, Desc: desc}, nil
+}
+
+// String is the canonical form accepted by ParseSort.
+func (s Sort) String() string {
+	desc := s.Desc
+	if s.Key == "newest" || s.Key == "relevance" {
+		desc = !desc
+	}
+	if desc {
+		return "-" + s.Key
+	}
+	return s.Key
+}
+
+// Missing is the sentinel that places listings without a value last.
+func (s Sort) Missing() float64 {
+	if s.Desc {
+		return missingDesc
+	}
+	return missingAsc
+}
+
+// Value is the sort key of l. The store computes the same value in SQL.
+func (s Sort) Value(l types.Listing) float64 {
+	switch s.Key {
+	case "price":
+		return float64(l.Price)
+	case "sqft":
+		if l.Sqft > 0 {
+			return float64(l.Sqft)
+		}
+	case "price_per_sqft":
+		if l.Sqft > 0 {
+			return float64(l.Price) / float64(l.Sqft)
+		}
+	case "year_built":
+		if l.YearBuilt > 0 {
+			return float64(l.YearBuilt)
+		}
+	case "newest":
+		if l.ListDate != nil {
+			return float64(l.ListDate.Unix())
+		}
+		// Like the store's first-seen fallback: on the market since
+		// DaysOnMarket days ago. Counting from midnight keeps the value, and
+		// so cursors, stable through the day.
+		today := time.Now().UTC().Truncate(24 * time.Hour)
+		return float64(today.AddDate(0, 0, -l.DaysOnMarket).Unix())
+	case "relevance":
+		// Score is filled in by the search that knows the query.
+		return l.Score
+	case "distance":
+		// DistanceMi is filled in by the search that knows the near point.
+		if l.HasLocation() {
+			return l.DistanceMi
+		}
+	case "monthly_cost":
+		// MonthlyCost is filled in by the search that knows the financing.
+		if l.MonthlyCost != nil {
+			return float64(l.MonthlyCost.Total)
+		}
+	}
+	return s.Missing()
+}
+
+// Less orders two (value, id) keys.
+func (s Sort) Less(v1 float64, id1 string, v2 float64, id2 string) bool {
+	if v1 != v2 {
+		if s.Desc {
+			return v1 > v2
+		}
+		return v1 < v2
+	}
+	return id1 < id2
+}
+
+// Cursor marks the last row of a page.
+type Cursor struct {
+	Sort  string  `json:"s"`
+	Value float64 `json:"v"`
+	ID    string  `json:"id"`
+}
+
+// Encode renders the cursor as an opaque URL-safe token.
+func (c Cursor) Encode() string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// DecodeCursor parses a token from Encode. An empty token yields nil.
+func DecodeCursor(raw string, s Sort) (*Cursor, error) {
+	if raw == "" {
+		return nil, nil
+	}
+	b, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var c Cursor
+	if err := json.Unmarshal(b, &c); err != nil || c.ID == "" {
+		return nil, ErrInvalidCursor
+	}
+	if c.Sort != s.String() {
+		return nil, fmt.Errorf("%w: issued for sort %q", ErrInvalidCursor, c.Sort)
+	}
+	return &c, nil
+}
+
+// Paginate sorts listings in place and returns the window after cursor.
+func Paginate(listings []types.Listing, page types.PageRequest) (types.SearchPage, error) {
+	s, err := ParseSort(page.Sort)
+	if err != nil {
+		return types.SearchPage{}, err
+	}
+	cursor, err := DecodeCursor(page.Cursor, s)
+	if err != nil {
+		return types.SearchPage{}, err
+	}
+	return PaginateBy(listings, s, page.Limit, cursor, s.Value), nil
+}
+
+// PaginateBy is Paginate with a caller-supplied sort value, for keys that
+// depend on request context such as relevance scores or distance.
+func PaginateBy(listings []types.Listing, s Sort, limit int, cursor *Cursor, value func(types.Listing) float64) types.SearchPage {
+	values := make(map[string]float64, len(listings))
+	for _, l := range listings {
+		values[l.ID] = value(l)
+	}
+	sort.SliceStable(listings, func(i, j int) bool {
+		return s.Less(values[listings[i].ID], listings[i].ID, values[listings[j].ID], listings[j].ID)
+	})
+
+	out := types.SearchPage{Total: len(listings)}
+	start := 0
+	if cursor != nil {
+		start = sort.Search(len(listings), func(i int) bool {
+			return s.Less(cursor.Value, cursor.ID, values[listings[i].ID], listings[i].ID)
+		})
+	}
+	end := len(listings)
+	if limit > 0 && start+limit < end {
+		end = start + limit
+		last := listings[end-1]
+		out.NextCursor = Cursor{Sort: s.String(), Value: values[last.ID], ID: last.ID}.Encode()
+	}
+	out.Results = listings[start:end]
+	return out
+}
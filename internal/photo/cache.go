@@ -0,0 +1,384 @@
+// Package photo keeps a local, content-addressed copy of listing photos. Each
+// image is downloaded once, stored under the SHA-256 of its bytes with a
+// perceptual hash for spotting the same picture elsewhere, and served back
+// as resized thumbnails so clients need not hot-link upstream CDNs.
+package photo
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif" // register decoders for image.Decode
+	"image/jpeg"
+	_ "image/png"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"home-finder/internal/types"
+)
+
+const (
+	defaultMaxBytes  = 10 << 20
+	defaultMaxPixels = 50_000_000
+	thumbQuality     = 80
+)
+
+// contentTypes are the raster formats the cache accepts, keyed by the type
+// http.DetectContentType sniffs. Anything else, notably SVG, which can carry
+// script, is refused. WebP is stored and served but not decoded.
+var contentTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/gif":  true,
+	"image/webp": true,
+}
+
+// Widths are the thumbnail widths served; requests snap up to the nearest.
+var Widths = []int{160, 320, 640, 1280}
+
+// ErrNotFound is returned for hashes that are not in the cache.
+var ErrNotFound = errors.New("photo not found")
+
+var errTooManyPixels = errors.New("image has too many pixels")
+
+// Info describes a cached photo. Width, Height and DHash are zero for
+// formats the standard library cannot decode. ContentType is sniffed from
+// the bytes, not taken from upstream.
+type Info struct {
+	Hash        string    `json:"hash"`
+	URL         string    `json:"url"` // where it was first fetched from
+	ContentType string    `json:"contentType"`
+	Size        int       `json:"size"`
+	Width       int       `json:"width,omitempty"`
+	Height      int       `json:"height,omitempty"`
+	DHash       string    `json:"dhash,omitempty"`
+	FetchedAt   time.Time `json:"fetchedAt"`
+}
+
+// Cache stores photos on disk under Dir:
+//
+//	blobs/ab/<sha256>        original bytes
+//	blobs/ab/<sha256>.json   Info
+//	urls/<sha256 of URL>     hash the URL resolved to
+//	thumbs/ab/<sha256>-<w>   JPEG thumbnails
+//
+// Files are written via rename, so the API and worker can share a
+// directory.
+type Cache struct {
+	Dir      string
+	HTTP     *http.Client
+	MaxBytes int64
+	// MaxPixels bounds width*height of images that are decoded, since a
+	// small compressed file can expand to gigabytes of pixels.
+	MaxPixels int
+}
+
+func NewCache(dir string) (*Cache, error) {
+	for _, sub := range []string{"blobs", "urls", "thumbs"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0o755); err != nil {
+			return nil, err
+		}
+	}
+	return &Cache{Dir: dir, HTTP: &http.Client{Timeout: 30 * time.Second}, MaxBytes: defaultMaxBytes, MaxPixels: defaultMaxPixels}, nil
+}
+
+// CacheFromEnv opens the cache in PHOTO_CACHE_DIR, or returns nil when it
+// is unset.
+func CacheFromEnv() (*Cache, error) {
+	dir := os.Getenv("PHOTO_CACHE_DIR")
+	if dir == "" {
+		return nil, nil
+	}
+	return NewCache(dir)
+}
+
+// Fetch returns the cached photo for rawURL, downloading it on first use.
+func (c *Cache) Fetch(ctx context.Context, rawURL string) (Info, error) {
+	if hash, err := os.ReadFile(c.urlPath(rawURL)); err == nil {
+		if info, err := c.Info(string(hash)); err == nil {
+			return info, nil
+		}
+	}
+	data, contentType, err := c.download(ctx, rawURL)
+	if err != nil {
+		return Info{}, err
+	}
+	info, err := c.put(data, contentType, rawURL)
+	if err != nil {
+		return Info{}, err
+	}
+	if err := writeFile(c.urlPath(rawURL), []byte(info.Hash)); err != nil {
+		return Info{}, err
+	}
+	return info, nil
+}
+
+// Load returns a photo's bytes and content type, fetching it if needed. It
+// lets the vision client read through the cache.
+func (c *Cache) Load(ctx context.Context, rawURL string) ([]byte, string, error) {
+	info, err := c.Fetch(ctx, rawURL)
+	if err != nil {
+		return nil, "", err
+	}
+	data, err := os.ReadFile(c.blobPath(info.Hash))
+	if err != nil {
+		return nil, "", err
+	}
+	return data, info.ContentType, nil
+}
+
+// ContentHash returns the SHA-256 of the photo at rawURL, fetching it if
+// needed.
+func (c *Cache) ContentHash(ctx context.Context, rawURL string) (string, error) {
+	info, err := c.Fetch(ctx, rawURL)
+	return info.Hash, err
+}
+
+// Info looks up a cached photo by hash.
+func (c *Cache) Info(hash string) (Info, error) {
+	if !validHash(hash) {
+		return Info{}, ErrNotFound
+	}
+	raw, err := os.ReadFile(c.blobPath(hash) + ".json")
+	if errors.Is(err, os.ErrNotExist) {
+		return Info{}, ErrNotFound
+	}
+	if err != nil {
+		return Info{}, err
+	}
+	var info Info
+	if err := json.Unmarshal(raw, &info); err != nil {
+		return Info{}, fmt.Errorf("photo %s: %w", hash, err)
+	}
+	return info, nil
+}
+
+// Thumbnail returns the path and content type of hash resized to width,
+// which should be one of Widths. The original is returned when width is
+// zero, at least the photo's own width, or the format cannot be decoded.
+// Photos cached before content types were checked are ErrNotFound unless
+// they sniff as an accepted format.
+func (c *Cache) Thumbnail(hash string, width int) (string, string, error) {
+	info, err := c.Info(hash)
+	if err != nil {
+		return "", "", err
+	}
+	if !contentTypes[info.ContentType] {
+		head := make([]byte, 512)
+		f, err := os.Open(c.blobPath(hash))
+		if err != nil {
+			return "", "", err
+		}
+		n, _ := io.ReadFull(f, head)
+		f.Close()
+		if info.ContentType = http.DetectContentType(head[:n]); !contentTypes[info.ContentType] {
+			return "", "", ErrNotFound
+		}
+	}
+	if width <= 0 || info.Width == 0 || width >= info.Width {
+		return c.blobPath(hash), info.ContentType, nil
+	}
+	path := filepath.Join(c.Dir, "thumbs", hash[:2], fmt.Sprintf("%s-%d", hash, width))
+	if _, err := os.Stat(path); err == nil {
+		return path, "image/jpeg", nil
+	}
+	f, err := os.Open(c.blobPath(hash))
+	if err != nil {
+		return "", "", err
+	}
+	defer f.Close()
+	img, err := c.decode(f)
+	if err != nil {
+		return "", "", fmt.Errorf("decode photo %s: %w", hash, err)
+	}
+	height := max(1, info.Height*width/info.Width)
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, resize(img, width, height), &jpeg.Options{Quality: thumbQuality}); err != nil {
+		return "", "", err
+	}
+	if err := writeFile(path, buf.Bytes()); err != nil {
+		return "", "", err
+	}
+	return path, "image/jpeg", nil
+}
+
+// SnapWidth rounds a requested width up to the nearest of Widths. Zero, or
+// anything wider than the largest, means the original.
+func SnapWidth(w int) int {
+	if w <= 0 {
+		return 0
+	}
+	for _, candidate := range Widths {
+		if w <= candidate {
+			return candidate
+		}
+	}
+	return 0
+}
+
+// FetchAll caches every gallery photo of listings with up to workers
+// downloads at a time, filling in each Photo's Hash and DHash. Photos that
+// fail keep their URL and are retried on the next call. It returns how many
+// failed.
+func (c *Cache) FetchAll(ctx context.Context, listings []types.Listing, workers int) int {
+	type job struct{ listing, photo int }
+	work := make(chan job)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	failed := 0
+	for w := 0; w < max(1, workers); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range work {
+				p := &listings[j.listing].Photos[j.photo]
+				info, err := c.Fetch(ctx, p.URL)
+				if err != nil {
+					log.Printf("photo cache %s: %v", p.URL, err)
+					mu.Lock()
+					failed++
+					mu.Unlock()
+					continue
+				}
+				p.Hash, p.DHash = info.Hash, info.DHash
+			}
+		}()
+	}
+	for i := range listings {
+		for k := range listings[i].Photos {
+			if ctx.Err() != nil {
+				break
+			}
+			work <- job{i, k}
+		}
+	}
+	close(work)
+	wg.Wait()
+	return failed
+}
+
+func (c *Cache) download(ctx context.Context, rawURL string) ([]byte, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("download %s: status %d", rawURL, resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, c.MaxBytes+1))
+	if err != nil {
+		return nil, "", err
+	}
+	if int64(len(data)) > c.MaxBytes {
+		return nil, "", fmt.Errorf("download %s: larger than %d bytes", rawURL, c.MaxBytes)
+	}
+	// The upstream Content-Type is not trusted: the photo is served from the
+	// API's origin, so only what the bytes sniff as counts.
+	contentType := http.DetectContentType(data)
+	if !contentTypes[contentType] {
+		return nil, "", fmt.Errorf("download %s: %s is not a supported image format", rawURL, contentType)
+	}
+	return data, contentType, nil
+}
+
+// decode decodes an image after checking its dimensions against MaxPixels.
+func (c *Cache) decode(r io.ReadSeeker) (image.Image, error) {
+	cfg, _, err := image.DecodeConfig(r)
+	if err != nil {
+		return nil, err
+	}
+	if limit := c.MaxPixels; limit > 0 && (cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width > limit/cfg.Height) {
+		return nil, fmt.Errorf("%w: %dx%d is over %d", errTooManyPixels, cfg.Width, cfg.Height, limit)
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	img, _, err := image.Decode(r)
+	return img, err
+}
+
+// put stores data under its hash unless it is already there.
+func (c *Cache) put(data []byte, contentType, rawURL string) (Info, error) {
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+	if info, err := c.Info(hash); err == nil {
+		return info, nil
+	}
+	info := Info{Hash: hash, URL: rawURL, ContentType: contentType, Size: len(data), FetchedAt: time.Now().UTC()}
+	// Images that do not decode, such as WebP, are kept without dimensions,
+	// thumbnails or a dHash; oversized ones are refused.
+	img, err := c.decode(bytes.NewReader(data))
+	switch {
+	case errors.Is(err, errTooManyPixels):
+		return Info{}, fmt.Errorf("photo %s: %w", rawURL, err)
+	case err == nil:
+		b := img.Bounds()
+		info.Width, info.Height = b.Dx(), b.Dy()
+		info.DHash = FormatDHash(DHash(img))
+	}
+	if err := writeFile(c.blobPath(hash), data); err != nil {
+		return Info{}, err
+	}
+	meta, err := json.Marshal(info)
+	if err != nil {
+		return Info{}, err
+	}
+	// Metadata goes last: its presence marks the blob complete.
+	if err := writeFile(c.blobPath(hash)+".json", meta); err != nil {
+		return Info{}, err
+	}
+	return info, nil
+}
+
+func (c *Cache) blobPath(hash string) string {
+	return filepath.Join(c.Dir, "blobs", hash[:2], hash)
+}
+
+func (c *Cache) urlPath(rawURL string) string {
+	sum := sha256.Sum256([]byte(rawURL))
+	return filepath.Join(c.Dir, "urls", hex.EncodeToString(sum[:]))
+}
+
+func validHash(hash string) bool {
+	if len(hash) != sha256.Size*2 {
+		return false
+	}
+	_, err := hex.DecodeString(hash)
+	return err == nil && strings.ToLower(hash) == hash
+}
+
+// writeFile writes data to path atomically, creating its directory.
+func writeFile(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,61 @@
+package photo
+
+import (
+	"fmt"
+	"image"
+	"math/bits"
+	"strconv"
+)
+
+// MaxBandDistance is the largest Hamming distance Bands can find: a 64-bit
+// hash split into four 16-bit bands differs in at most three of them when
+// at most three bits differ, so one band still matches exactly.
+const MaxBandDistance = 3
+
+// DHash is the 64-bit difference hash of img: the image is shrunk to 9x8
+// grayscale and each bit records whether a pixel is brighter than its right
+// neighbor. Re-encoded, resized or lightly edited copies of a photo land
+// within a few bits of each other.
+func DHash(img image.Image) uint64 {
+	small := resize(img, 9, 8)
+	var h uint64
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			h <<= 1
+			if luma(small, x, y) > luma(small, x+1, y) {
+				h |= 1
+			}
+		}
+	}
+	return h
+}
+
+// Distance is the Hamming distance between two hashes.
+func Distance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// Bands splits a hash into four 16-bit bands for indexed near-duplicate
+// lookups; see MaxBandDistance.
+func Bands(h uint64) [4]int {
+	return [4]int{int(h >> 48), int(h >> 32 & 0xffff), int(h >> 16 & 0xffff), int(h & 0xffff)}
+}
+
+// FormatDHash renders a hash as 16 hex digits.
+func FormatDHash(h uint64) string {
+	return fmt.Sprintf("%016x", h)
+}
+
+// ParseDHash reads a hash written by FormatDHash.
+func ParseDHash(s string) (uint64, error) {
+	if len(s) != 16 {
+		return 0, fmt.Errorf("dhash %q: want 16 hex digits", s)
+	}
+	return strconv.ParseUint(s, 16, 64)
+}
+
+func luma(img *image.RGBA, x, y int) int {
+	i := img.PixOffset(x, y)
+	p := img.Pix[i : i+3]
+	return 299*int(p[0]) + 587*int(p[1]) + 114*int(p[2])
+}
@@ -0,0 +1,53 @@
+package photo
+
+import (
+	"image"
+	"image/draw"
+)
+
+// resize scales img to w x h by averaging the source pixels under each
+// destination pixel. It is meant for shrinking; upscaling repeats pixels.
+func resize(img image.Image, w, h int) *image.RGBA {
+	b := img.Bounds()
+	src, ok := img.(*image.RGBA)
+	if !ok || b.Min != (image.Point{}) {
+		src = image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+		draw.Draw(src, src.Bounds(), img, b.Min, draw.Src)
+	}
+	sw, sh := src.Rect.Dx(), src.Rect.Dy()
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	if sw == 0 || sh == 0 {
+		return dst
+	}
+	for dy := 0; dy < h; dy++ {
+		y0, y1 := span(dy, h, sh)
+		for dx := 0; dx < w; dx++ {
+			x0, x1 := span(dx, w, sw)
+			var sum [4]int
+			for y := y0; y < y1; y++ {
+				row := src.Pix[src.PixOffset(x0, y):src.PixOffset(x1, y)]
+				for i := 0; i < len(row); i += 4 {
+					sum[0] += int(row[i])
+					sum[1] += int(row[i+1])
+					sum[2] += int(row[i+2])
+					sum[3] += int(row[i+3])
+				}
+			}
+			n := (x1 - x0) * (y1 - y0)
+			o := dst.PixOffset(dx, dy)
+			for c := 0; c < 4; c++ {
+				dst.Pix[o+c] = uint8(sum[c] / n)
+			}
+		}
+	}
+	return dst
+}
+
+// span maps destination index d of n onto the source range [lo, hi) of size.
+func span(d, n, size int) (int, int) {
+	lo, hi := d*size/n, (d+1)*size/n
+	if hi <= lo {
+		hi = lo + 1
+	}
+	return lo, hi
+}
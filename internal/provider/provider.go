@@ -0,0 +1,82 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"time"
+
+	"home-finder/internal/types"
+)
+
+// Provider is an upstream listing source that can answer searches.
+type Provider interface {
+	// Name identifies the provider in logs, registry lookups and source status.
+	Name() string
+	Search(ctx context.Context, filters types.SearchFilters) ([]types.Listing, error)
+	Capabilities() Capabilities
+}
+
+// Pager is implemented by providers that support bulk ingest.
+type Pager interface {
+	// FetchPage returns one 1-based page of results.
+	FetchPage(ctx context.Context, filters types.SearchFilters, page, pageSize int) ([]types.Listing, error)
+}
+
+// Getter is implemented by providers that can look up a single listing.
+type Getter interface {
+	// GetListing returns the listing with the given ID, or ErrNotFound when
+	// the provider does not know it.
+	GetListing(ctx context.Context, id string) (types.Listing, error)
+}
+
+// ErrNotFound is returned by Getter implementations for unknown IDs.
+var ErrNotFound = errors.New("listing not found upstream")
+
+// Capabilities describes which filters a provider applies upstream. Callers
+// still re-apply every filter locally; capabilities only inform fan-out and UI.
+type Capabilities struct {
+	Paging  bool `json:"paging"`
+	Tags    bool `json:"tags"`
+	Query   bool `json:"query"`
+	Polygon bool `json:"polygon"`
+}
+
+const defaultTimeout = 8 * time.Second
+
+// RegistryFromEnv registers every configured upstream, in priority order:
+// 1) SCRAPER_LISTINGS_BASE (unofficial scrapers like Zillow/Redfin via a self-hosted proxy)
+// 2) LISTINGS_API_BASE (official/partner API); LISTINGS_API_FORMAT=reso treats it
+// as a RESO Web API service root instead of a {"results": [...]} endpoint.
+// PROVIDER_TIMEOUT sets the default per-provider search timeout;
+// SCRAPER_LISTINGS_TIMEOUT and LISTINGS_API_TIMEOUT override it per provider.
+func RegistryFromEnv() *Registry {
+	reg := NewRegistry()
+	fallback := envDuration("PROVIDER_TIMEOUT", defaultTimeout)
+	if base := os.Getenv("SCRAPER_LISTINGS_BASE"); base != "" {
+		c := NewResultsClient("scraper", base, os.Getenv("SCRAPER_LISTINGS_KEY"))
+		reg.Register(c, envDuration("SCRAPER_LISTINGS_TIMEOUT", fallback))
+	}
+	if base := os.Getenv("LISTINGS_API_BASE"); base != "" {
+		key := os.Getenv("LISTINGS_API_KEY")
+		timeout := envDuration("LISTINGS_API_TIMEOUT", fallback)
+		if strings.EqualFold(os.Getenv("LISTINGS_API_FORMAT"), "reso") {
+			c := NewRESOClient("official", base, key)
+			if resource := os.Getenv("LISTINGS_API_RESOURCE"); resource != "" {
+				c.Resource = resource
+			}
+			reg.Register(c, timeout)
+		} else {
+			reg.Register(NewResultsClient("official", base, key), timeout)
+		}
+	}
+	return reg
+}
+
+func envDuration(key string, fallback time.Duration) time.Duration {
+	if d, err := time.ParseDuration(os.Getenv(key)); err == nil && d > 0 {
+		return d
+	}
+	return fallback
+}
@@ -0,0 +1,222 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"home-finder/internal/dedup"
+	"home-finder/internal/geo"
+	"home-finder/internal/types"
+)
+
+// SourceStatus reports how one provider fared in a fan-out search.
+type SourceStatus struct {
+	Name      string `json:"name"`
+	Status    string `json:"status"` // ok, error or timeout
+	Error     string `json:"error,omitempty"`
+	LatencyMs int64  `json:"latencyMs"`
+	Count     int    `json:"count"`
+}
+
+type registered struct {
+	provider Provider
+	timeout  time.Duration
+}
+
+// Registry holds the configured providers in priority order.
+type Registry struct {
+	mu      sync.RWMutex
+	entries []registered
+}
+
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// Register adds a provider, replacing any existing provider with the same name.
+// A non-positive timeout uses the package default.
+func (r *Registry) Register(p Provider, timeout time.Duration) {
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, e := range r.entries {
+		if e.provider.Name() == p.Name() {
+			r.entries[i] = registered{provider: p, timeout: timeout}
+			return
+		}
+	}
+	r.entries = append(r.entries, registered{provider: p, timeout: timeout})
+}
+
+// Get looks up a provider by name.
+func (r *Registry) Get(name string) (Provider, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, e := range r.entries {
+		if e.provider.Name() == name {
+			return e.provider, true
+		}
+	}
+	return nil, false
+}
+
+// Providers returns the registered providers in priority order.
+func (r *Registry) Providers() []Provider {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := make([]Provider, 0, len(r.entries))
+	for _, e := range r.entries {
+		out = append(out, e.provider)
+	}
+	return out
+}
+
+// Pager returns the named provider for bulk ingest, or the first provider
+// that supports paging when name is empty.
+func (r *Registry) Pager(name string) (Pager, string, bool) {
+	for _, p := range r.Providers() {
+		if name != "" && p.Name() != name {
+			continue
+		}
+		if pager, ok := p.(Pager); ok && p.Capabilities().Paging {
+			return pager, p.Name(), true
+		}
+	}
+	return nil, "", false
+}
+
+// Priority names the registered providers in priority order, for ranking
+// their records when duplicates are merged.
+func (r *Registry) Priority() dedup.Priority {
+	var p dedup.Priority
+	for _, pr := range r.Providers() {
+		p = append(p, pr.Name())
+	}
+	return p
+}
+
+// Len reports how many providers are registered.
+func (r *Registry) Len() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return len(r.entries)
+}
+
+// Search queries every provider concurrently, each under its own timeout, and
+// merges the results. Statuses are returned in registration order whether or
+// not the provider succeeded.
+func (r *Registry) Search(ctx context.Context, filters types.SearchFilters) ([]types.Listing, []SourceStatus) {
+	r.mu.RLock()
+	entries := append([]registered(nil), r.entries...)
+	r.mu.RUnlock()
+
+	results := make([][]types.Listing, len(entries))
+	statuses := make([]SourceStatus, len(entries))
+	var wg sync.WaitGroup
+	for i, e := range entries {
+		wg.Add(1)
+		go func(i int, e registered) {
+			defer wg.Done()
+			pctx, cancel := context.WithTimeout(ctx, e.timeout)
+			defer cancel()
+			start := time.Now()
+			listings, err := e.provider.Search(pctx, forProvider(filters, e.provider.Capabilities()))
+			st := SourceStatus{
+				Name:      e.provider.Name(),
+				Status:    "ok",
+				LatencyMs: time.Since(start).Milliseconds(),
+				Count:     len(listings),
+			}
+			switch {
+			case err != nil && errors.Is(pctx.Err(), context.DeadlineExceeded):
+				st.Status, st.Error = "timeout", err.Error()
+			case err != nil:
+				st.Status, st.Error = "error", err.Error()
+			}
+			// Keep partial results from providers that failed mid-pagination.
+			results[i] = listings
+			statuses[i] = st
+		}(i, e)
+	}
+	wg.Wait()
+	priority := make(dedup.Priority, len(entries))
+	for i, e := range entries {
+		priority[i] = e.provider.Name()
+	}
+	return Merge(priority, results...), statuses
+}
+
+// Lookup asks the providers that implement Getter for listing id, starting
+// with the one named source and then in priority order, each under its own
+// timeout. The first hit wins. It returns ErrNotFound when every provider
+// answered that it does not know the ID, along with the statuses of the
+// providers asked.
+func (r *Registry) Lookup(ctx context.Context, source, id string) (types.Listing, []SourceStatus, error) {
+	r.mu.RLock()
+	var entries []registered
+	for _, e := range r.entries {
+		if _, ok := e.provider.(Getter); !ok {
+			continue
+		}
+		if e.provider.Name() == source {
+			entries = append([]registered{e}, entries...)
+		} else {
+			entries = append(entries, e)
+		}
+	}
+	r.mu.RUnlock()
+
+	var statuses []SourceStatus
+	var lastErr error = ErrNotFound
+	for _, e := range entries {
+		pctx, cancel := context.WithTimeout(ctx, e.timeout)
+		start := time.Now()
+		l, err := e.provider.(Getter).GetListing(pctx, id)
+		st := SourceStatus{Name: e.provider.Name(), Status: "ok", LatencyMs: time.Since(start).Milliseconds()}
+		switch {
+		case err == nil:
+			st.Count = 1
+		case errors.Is(err, ErrNotFound):
+		case errors.Is(pctx.Err(), context.DeadlineExceeded):
+			st.Status, st.Error = "timeout", err.Error()
+			lastErr = err
+		default:
+			st.Status, st.Error = "error", err.Error()
+			lastErr = err
+		}
+		cancel()
+		statuses = append(statuses, st)
+		if err == nil {
+			return l, statuses, nil
+		}
+	}
+	return types.Listing{}, statuses, lastErr
+}
+
+// forProvider narrows filters to what a provider understands. A polygon is
+// replaced by its bounding box for providers that cannot take shapes; the
+// exact containment check always runs locally afterwards.
+func forProvider(filters types.SearchFilters, caps Capabilities) types.SearchFilters {
+	if len(filters.Polygons) > 0 && !caps.Polygon {
+		if filters.BBox == nil {
+			b := geo.PolygonBounds(filters.Polygons)
+			filters.BBox = &b
+		}
+		filters.Polygons = nil
+	}
+	return filters
+}
+
+// Merge concatenates result sets and collapses records of the same property,
+// across or within sources, into canonical listings ranked by p.
+func Merge(p dedup.Priority, sets ...[]types.Listing) []types.Listing {
+	var all []types.Listing
+	for _, set := range sets {
+		all = append(all, set...)
+	}
+	return dedup.Dedupe(all, p)
+}
@@ -0,0 +1,693 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"home-finder/internal/filterexpr"
+	"home-finder/internal/geo"
+	"home-finder/internal/types"
+)
+
+// RESOClient queries a RESO Web API (OData v4) feed, usually the Property
+// resource of an MLS or Bridge/Trestle style aggregator. Like ResultsClient it
+// relies on the caller's context deadline.
+type RESOClient struct {
+	Label    string
+	BaseURL  string // service root, e.g. https://api.example.com/odata
+	Token    string
+	Resource string
+	// PageSize is the $top sent on each request; MaxPages caps how many
+	// @odata.nextLink hops Search follows.
+	PageSize    int
+	MaxPages    int
+	ExpandMedia bool
+	HTTP        *http.Client
+}
+
+func NewRESOClient(label, baseURL, token string) *RESOClient {
+	return &RESOClient{
+		Label:       label,
+		BaseURL:     baseURL,
+		Token:       token,
+		Resource:    "Property",
+		PageSize:    200,
+		MaxPages:    5,
+		ExpandMedia: true,
+		HTTP:        &http.Client{},
+	}
+}
+
+// resoSelect lists the Data Dictionary fields mapped onto types.Listing.
+var resoSelect = []string{
+	"ListingKey", "ListingId", "ListPrice", "UnparsedAddress", "StreetNumber", "StreetName",
+	"StreetSuffix", "UnitNumber", "City", "StateOrProvince", "PostalCode", "BedroomsTotal",
+	"BathroomsTotalDecimal", "LivingArea", "LotSizeSquareFeet", "YearBuilt", "Stories",
+	"GarageSpaces", "PoolPrivateYN", "WaterfrontYN", "ViewYN", "FireplaceYN", "NewConstructionYN",
+	"Basement", "ParkingFeatures", "PropertyCondition", "AssociationFee", "AssociationFeeFrequency",
+	"PropertyType", "PropertySubType", "View", "PatioAndPorchFeatures", "ListingContractDate",
+	"Latitude", "Longitude", "PublicRemarks", "OriginalListPrice", "StandardStatus",
+	"PurchaseContractDate", "CloseDate", "ClosePrice",
+}
+
+// resoStatuses maps each listing status onto the StandardStatus values it
+// covers.
+var resoStatuses = map[string][]string{
+	types.StatusActive:     {"Active"},
+	types.StatusContingent: {"Active Under Contract"},
+	types.StatusPending:    {"Pending"},
+	types.StatusSold:       {"Closed"},
+	types.StatusWithdrawn:  {"Withdrawn", "Canceled", "Expired", "Hold"},
+}
+
+func (c *RESOClient) Name() string {
+	return c.Label
+}
+
+// Capabilities: tags and free text have no Data Dictionary equivalent.
+func (c *RESOClient) Capabilities() Capabilities {
+	return Capabilities{Paging: true}
+}
+
+// Search follows @odata.nextLink until the feed is exhausted or MaxPages is hit.
+// Filters the feed cannot express (tags, free text, RV parking, ADU, fixer,
+// basement) are left for the caller to apply locally.
+func (c *RESOClient) Search(ctx context.Context, filters types.SearchFilters) ([]types.Listing, error) {
+	next := c.queryURL(filters, c.PageSize, 0)
+	var out []types.Listing
+	for page := 0; next != "" && page < c.MaxPages; page++ {
+		batch, link, err := c.fetch(ctx, next)
+		if err != nil {
+			return out, err
+		}
+		out = append(out, batch...)
+		next = link
+	}
+	return out, nil
+}
+
+// FetchPage requests a single $top/$skip window. Pages are 1-based.
+func (c *RESOClient) FetchPage(ctx context.Context, filters types.SearchFilters, page, pageSize int) ([]types.Listing, error) {
+	if pageSize <= 0 {
+		pageSize = c.PageSize
+	}
+	if page < 1 {
+		page = 1
+	}
+	batch, _, err := c.fetch(ctx, c.queryURL(filters, pageSize, (page-1)*pageSize))
+	return batch, err
+}
+
+// GetListing looks up one of the listings this client produced, whose IDs
+// are "reso-" plus the ListingKey (or ListingId). Unlike Search it does not
+// restrict the status, so closed listings are still found.
+func (c *RESOClient) GetListing(ctx context.Context, id string) (types.Listing, error) {
+	key, ok := strings.CutPrefix(id, "reso-")
+	if !ok || key == "" {
+		return types.Listing{}, ErrNotFound
+	}
+	q := url.Values{}
+	q.Set("$filter", fmt.Sprintf("ListingKey eq %[1]s or ListingId eq %[1]s", odataString(key)))
+	q.Set("$top", "1")
+	batch, _, err := c.fetch(ctx, c.resourceURL(q))
+	if err != nil {
+		return types.Listing{}, err
+	}
+	if len(batch) == 0 {
+		return types.Listing{}, ErrNotFound
+	}
+	return batch[0], nil
+}
+
+func (c *RESOClient) queryURL(filters types.SearchFilters, top, skip int) string {
+	q := url.Values{}
+	if f := ODataFilter(filters); f != "" {
+		q.Set("$filter", f)
+	}
+	q.Set("$top", fmt.Sprintf("%d", top))
+	if skip > 0 {
+		q.Set("$skip", fmt.Sprintf("%d", skip))
+	}
+	q.Set("$orderby", "ListingKey")
+	return c.resourceURL(q)
+}
+
+// resourceURL adds the field selection to q and addresses it to Resource.
+func (c *RESOClient) resourceURL(q url.Values) string {
+	q.Set("$select", strings.Join(resoSelect, ","))
+	if c.ExpandMedia {
+		q.Set("$expand", "Media($select=MediaURL,Order,ShortDescription,ImageWidth,ImageHeight,ImageOf)")
+	}
+	// OData expects %20 rather than + for spaces inside $filter expressions.
+	raw := strings.ReplaceAll(q.Encode(), "+", "%20")
+	return fmt.Sprintf("%s/%s?%s", strings.TrimRight(c.BaseURL, "/"), c.Resource, raw)
+}
+
+func (c *RESOClient) fetch(ctx context.Context, rawURL string) ([]types.Listing, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	req.Header.Set("Accept", "application/json")
+	if c.Token != "" {
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.Token))
+	}
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return nil, "", fmt.Errorf("reso status %d", resp.StatusCode)
+	}
+	var payload struct {
+		Value    []resoProperty `json:"value"`
+		NextLink string         `json:"@odata.nextLink"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
+		return nil, "", err
+	}
+	out := make([]types.Listing, 0, len(payload.Value))
+	for _, p := range payload.Value {
+		out = append(out, p.toListing())
+	}
+	next := ""
+	if payload.NextLink != "" {
+		// nextLink may be relative to the request URL.
+		ref, err := url.Parse(payload.NextLink)
+		if err != nil {
+			return out, "", fmt.Errorf("reso nextLink: %w", err)
+		}
+		next = req.URL.ResolveReference(ref).String()
+	}
+	return out, next, nil
+}
+
+// ODataFilter translates the filters the RESO Data Dictionary can express into
+// an OData $filter expression. Like the store it asks for active listings
+// unless f names other statuses.
+func ODataFilter(f types.SearchFilters) string {
+	conds := []string{odataStatus(f)}
+	num := func(field string, min, max float64) {
+		if min > 0 {
+			conds = append(conds, field+" ge "+odataNumber(min))
+		}
+		if max > 0 {
+			conds = append(conds, field+" le "+odataNumber(max))
+		}
+	}
+	num("ListPrice", float64(f.MinPrice), float64(f.MaxPrice))
+	num("BedroomsTotal", float64(f.MinBeds), float64(f.MaxBeds))
+	num("BathroomsTotalDecimal", f.MinBaths, f.MaxBaths)
+	num("LivingArea", float64(f.MinSqft), float64(f.MaxSqft))
+	num("LotSizeSquareFeet", float64(f.MinLotSqft), float64(f.MaxLotSqft))
+	num("YearBuilt", float64(f.MinYearBuilt), float64(f.MaxYearBuilt))
+	num("Stories", float64(f.MinStories), 0)
+	num("GarageSpaces", float64(f.MinGarage), 0)
+	num("AssociationFee", float64(f.MinHOA), float64(f.MaxHOA))
+
+	if len(f.PropertyTypes) > 0 {
+		var ors []string
+		for _, pt := range f.PropertyTypes {
+			ors = append(ors, "PropertySubType eq "+odataString(resoSubType(pt)))
+		}
+		conds = append(conds, "("+strings.Join(ors, " or ")+")")
+	}
+	if f.City != "" {
+		conds = append(conds, "contains(tolower(City), "+odataString(strings.ToLower(f.City))+")")
+	}
+	if f.State != "" {
+		conds = append(conds, "StateOrProvince eq "+odataString(strings.ToUpper(f.State)))
+	}
+	if f.Zip != "" {
+		conds = append(conds, "startswith(PostalCode, "+odataString(f.Zip)+")")
+	}
+	// Radius searches are sent as their bounding box; the exact distance
+	// check happens locally.
+	if f.BBox != nil {
+		conds = append(conds, odataBBox(*f.BBox)...)
+	}
+	if f.Near != nil && f.RadiusMi > 0 {
+		conds = append(conds, odataBBox(geo.Around(*f.Near, f.RadiusMi))...)
+	}
+	flags := []struct {
+		on    bool
+		field string
+	}{
+		{f.RequirePool, "PoolPrivateYN"},
+		{f.RequireWater, "WaterfrontYN"},
+		{f.RequireView, "ViewYN"},
+		{f.RequireFireplace, "FireplaceYN"},
+		{f.RequireNew, "NewConstructionYN"},
+	}
+	for _, fl := range flags {
+		if fl.on {
+			conds = append(conds, fl.field+" eq true")
+		}
+	}
+	if f.PriceReduced {
+		conds = append(conds, "ListPrice lt OriginalListPrice")
+	}
+	if expr, err := filterexpr.Parse(f.Filter); err == nil && expr != nil {
+		if cond, _ := odataExpr(expr); cond != "" {
+			conds = append(conds, cond)
+		}
+	}
+	return strings.Join(conds, " and ")
+}
+
+// odataFields maps filterexpr fields onto Data Dictionary fields. exact is
+// false where toListing rounds or derives the value, so the OData condition
+// only approximates the local one.
+var odataFields = map[string]struct {
+	name  string
+	exact bool
+}{
+	"price":      {"ListPrice", false},
+	"beds":       {"BedroomsTotal", true},
+	"baths":      {"BathroomsTotalDecimal", true},
+	"sqft":       {"LivingArea", false},
+	"lot_sqft":   {"LotSizeSquareFeet", false},
+	"year_built": {"YearBuilt", true},
+	"stories":    {"Stories", true},
+	"garage":     {"GarageSpaces", false},
+	"city":       {"City", true},
+	"state":      {"StateOrProvince", true},
+	"zip":        {"PostalCode", true},
+	"pool":       {"PoolPrivateYN", true},
+	"waterfront": {"WaterfrontYN", true},
+	"view":       {"ViewYN", false},
+	"fireplace":  {"FireplaceYN", true},
+	"new_build":  {"NewConstructionYN", true},
+}
+
+var odataOps = map[string]string{"=": "eq", "!=": "ne", "<": "lt", "<=": "le", ">": "gt", ">=": "ge"}
+
+// odataExpr translates as much of a filter= expression as OData can carry.
+// The result never excludes a listing the local evaluation would keep: parts
+// that cannot be translated are widened to "no constraint" (an empty string),
+// and NOT is only pushed down over exact translations. The API re-applies the
+// full expression to whatever comes back.
+func odataExpr(e filterexpr.Expr) (cond string, exact bool) {
+	switch e := e.(type) {
+	case *filterexpr.Binary:
+		left, leftExact := odataExpr(e.Left)
+		right, rightExact := odataExpr(e.Right)
+		exact = leftExact && rightExact
+		if e.Op == "OR" {
+			if left == "" || right == "" {
+				return "", false
+			}
+			return "(" + left + " or " + right + ")", exact
+		}
+		switch {
+		case left == "":
+			return right, exact
+		case right == "":
+			return left, exact
+		}
+		return "(" + left + " and " + right + ")", exact
+	case *filterexpr.Not:
+		x, xExact := odataExpr(e.X)
+		if x == "" || !xExact {
+			return "", false
+		}
+		return "not (" + x + ")", true
+	case *filterexpr.Cond:
+		field, ok := odataFields[e.Field]
+		if !ok {
+			return "", false
+		}
+		op := odataOps[e.Op]
+		var cond string
+		switch v := e.Value.(type) {
+		case float64:
+			cond = fmt.Sprintf("%s %s %s", field.name, op, odataNumber(v))
+		case string:
+			cond = fmt.Sprintf("tolower(%s) %s %s", field.name, op, odataString(strings.ToLower(v)))
+		case bool:
+			cond = fmt.Sprintf("%s %s %t", field.name, op, v)
+		}
+		// Fields absent upstream are null in OData but zero values locally.
+		if e.Eval(types.Listing{}, nil) {
+			cond = "(" + cond + " or " + field.name + " eq null)"
+		}
+		return cond, field.exact
+	}
+	return "", false
+}
+
+func odataBBox(b types.BBox) []string {
+	minLat, maxLat := odataNumber(b.MinLat), odataNumber(b.MaxLat)
+	minLng, maxLng := odataNumber(b.MinLng), odataNumber(b.MaxLng)
+	conds := []string{"Latitude ge " + minLat + " and Latitude le " + maxLat}
+	if b.MinLng <= b.MaxLng {
+		conds = append(conds, "Longitude ge "+minLng+" and Longitude le "+maxLng)
+	} else {
+		conds = append(conds, "(Longitude ge "+minLng+" or Longitude le "+maxLng+")")
+	}
+	return conds
+}
+
+// odataNumber formats v as an OData numeric literal. %g would switch to
+// exponent form (1e+06) for large prices, which OData does not accept.
+func odataNumber(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// odataStatus selects f's statuses, limiting closed listings to those that
+// closed within SoldWithinDays.
+func odataStatus(f types.SearchFilters) string {
+	var ors []string
+	for _, st := range f.EffectiveStatuses() {
+		for _, v := range resoStatuses[st] {
+			cond := "StandardStatus eq " + odataString(v)
+			if st == types.StatusSold && f.SoldWithinDays > 0 {
+				since := time.Now().AddDate(0, 0, -f.SoldWithinDays).Format("2006-01-02")
+				cond = "(" + cond + " and CloseDate gt " + since + ")"
+			}
+			ors = append(ors, cond)
+		}
+	}
+	if len(ors) == 1 {
+		return ors[0]
+	}
+	return "(" + strings.Join(ors, " or ") + ")"
+}
+
+// resoDate parses a Data Dictionary date field, which may be empty.
+func resoDate(s string) *time.Time {
+	d, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		return nil
+	}
+	return &d
+}
+
+func odataString(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// resoSubTypes maps the app's property types onto PropertySubType lookup values.
+var resoSubTypes = map[string]string{
+	"single family": "Single Family Residence",
+	"condo":         "Condominium",
+	"townhouse":     "Townhouse",
+	"multi family":  "Duplex",
+	"manufactured":  "Manufactured Home",
+	"land":          "Unimproved Land",
+}
+
+func resoSubType(pt string) string {
+	if v, ok := resoSubTypes[strings.ToLower(strings.TrimSpace(pt))]; ok {
+		return v
+	}
+	return strings.TrimSpace(pt)
+}
+
+func appPropertyType(subType, propertyType string) string {
+	for app, reso := range resoSubTypes {
+		if strings.EqualFold(reso, subType) {
+			return titleCase(app)
+		}
+	}
+	if subType != "" {
+		return subType
+	}
+	return propertyType
+}
+
+func titleCase(s string) string {
+	words := strings.Fields(s)
+	for i, w := range words {
+		words[i] = strings.ToUpper(w[:1]) + w[1:]
+	}
+	return strings.Join(words, " ")
+}
+
+// resoProperty holds the subset of the Property resource we map. Pointer
+// fields distinguish "absent" from zero so partial $select responses decode.
+type resoProperty struct {
+	ListingKey              string   `json:"ListingKey"`
+	ListingID               string   `json:"ListingId"`
+	ListPrice               *float64 `json:"ListPrice"`
+	OriginalListPrice       *float64 `json:"OriginalListPrice"`
+	UnparsedAddress         string   `json:"UnparsedAddress"`
+	StreetNumber            string   `json:"StreetNumber"`
+	StreetName              string   `json:"StreetName"`
+	StreetSuffix            string   `json:"StreetSuffix"`
+	UnitNumber              string   `json:"UnitNumber"`
+	City                    string   `json:"City"`
+	StateOrProvince         string   `json:"StateOrProvince"`
+	PostalCode              string   `json:"PostalCode"`
+	BedroomsTotal           *int     `json:"BedroomsTotal"`
+	BathroomsTotalDecimal   *float64 `json:"BathroomsTotalDecimal"`
+	LivingArea              *float64 `json:"LivingArea"`
+	LotSizeSquareFeet       *float64 `json:"LotSizeSquareFeet"`
+	YearBuilt               *int     `json:"YearBuilt"`
+	Stories                 *int     `json:"Stories"`
+	GarageSpaces            *float64 `json:"GarageSpaces"`
+	PoolPrivateYN           *bool    `json:"PoolPrivateYN"`
+	WaterfrontYN            *bool    `json:"WaterfrontYN"`
+	ViewYN                  *bool    `json:"ViewYN"`
+	FireplaceYN             *bool    `json:"FireplaceYN"`
+	NewConstructionYN       *bool    `json:"NewConstructionYN"`
+	Basement                []string `json:"Basement"`
+	ParkingFeatures         []string `json:"ParkingFeatures"`
+	PropertyCondition       []string `json:"PropertyCondition"`
+	AssociationFee          *float64 `json:"AssociationFee"`
+	AssociationFeeFrequency string   `json:"AssociationFeeFrequency"`
+	PropertyType            string   `json:"PropertyType"`
+	PropertySubType         string   `json:"PropertySubType"`
+	View                    []string `json:"View"`
+	PatioAndPorchFeatures   []string `json:"PatioAndPorchFeatures"`
+	ListingContractDate     string   `json:"ListingContractDate"`
+	StandardStatus          string   `json:"StandardStatus"`
+	PurchaseContractDate    string   `json:"PurchaseContractDate"`
+	CloseDate               string   `json:"CloseDate"`
+	ClosePrice              *float64 `json:"ClosePrice"`
+	PublicRemarks           string   `json:"PublicRemarks"`
+	Latitude                *float64 `json:"Latitude"`
+	Longitude               *float64 `json:"Longitude"`
+	Media                   []struct {
+		MediaURL         string `json:"MediaURL"`
+		Order            *int   `json:"Order"`
+		ShortDescription string `json:"ShortDescription"`
+		ImageWidth       *int   `json:"ImageWidth"`
+		ImageHeight      *int   `json:"ImageHeight"`
+		ImageOf          string `json:"ImageOf"`
+	} `json:"Media"`
+}
+
+func (p resoProperty) toListing() types.Listing {
+	l := types.Listing{
+		ID:            "reso-" + firstNonEmpty(p.ListingKey, p.ListingID),
+		Address:       p.address(),
+		Description:   strings.TrimSpace(p.PublicRemarks),
+		City:          p.City,
+		State:         p.StateOrProvince,
+		Zip:           p.PostalCode,
+		Latitude:      derefFloat(p.Latitude),
+		Longitude:     derefFloat(p.Longitude),
+		Price:         roundInt(p.ListPrice),
+		OriginalPrice: roundInt(p.OriginalListPrice),
+		Beds:          derefInt(p.BedroomsTotal),
+		Baths:         derefFloat(p.BathroomsTotalDecimal),
+		Sqft:          roundInt(p.LivingArea),
+		LotSqft:       roundInt(p.LotSizeSquareFeet),
+		YearBuilt:     derefInt(p.YearBuilt),
+		Stories:       derefInt(p.Stories),
+		GarageSpaces:  roundInt(p.GarageSpaces),
+		HasPool:       derefBool(p.PoolPrivateYN),
+		HasWaterfront: derefBool(p.WaterfrontYN),
+		HasView:       derefBool(p.ViewYN) || len(nonNone(p.View)) > 0,
+		HasFireplace:  derefBool(p.FireplaceYN),
+		IsNewBuild:    derefBool(p.NewConstructionYN),
+		HasBasement:   len(nonNone(p.Basement)) > 0,
+		HasRVParking:  anyWord(p.ParkingFeatures, "rv"),
+		IsFixer:       anyContains(p.PropertyCondition, "fixer"),
+		HOAFee:        monthlyFee(p.AssociationFee, p.AssociationFeeFrequency),
+		PropertyType:  appPropertyType(p.PropertySubType, p.PropertyType),
+		Source:        "reso",
+	}
+	if p.UnitNumber != "" && !strings.Contains(l.Address, p.UnitNumber) {
+		l.Address += " #" + p.UnitNumber
+	}
+	l.Title = l.Address
+	if l.PropertyType != "" {
+		l.Title = fmt.Sprintf("%s in %s", l.PropertyType, p.City)
+	}
+	l.PhotoURL = p.heroPhoto()
+	l.Photos = p.photos()
+	l.Status = types.NormalizeStatus(p.StandardStatus)
+	l.ListDate = resoDate(p.ListingContractDate)
+	l.PendingDate = resoDate(p.PurchaseContractDate)
+	l.SoldDate = resoDate(p.CloseDate)
+	l.SoldPrice = roundInt(p.ClosePrice)
+	for _, group := range [][]string{p.View, p.PatioAndPorchFeatures, p.ParkingFeatures} {
+		for _, v := range nonNone(group) {
+			l.Tags = append(l.Tags, strings.ToLower(v))
+		}
+	}
+	return l
+}
+
+func (p resoProperty) address() string {
+	if p.UnparsedAddress != "" {
+		return p.UnparsedAddress
+	}
+	parts := []string{}
+	for _, s := range []string{p.StreetNumber, p.StreetName, p.StreetSuffix} {
+		if s != "" {
+			parts = append(parts, s)
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+func (p resoProperty) heroPhoto() string {
+	best, bestOrder := "", math.MaxInt
+	for _, m := range p.Media {
+		if m.MediaURL == "" {
+			continue
+		}
+		order := math.MaxInt
+		if m.Order != nil {
+			order = *m.Order
+		}
+		if best == "" || order < bestOrder {
+			best, bestOrder = m.MediaURL, order
+		}
+	}
+	return best
+}
+
+// photos maps Media to the gallery, renumbered in display order. Media
+// without an Order sort last, in feed order.
+func (p resoProperty) photos() []types.Photo {
+	type ranked struct {
+		rank  int
+		photo types.Photo
+	}
+	var media []ranked
+	for _, m := range p.Media {
+		if m.MediaURL == "" {
+			continue
+		}
+		rank := math.MaxInt
+		if m.Order != nil {
+			rank = *m.Order
+		}
+		media = append(media, ranked{rank, types.Photo{
+			URL:     m.MediaURL,
+			Caption: strings.TrimSpace(m.ShortDescription),
+			Width:   derefInt(m.ImageWidth),
+			Height:  derefInt(m.ImageHeight),
+			Room:    m.ImageOf,
+		}})
+	}
+	sort.SliceStable(media, func(i, j int) bool { return media[i].rank < media[j].rank })
+	out := make([]types.Photo, len(media))
+	for i, m := range media {
+		out[i] = m.photo
+		out[i].Order = i
+	}
+	return out
+}
+
+// monthlyFee normalizes AssociationFee to a monthly amount.
+func monthlyFee(fee *float64, frequency string) int {
+	if fee == nil {
+		return 0
+	}
+	v := *fee
+	switch strings.ToLower(frequency) {
+	case "annually":
+		v /= 12
+	case "semi-annually":
+		v /= 6
+	case "quarterly":
+		v /= 3
+	case "weekly":
+		v = v * 52 / 12
+	}
+	return int(v + 0.5)
+}
+
+func nonNone(vals []string) []string {
+	var out []string
+	for _, v := range vals {
+		if v != "" && !strings.EqualFold(v, "none") {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+func anyContains(vals []string, needle string) bool {
+	for _, v := range vals {
+		if strings.Contains(strings.ToLower(v), needle) {
+			return true
+		}
+	}
+	return false
+}
+
+// anyWord reports whether any value contains word as a whole word, so "rv"
+// matches "RV Access/Parking" but not "Reserved" or "Covered".
+func anyWord(vals []string, word string) bool {
+	for _, v := range vals {
+		fields := strings.FieldsFunc(strings.ToLower(v), func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		})
+		for _, f := range fields {
+			if f == word {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func firstNonEmpty(vals ...string) string {
+	for _, v := range vals {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+func roundInt(f *float64) int {
+	if f == nil {
+		return 0
+	}
+	return int(*f + 0.5)
+}
+
+func derefInt(i *int) int {
+	if i == nil {
+		return 0
+	}
+	return *i
+}
+
+func derefFloat(f *float64) float64 {
+	if f == nil {
+		return 0
+	}
+	return *f
+}
+
+func derefBool(b *bool) bool {
+	return b != nil && *b
+}
@@ -0,0 +1,249 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"home-finder/internal/types"
+)
+
+// fakeOData serves pages of Property records, linking each page to the next
+// with a relative @odata.nextLink, and records the requests it gets.
+type fakeOData struct {
+	mu       sync.Mutex
+	pages    [][]map[string]any
+	requests []*http.Request
+}
+
+func (f *fakeOData) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	f.requests = append(f.requests, r)
+	f.mu.Unlock()
+	if r.URL.Path != "/odata/Property" {
+		http.NotFound(w, r)
+		return
+	}
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	body := map[string]any{"value": f.pages[page]}
+	if page+1 < len(f.pages) {
+		body["@odata.nextLink"] = "Property?page=" + strconv.Itoa(page+1)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(body)
+}
+
+func newFakeOData(t *testing.T, pages ...[]map[string]any) (*fakeOData, *RESOClient) {
+	t.Helper()
+	f := &fakeOData{pages: pages}
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+	c := NewRESOClient("test", srv.URL+"/odata/", "secret")
+	return f, c
+}
+
+func TestODataFilterNumbers(t *testing.T) {
+	got := ODataFilter(types.SearchFilters{
+		MinPrice: 1_250_000,
+		MaxPrice: 12_000_000,
+		MinBaths: 2.5,
+		BBox:     &types.BBox{MinLng: -122.7, MinLat: 45.5, MaxLng: -122.6, MaxLat: 45.6},
+	})
+	for _, want := range []string{
+		"StandardStatus eq 'Active'",
+		"ListPrice ge 1250000",
+		"ListPrice le 12000000",
+		"BathroomsTotalDecimal ge 2.5",
+		"Latitude ge 45.5 and Latitude le 45.6",
+		"Longitude ge -122.7 and Longitude le -122.6",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("filter %q lacks %q", got, want)
+		}
+	}
+	if strings.Contains(got, "e+") {
+		t.Errorf("filter %q uses exponent notation", got)
+	}
+}
+
+func TestODataFilterExpression(t *testing.T) {
+	got := ODataFilter(types.SearchFilters{Filter: "price >= 2000000 AND city = \"O'Fallon\""})
+	for _, want := range []string{"ListPrice ge 2000000", "tolower(City) eq 'o''fallon'"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("filter %q lacks %q", got, want)
+		}
+	}
+}
+
+func TestRESOSearchFollowsNextLink(t *testing.T) {
+	f, c := newFakeOData(t,
+		[]map[string]any{{"ListingKey": "1"}, {"ListingKey": "2"}},
+		[]map[string]any{{"ListingKey": "3"}},
+	)
+	got, err := c.Search(context.Background(), types.SearchFilters{MinPrice: 1_000_000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, l := range got {
+		ids = append(ids, l.ID)
+	}
+	if strings.Join(ids, ",") != "reso-1,reso-2,reso-3" {
+		t.Fatalf("ids = %v", ids)
+	}
+	if len(f.requests) != 2 {
+		t.Fatalf("made %d requests, want 2", len(f.requests))
+	}
+	first := f.requests[0]
+	if auth := first.Header.Get("Authorization"); auth != "Bearer secret" {
+		t.Errorf("Authorization = %q", auth)
+	}
+	if strings.Contains(first.URL.RawQuery, "+") {
+		t.Errorf("query %q encodes spaces as +", first.URL.RawQuery)
+	}
+	q := first.URL.Query()
+	if filter := q.Get("$filter"); !strings.Contains(filter, "ListPrice ge 1000000") {
+		t.Errorf("$filter = %q", filter)
+	}
+	if q.Get("$top") != "200" || q.Get("$orderby") != "ListingKey" {
+		t.Errorf("paging params = %v", q)
+	}
+	if got := f.requests[1].URL.Query().Get("page"); got != "1" {
+		t.Errorf("second request did not follow nextLink: %s", f.requests[1].URL)
+	}
+}
+
+func TestRESOSearchStopsAtMaxPages(t *testing.T) {
+	f, c := newFakeOData(t,
+		[]map[string]any{{"ListingKey": "1"}},
+		[]map[string]any{{"ListingKey": "2"}},
+		[]map[string]any{{"ListingKey": "3"}},
+	)
+	c.MaxPages = 2
+	got, err := c.Search(context.Background(), types.SearchFilters{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || len(f.requests) != 2 {
+		t.Fatalf("got %d listings in %d requests, want 2 in 2", len(got), len(f.requests))
+	}
+}
+
+func TestRESOFetchPageSkips(t *testing.T) {
+	f, c := newFakeOData(t, []map[string]any{{"ListingKey": "1"}})
+	if _, err := c.FetchPage(context.Background(), types.SearchFilters{}, 3, 50); err != nil {
+		t.Fatal(err)
+	}
+	q := f.requests[0].URL.Query()
+	if q.Get("$top") != "50" || q.Get("$skip") != "100" {
+		t.Errorf("$top=%s $skip=%s, want 50 and 100", q.Get("$top"), q.Get("$skip"))
+	}
+}
+
+func TestRESOFieldMapping(t *testing.T) {
+	_, c := newFakeOData(t, []map[string]any{{
+		"ListingKey":              "K1",
+		"ListingId":               "M1",
+		"ListPrice":               1_499_999.6,
+		"OriginalListPrice":       1_600_000,
+		"StreetNumber":            "12",
+		"StreetName":              "Elm",
+		"StreetSuffix":            "St",
+		"UnitNumber":              "4",
+		"City":                    "Portland",
+		"StateOrProvince":         "OR",
+		"PostalCode":              "97201",
+		"BedroomsTotal":           3,
+		"BathroomsTotalDecimal":   2.5,
+		"LivingArea":              1800.4,
+		"GarageSpaces":            2,
+		"PoolPrivateYN":           true,
+		"ViewYN":                  false,
+		"View":                    []string{"Mountain(s)"},
+		"Basement":                []string{"None"},
+		"ParkingFeatures":         []string{"Reserved", "Covered"},
+		"AssociationFee":          1200,
+		"AssociationFeeFrequency": "Annually",
+		"PropertySubType":         "Condominium",
+		"StandardStatus":          "Closed",
+		"CloseDate":               "2024-03-01",
+		"ClosePrice":              1_450_000,
+		"Media": []map[string]any{
+			{"MediaURL": "https://img/2.jpg", "Order": 2},
+			{"MediaURL": "https://img/none.jpg"},
+			{"MediaURL": "https://img/1.jpg", "Order": 1, "ImageOf": "Kitchen"},
+		},
+	}})
+	l, err := c.GetListing(context.Background(), "reso-K1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checks := []struct {
+		name      string
+		got, want any
+	}{
+		{"ID", l.ID, "reso-K1"},
+		{"Address", l.Address, "12 Elm St #4"},
+		{"Price", l.Price, 1_500_000},
+		{"OriginalPrice", l.OriginalPrice, 1_600_000},
+		{"Beds", l.Beds, 3},
+		{"Baths", l.Baths, 2.5},
+		{"Sqft", l.Sqft, 1800},
+		{"GarageSpaces", l.GarageSpaces, 2},
+		{"HasPool", l.HasPool, true},
+		{"HasView", l.HasView, true},
+		{"HasBasement", l.HasBasement, false},
+		{"HasRVParking", l.HasRVParking, false},
+		{"HOAFee", l.HOAFee, 100},
+		{"PropertyType", l.PropertyType, "Condo"},
+		{"Title", l.Title, "Condo in Portland"},
+		{"Status", l.Status, types.StatusSold},
+		{"SoldPrice", l.SoldPrice, 1_450_000},
+		{"PhotoURL", l.PhotoURL, "https://img/1.jpg"},
+		{"Source", l.Source, "reso"},
+	}
+	for _, c := range checks {
+		if c.got != c.want {
+			t.Errorf("%s = %v, want %v", c.name, c.got, c.want)
+		}
+	}
+	if l.SoldDate == nil || l.SoldDate.Format("2006-01-02") != "2024-03-01" {
+		t.Errorf("SoldDate = %v", l.SoldDate)
+	}
+	if len(l.Photos) != 3 || l.Photos[0].Room != "Kitchen" || l.Photos[2].URL != "https://img/none.jpg" || l.Photos[2].Order != 2 {
+		t.Errorf("Photos = %+v", l.Photos)
+	}
+}
+
+func TestRESORVParking(t *testing.T) {
+	cases := map[string]bool{
+		"RV Access/Parking": true,
+		"RV":                true,
+		"Parking Pad, RV":   true,
+		"Reserved":          false,
+		"Covered":           false,
+		"Driveway":          false,
+	}
+	for feature, want := range cases {
+		got := resoProperty{ParkingFeatures: []string{feature}}.toListing().HasRVParking
+		if got != want {
+			t.Errorf("%q: HasRVParking = %v, want %v", feature, got, want)
+		}
+	}
+}
+
+func TestRESOUpstreamError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "bad filter", http.StatusBadRequest)
+	}))
+	defer srv.Close()
+	c := NewRESOClient("test", srv.URL, "")
+	if _, err := c.Search(context.Background(), types.SearchFilters{}); err == nil || !strings.Contains(err.Error(), "400") {
+		t.Fatalf("err = %v, want reso status 400", err)
+	}
+}
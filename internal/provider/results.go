@@ -0,0 +1,256 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"home-finder/internal/geo"
+	"home-finder/internal/types"
+)
+
+// ResultsClient talks to an upstream that exposes GET /search and answers with
+// JSON shaped as {"results": [ ... listings ... ]}. Both the self-hosted scraper
+// and simple partner APIs use this shape. Requests are bounded by the caller's
+// context deadline rather than a client timeout, since ingest and interactive
+// search need very different limits.
+type ResultsClient struct {
+	Label   string
+	BaseURL string
+	APIKey  string
+	HTTP    *http.Client
+}
+
+func NewResultsClient(label, baseURL, apiKey string) *ResultsClient {
+	return &ResultsClient{
+		Label:   label,
+		BaseURL: baseURL,
+		APIKey:  apiKey,
+		HTTP:    &http.Client{},
+	}
+}
+
+func (c *ResultsClient) Name() string {
+	return c.Label
+}
+
+// Capabilities: the {"results"} contract forwards every filter, including tags
+// and free text; paging is best effort.
+func (c *ResultsClient) Capabilities() Capabilities {
+	return Capabilities{Paging: true, Tags: true, Query: true, Polygon: true}
+}
+
+// Search fetches a single, unpaged result set for the filters.
+func (c *ResultsClient) Search(ctx context.Context, filters types.SearchFilters) ([]types.Listing, error) {
+	return c.fetch(ctx, encodeFilters(filters))
+}
+
+// FetchPage fetches one page of results. Pages are 1-based; upstreams that do
+// not understand page/page_size simply return their full result set.
+func (c *ResultsClient) FetchPage(ctx context.Context, filters types.SearchFilters, page, pageSize int) ([]types.Listing, error) {
+	q := encodeFilters(filters)
+	if page > 0 {
+		q.Set("page", fmt.Sprintf("%d", page))
+	}
+	if pageSize > 0 {
+		q.Set("page_size", fmt.Sprintf("%d", pageSize))
+	}
+	return c.fetch(ctx, q)
+}
+
+// GetListing fetches GET /listings/{id}, which answers with the bare listing
+// the same way this API does.
+func (c *ResultsClient) GetListing(ctx context.Context, id string) (types.Listing, error) {
+	apiURL := fmt.Sprintf("%s/listings/%s", strings.TrimRight(c.BaseURL, "/"), url.PathEscape(id))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, apiURL, nil)
+	if err != nil {
+		return types.Listing{}, err
+	}
+	if c.APIKey != "" {
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.APIKey))
+	}
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return types.Listing{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return types.Listing{}, ErrNotFound
+	}
+	if resp.StatusCode >= 300 {
+		return types.Listing{}, fmt.Errorf("upstream status %d", resp.StatusCode)
+	}
+	var l types.Listing
+	if err := json.NewDecoder(resp.Body).Decode(&l); err != nil {
+		return types.Listing{}, err
+	}
+	if l.ID == "" {
+		return types.Listing{}, ErrNotFound
+	}
+	return l, nil
+}
+
+func (c *ResultsClient) fetch(ctx context.Context, q url.Values) ([]types.Listing, error) {
+	apiURL := fmt.Sprintf("%s/search", strings.TrimRight(c.BaseURL, "/"))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, apiURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.URL.RawQuery = q.Encode()
+	if c.APIKey != "" {
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.APIKey))
+	}
+
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("upstream status %d", resp.StatusCode)
+	}
+	var payload struct {
+		Results []types.Listing `json:"results"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
+		return nil, err
+	}
+	return payload.Results, nil
+}
+
+// encodeFilters maps SearchFilters onto the query params the API itself accepts.
+func encodeFilters(filters types.SearchFilters) url.Values {
+	q := url.Values{}
+	if filters.MinPrice > 0 {
+		q.Set("min_price", fmt.Sprintf("%d", filters.MinPrice))
+	}
+	if filters.MaxPrice > 0 {
+		q.Set("max_price", fmt.Sprintf("%d", filters.MaxPrice))
+	}
+	if filters.MinBeds > 0 {
+		q.Set("min_beds", fmt.Sprintf("%d", filters.MinBeds))
+	}
+	if filters.MaxBeds > 0 {
+		q.Set("max_beds", fmt.Sprintf("%d", filters.MaxBeds))
+	}
+	if filters.MinBaths > 0 {
+		q.Set("min_baths", fmt.Sprintf("%g", filters.MinBaths))
+	}
+	if filters.MaxBaths > 0 {
+		q.Set("max_baths", fmt.Sprintf("%g", filters.MaxBaths))
+	}
+	if filters.MinSqft > 0 {
+		q.Set("min_sqft", fmt.Sprintf("%d", filters.MinSqft))
+	}
+	if filters.MaxSqft > 0 {
+		q.Set("max_sqft", fmt.Sprintf("%d", filters.MaxSqft))
+	}
+	if filters.MinLotSqft > 0 {
+		q.Set("min_lot_sqft", fmt.Sprintf("%d", filters.MinLotSqft))
+	}
+	if filters.MaxLotSqft > 0 {
+		q.Set("max_lot_sqft", fmt.Sprintf("%d", filters.MaxLotSqft))
+	}
+	if filters.MinYearBuilt > 0 {
+		q.Set("min_year_built", fmt.Sprintf("%d", filters.MinYearBuilt))
+	}
+	if filters.MaxYearBuilt > 0 {
+		q.Set("max_year_built", fmt.Sprintf("%d", filters.MaxYearBuilt))
+	}
+	if filters.MinStories > 0 {
+		q.Set("min_stories", fmt.Sprintf("%d", filters.MinStories))
+	}
+	if filters.MinGarage > 0 {
+		q.Set("min_garage", fmt.Sprintf("%d", filters.MinGarage))
+	}
+	if filters.MinHOA > 0 {
+		q.Set("min_hoa", fmt.Sprintf("%d", filters.MinHOA))
+	}
+	if filters.MaxHOA > 0 {
+		q.Set("max_hoa", fmt.Sprintf("%d", filters.MaxHOA))
+	}
+	if len(filters.PropertyTypes) > 0 {
+		q.Set("property_types", strings.Join(filters.PropertyTypes, ","))
+	}
+	if len(filters.Tags) > 0 {
+		q.Set("tags", strings.Join(filters.Tags, ","))
+	}
+	if len(filters.ExcludeTags) > 0 {
+		q.Set("exclude_tags", strings.Join(filters.ExcludeTags, ","))
+	}
+	if filters.City != "" {
+		q.Set("city", filters.City)
+	}
+	if filters.State != "" {
+		q.Set("state", filters.State)
+	}
+	if filters.Zip != "" {
+		q.Set("zip", filters.Zip)
+	}
+	if filters.Near != nil {
+		q.Set("near", fmt.Sprintf("%g,%g", filters.Near.Lat, filters.Near.Lng))
+		if filters.RadiusMi > 0 {
+			q.Set("radius_mi", fmt.Sprintf("%g", filters.RadiusMi))
+		}
+	}
+	if filters.BBox != nil {
+		b := filters.BBox
+		q.Set("bbox", fmt.Sprintf("%g,%g,%g,%g", b.MinLng, b.MinLat, b.MaxLng, b.MaxLat))
+	}
+	if len(filters.Polygons) > 0 {
+		q.Set("polygon", string(geo.ToGeoJSON(filters.Polygons)))
+	}
+	if filters.Query != "" {
+		q.Set("q", filters.Query)
+	}
+	if filters.UseVision {
+		q.Set("use_vision", "1")
+	}
+	if filters.MinVisionConfidence > 0 {
+		q.Set("min_vision_confidence", fmt.Sprintf("%g", filters.MinVisionConfidence))
+	}
+	if filters.RequirePool {
+		q.Set("pool", "1")
+	}
+	if filters.RequireWater {
+		q.Set("waterfront", "1")
+	}
+	if filters.RequireView {
+		q.Set("view", "1")
+	}
+	if filters.RequireBasement {
+		q.Set("basement", "1")
+	}
+	if filters.RequireFireplace {
+		q.Set("fireplace", "1")
+	}
+	if filters.RequireADU {
+		q.Set("adu", "1")
+	}
+	if filters.RequireRVParking {
+		q.Set("rv_parking", "1")
+	}
+	if filters.RequireNew {
+		q.Set("new_build", "1")
+	}
+	if filters.RequireFixer {
+		q.Set("fixer", "1")
+	}
+	if filters.PriceReduced {
+		q.Set("price_reduced", "1")
+	}
+	if len(filters.Statuses) > 0 {
+		q.Set("status", strings.Join(filters.Statuses, ","))
+	}
+	if filters.SoldWithinDays > 0 {
+		q.Set("sold_within_days", fmt.Sprintf("%d", filters.SoldWithinDays))
+	}
+	if filters.MaxDaysOnMarket > 0 {
+		q.Set("max_days_on_market", fmt.Sprintf("%d", filters.MaxDaysOnMarket))
+	}
+
+	return q
+}
@@ -0,0 +1,88 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"home-finder/internal/facet"
+	"home-finder/internal/filterexpr"
+	"home-finder/internal/types"
+)
+
+// Facets counts each named facet with one GROUP BY per facet, dropping that
+// facet's own filter from the WHERE clause. Buckets match api.computeFacets.
+func (s *SQLStore) Facets(ctx context.Context, filters types.SearchFilters, names []string) (types.Facets, error) {
+	if _, err := filterexpr.Parse(filters.Filter); err != nil {
+		return nil, err
+	}
+	out := make(types.Facets, len(names))
+	for _, name := range names {
+		f := facet.Without(filters, name)
+		where, args := buildWhere(s.dialect, f)
+
+		var query string
+		switch name {
+		case facet.PropertyType:
+			query = `SELECT lower(trim(l.property_type)), COUNT(*) FROM listings l WHERE ` + where + ` GROUP BY 1`
+		case facet.Beds:
+			query = `SELECT l.beds, COUNT(*) FROM listings l WHERE ` + where + ` GROUP BY 1`
+		case facet.City:
+			query = `SELECT trim(l.city), COUNT(*) FROM listings l WHERE ` + where + ` GROUP BY 1`
+		case facet.Tags:
+			scope, scopeArgs := tagScope(f)
+			query = `SELECT t.tag, COUNT(DISTINCT l.id) FROM listings l JOIN listing_tags t ON t.listing_id = l.id` + scope +
+				` WHERE ` + where + ` GROUP BY t.tag`
+			// The join condition precedes the WHERE clause.
+			args = append(scopeArgs, args...)
+		case facet.PriceHistogram:
+			query = `SELECT ` + priceBucketExpr() + `, COUNT(*) FROM listings l WHERE ` + where + ` GROUP BY 1`
+		default:
+			return nil, fmt.Errorf("unknown facet %q", name)
+		}
+
+		rows, err := s.db.QueryContext(ctx, s.dialect.rebind(query), args...)
+		if err != nil {
+			return nil, fmt.Errorf("facet %s: %w", name, err)
+		}
+		values := map[string]int{}
+		buckets := map[int]int{}
+		for rows.Next() {
+			var value string
+			var n int
+			if err := rows.Scan(&value, &n); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("facet %s: %w", name, err)
+			}
+			if name == facet.PriceHistogram {
+				i, _ := strconv.Atoi(value)
+				buckets[i] += n
+			} else {
+				values[value] += n
+			}
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return nil, fmt.Errorf("facet %s: %w", name, err)
+		}
+		if name == facet.PriceHistogram {
+			out[name] = facet.Histogram(buckets)
+		} else {
+			out[name] = facet.Values(name, values)
+		}
+	}
+	return out, nil
+}
+
+// priceBucketExpr maps l.price to its facet.PriceEdges bucket index.
+func priceBucketExpr() string {
+	var b strings.Builder
+	b.WriteString("CASE")
+	for i := 1; i < len(facet.PriceEdges); i++ {
+		fmt.Fprintf(&b, " WHEN l.price < %d THEN %d", facet.PriceEdges[i], i-1)
+	}
+	fmt.Fprintf(&b, " ELSE %d END", len(facet.PriceEdges)-1)
+	return b.String()
+}
@@ -0,0 +1,80 @@
+package store
+
+import (
+	"fmt"
+
+	"home-finder/internal/filterexpr"
+)
+
+// filterColumns maps filterexpr field names to listings columns.
+var filterColumns = map[string]string{
+	"price":         "price",
+	"beds":          "beds",
+	"baths":         "baths",
+	"sqft":          "sqft",
+	"lot_sqft":      "lot_sqft",
+	"year_built":    "year_built",
+	"stories":       "stories",
+	"garage":        "garage_spaces",
+	"hoa":           "hoa_fee",
+	"city":          "city",
+	"state":         "state",
+	"zip":           "zip",
+	"property_type": "property_type",
+	"source":        "source",
+	"pool":          "has_pool",
+	"waterfront":    "has_waterfront",
+	"view":          "has_view",
+	"basement":      "has_basement",
+	"fireplace":     "has_fireplace",
+	"adu":           "has_adu",
+	"rv_parking":    "has_rv_parking",
+	"new_build":     "is_new_build",
+	"fixer":         "is_fixer",
+}
+
+// filterSQL translates a parsed filter= expression into a condition over the
+// listings table (aliased l) with the same semantics as Expr.Eval. scope is
+// the tagScope clause for tag conditions.
+func filterSQL(e filterexpr.Expr, scope string, scopeArgs []any) (string, []any) {
+	switch e := e.(type) {
+	case *filterexpr.Binary:
+		left, leftArgs := filterSQL(e.Left, scope, scopeArgs)
+		right, rightArgs := filterSQL(e.Right, scope, scopeArgs)
+		return "(" + left + " " + e.Op + " " + right + ")", append(leftArgs, rightArgs...)
+	case *filterexpr.Not:
+		x, args := filterSQL(e.X, scope, scopeArgs)
+		return "NOT " + x, args
+	case *filterexpr.Cond:
+		return condSQL(e, scope, scopeArgs)
+	}
+	panic(fmt.Sprintf("filterSQL: unexpected node %T", e))
+}
+
+func condSQL(c *filterexpr.Cond, scope string, scopeArgs []any) (string, []any) {
+	op := c.Op
+	if op == "!=" {
+		op = "<>"
+	}
+	switch filterexpr.KindOf(c.Field) {
+	case filterexpr.Number:
+		// Literals may be fractional (beds >= 2.5); comparing them against an
+		// INTEGER column would truncate them on Postgres.
+		return "(CAST(l." + filterColumns[c.Field] + " AS DOUBLE PRECISION) " + op + " ?)", []any{c.Value}
+	case filterexpr.Text:
+		return "(lower(trim(l." + filterColumns[c.Field] + ")) " + op + " lower(?))", []any{c.Value}
+	case filterexpr.Flag:
+		cond := "l." + filterColumns[c.Field]
+		if c.Value.(bool) != (c.Op == "=") {
+			cond = "NOT " + cond
+		}
+		return "(" + cond + ")", nil
+	case filterexpr.Tag:
+		cond := "EXISTS (SELECT 1 FROM listing_tags t WHERE t.listing_id = l.id AND t.tag = ?" + scope + ")"
+		if c.Op != "=" {
+			cond = "NOT " + cond
+		}
+		return cond, append([]any{c.Value}, scopeArgs...)
+	}
+	panic(fmt.Sprintf("filterSQL: unknown field %q", c.Field))
+}
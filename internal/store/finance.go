@@ -0,0 +1,66 @@
+package store
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"home-finder/internal/finance"
+	"home-finder/internal/types"
+)
+
+// monthlyExpr is the SQL twin of finance.Model.Total over the listings table
+// (aliased l). The model's factors are inlined as exact literals so both
+// sides do the same floating-point arithmetic. Tax table keys are checked by
+// TaxTable.Validate when loaded and quoted here besides; zip prefixes are
+// compared with substr rather than LIKE, whose wildcards strings.HasPrefix
+// does not have.
+func monthlyExpr(f types.SearchFilters) string {
+	fin := finance.Defaults
+	if f.Financing != nil {
+		fin = *f.Financing
+	}
+	m := finance.NewModel(fin)
+	num := func(v float64) string { return strconv.FormatFloat(v, 'g', -1, 64) }
+	price := "CAST(l.price AS DOUBLE PRECISION)"
+
+	var loan string
+	if m.Down <= 0 {
+		carry := m.Payment
+		if m.Borrowed > 0.8 {
+			carry += m.PMI
+		}
+		loan = price + " * " + num(m.Borrowed) + " * " + num(carry)
+	} else {
+		down := strconv.Itoa(m.Down)
+		loan = "CASE WHEN l.price > " + down + " THEN CAST(l.price - " + down + " AS DOUBLE PRECISION) * " +
+			"CASE WHEN " + down + " * 5 < l.price THEN " + num(m.Payment+m.PMI) + " ELSE " + num(m.Payment) + " END ELSE 0 END"
+	}
+
+	var annual string
+	if m.TaxRate > 0 {
+		annual = num(m.TaxRate + m.Insurance)
+	} else {
+		var b strings.Builder
+		b.WriteString("CASE")
+		for _, p := range m.Taxes.ZipPrefixes() {
+			b.WriteString(" WHEN substr(trim(l.zip), 1, " + strconv.Itoa(len(p)) + ") = " + sqlString(p) + " THEN " + num(m.Taxes.Zips[p]/100+m.Insurance))
+		}
+		states := make([]string, 0, len(m.Taxes.States))
+		for st := range m.Taxes.States {
+			states = append(states, st)
+		}
+		sort.Strings(states)
+		for _, st := range states {
+			b.WriteString(" WHEN upper(trim(l.state)) = " + sqlString(st) + " THEN " + num(m.Taxes.States[st]/100+m.Insurance))
+		}
+		b.WriteString(" ELSE " + num(m.Taxes.Default/100+m.Insurance) + " END")
+		annual = b.String()
+	}
+	return "(" + loan + " + " + price + " * (" + annual + ") / 12 + l.hoa_fee)"
+}
+
+// sqlString quotes s as an SQL string literal.
+func sqlString(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
@@ -0,0 +1,124 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"home-finder/internal/history"
+	"home-finder/internal/market"
+	"home-finder/internal/types"
+)
+
+// History returns a listing's price and status events, oldest first. The
+// history outlives expiry, so a listing that has dropped out of the store
+// still has one; ErrNotFound means the ID was never stored.
+func (s *SQLStore) History(ctx context.Context, id string) ([]types.HistoryEvent, error) {
+	events, err := loadHistory(ctx, s.db, s.dialect, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(events) == 0 {
+		return nil, ErrNotFound
+	}
+	return events, nil
+}
+
+// queryer is the part of *sql.DB and *sql.Tx that loadHistory needs.
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+func loadHistory(ctx context.Context, q queryer, d dialect, id string) ([]types.HistoryEvent, error) {
+	rows, err := q.QueryContext(ctx, d.rebind(`SELECT at, event, price, status, price_change FROM listing_history
+		WHERE listing_id = ? ORDER BY seq`), id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []types.HistoryEvent
+	for rows.Next() {
+		var e types.HistoryEvent
+		if err := rows.Scan(&e.At, &e.Event, &e.Price, &e.Status, &e.PriceChange); err != nil {
+			return nil, err
+		}
+		e.At = e.At.UTC()
+		out = append(out, e)
+	}
+	return out, rows.Err()
+}
+
+// recordHistory appends whatever changed since l's last recorded event and
+// fills in its history summary. It reports whether l was relisted.
+func recordHistory(ctx context.Context, tx *sql.Tx, d dialect, l *types.Listing, now time.Time) (bool, error) {
+	events, err := loadHistory(ctx, tx, d, l.ID)
+	if err != nil {
+		return false, err
+	}
+	var stored int
+	if err := tx.QueryRowContext(ctx, d.rebind(`SELECT COUNT(*) FROM listings WHERE id = ?`), l.ID).Scan(&stored); err != nil {
+		return false, err
+	}
+	changes := history.Changes(events, *l, stored > 0, now)
+	// Each event also records where the home is and its size, so market
+	// stats can place it after the listing itself has expired.
+	insert := d.rebind(`INSERT INTO listing_history (listing_id, seq, at, event, price, status, price_change, city, state, zip, property_type, sqft)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+	for i, e := range changes {
+		if _, err := tx.ExecContext(ctx, insert, l.ID, len(events)+i+1, e.At, e.Event, e.Price, e.Status, e.PriceChange,
+			l.City, l.State, l.Zip, l.PropertyType, l.Sqft); err != nil {
+			return false, err
+		}
+	}
+	relisted := history.Relisted(changes)
+	if relisted {
+		// An OriginalPrice carried over from the stored copy belongs to the
+		// previous marketing period.
+		l.OriginalPrice = 0
+	}
+	history.Summarize(l, append(events, changes...))
+	return relisted, nil
+}
+
+// MarketSeries returns the history of every listing ever stored in the
+// location and property types of f, whatever its status and whether or not
+// it has since expired. Listings are placed by the attributes recorded with
+// their events; the current size and listing date are used while the
+// listing is still stored.
+func (s *SQLStore) MarketSeries(ctx context.Context, f types.SearchFilters) ([]market.Series, error) {
+	where, args := "1 = 1", []any(nil)
+	if conds, condArgs := placeWhere("h", f); len(conds) > 0 {
+		where, args = strings.Join(conds, " AND "), condArgs
+	}
+	rows, err := s.db.QueryContext(ctx, s.dialect.rebind(`SELECT h.listing_id, COALESCE(l.sqft, h.sqft), l.listed_at, h.at, h.event, h.price, h.status, h.price_change
+		FROM listing_history h LEFT JOIN listings l ON l.id = h.listing_id WHERE `+where+` ORDER BY h.listing_id, h.seq`), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []market.Series
+	for rows.Next() {
+		var (
+			id       string
+			sqft     int
+			listedAt sql.NullInt64
+			e        types.HistoryEvent
+		)
+		if err := rows.Scan(&id, &sqft, &listedAt, &e.At, &e.Event, &e.Price, &e.Status, &e.PriceChange); err != nil {
+			return nil, err
+		}
+		if len(out) == 0 || out[len(out)-1].ID != id {
+			out = append(out, market.Series{ID: id})
+			if listedAt.Int64 > 0 {
+				out[len(out)-1].ListedAt = time.Unix(listedAt.Int64, 0).UTC()
+			}
+		}
+		e.At = e.At.UTC()
+		last := &out[len(out)-1]
+		// The latest recorded size wins for listings that have expired.
+		last.Sqft = sqft
+		last.Events = append(last.Events, e)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,61 @@
+package store
+
+import (
+	"context"
+	"time"
+)
+
+// IngestRun records one execution of an ingest job.
+type IngestRun struct {
+	ID          int64     `json:"id"`
+	Job         string    `json:"job"`
+	Source      string    `json:"source"`
+	TriggeredBy string    `json:"triggeredBy"`
+	StartedAt   time.Time `json:"startedAt"`
+	FinishedAt  time.Time `json:"finishedAt"`
+	Pages       int       `json:"pages"`
+	Fetched     int       `json:"fetched"`
+	Upserted    int       `json:"upserted"`
+	Skipped     int       `json:"skipped"`
+	Error       string    `json:"error,omitempty"`
+}
+
+// IngestRunLog persists ingest job history.
+type IngestRunLog interface {
+	RecordIngestRun(ctx context.Context, run IngestRun) error
+	RecentIngestRuns(ctx context.Context, limit int) ([]IngestRun, error)
+}
+
+func (s *SQLStore) RecordIngestRun(ctx context.Context, run IngestRun) error {
+	_, err := s.db.ExecContext(ctx, s.dialect.rebind(`INSERT INTO ingest_runs (
+		job, source, triggered_by, started_at, finished_at, pages, fetched, upserted, skipped, error
+	) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`),
+		run.Job, run.Source, run.TriggeredBy, run.StartedAt.UTC(), run.FinishedAt.UTC(),
+		run.Pages, run.Fetched, run.Upserted, run.Skipped, run.Error,
+	)
+	return err
+}
+
+func (s *SQLStore) RecentIngestRuns(ctx context.Context, limit int) ([]IngestRun, error) {
+	if limit <= 0 {
+		limit = 20
+	}
+	rows, err := s.db.QueryContext(ctx, s.dialect.rebind(`SELECT
+		id, job, source, triggered_by, started_at, finished_at, pages, fetched, upserted, skipped, error
+	FROM ingest_runs ORDER BY started_at DESC, id DESC LIMIT ?`), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []IngestRun
+	for rows.Next() {
+		var run IngestRun
+		if err := rows.Scan(&run.ID, &run.Job, &run.Source, &run.TriggeredBy, &run.StartedAt, &run.FinishedAt,
+			&run.Pages, &run.Fetched, &run.Upserted, &run.Skipped, &run.Error); err != nil {
+			return nil, err
+		}
+		out = append(out, run)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,61 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// backfills run in Go right after the migration of the same version, for
+// derived data SQL alone cannot compute.
+//
+// Migration 5 created listing_terms, but its rows are now written with the
+// columns 17 adds, so the listings stored before either are indexed at 17.
+var backfills = map[int]func(ctx context.Context, tx *sql.Tx, d dialect) error{
+	10: backfillStatus,
+	15: backfillScoredTags,
+	17: reindexTerms,
+}
+
+// Migrate applies any schema migrations for the store's dialect that have not
+// yet been recorded in schema_migrations. Migrations are append-only and run in
+// a single transaction so the API and worker can start side by side.
+func (s *SQLStore) Migrate(ctx context.Context) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if s.dialect.migrationLock != "" {
+		if _, err := tx.ExecContext(ctx, s.dialect.migrationLock); err != nil {
+			return fmt.Errorf("lock migrations: %w", err)
+		}
+	}
+	if _, err := tx.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (version INTEGER PRIMARY KEY)`); err != nil {
+		return fmt.Errorf("create schema_migrations: %w", err)
+	}
+	var current int
+	if err := tx.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&current); err != nil {
+		return fmt.Errorf("read schema version: %w", err)
+	}
+	for i := current; i < len(s.dialect.migrations); i++ {
+		version := i + 1
+		if _, err := tx.ExecContext(ctx, s.dialect.migrations[i]); err != nil {
+			return fmt.Errorf("migration %d: %w", version, err)
+		}
+		if backfill := backfills[version]; backfill != nil {
+			if err := backfill(ctx, tx, s.dialect); err != nil {
+				return fmt.Errorf("backfill migration %d: %w", version, err)
+			}
+		}
+		if _, err := tx.ExecContext(ctx, s.dialect.rebind(`INSERT INTO schema_migrations (version) VALUES (?)`), version); err != nil {
+			return fmt.Errorf("record migration %d: %w", version, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	s.optimize(ctx)
+	return nil
+}
@@ -0,0 +1,114 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"home-finder/internal/photo"
+	"home-finder/internal/types"
+)
+
+// PhotoMatch is a stored listing photo whose perceptual hash is close to the
+// one searched for.
+type PhotoMatch struct {
+	ListingID string
+	Position  int
+	Hash      string
+	DHash     string
+	Distance  int
+	// FirstSeen is when the listing was first stored with the photo; only
+	// set by PhotoSightings.
+	FirstSeen time.Time
+}
+
+// writePhotos replaces the listing_photos rows for l and records any photo
+// the listing shows for the first time in photo_sightings, which outlives
+// expiry. Photos that have not been cached yet have no hash and are skipped.
+func writePhotos(ctx context.Context, tx *sql.Tx, d dialect, l types.Listing, now time.Time) error {
+	if _, err := tx.ExecContext(ctx, d.rebind(`DELETE FROM listing_photos WHERE listing_id = ?`), l.ID); err != nil {
+		return err
+	}
+	var rows, sightings []string
+	var args, sightingArgs []any
+	for i, p := range l.Photos {
+		h, err := photo.ParseDHash(p.DHash)
+		if err != nil || p.Hash == "" {
+			continue
+		}
+		b := photo.Bands(h)
+		rows = append(rows, "(?, ?, ?, ?, ?, ?, ?, ?)")
+		args = append(args, l.ID, i, p.Hash, p.DHash, b[0], b[1], b[2], b[3])
+		sightings = append(sightings, "(?, ?, ?, ?, ?, ?, ?, ?)")
+		sightingArgs = append(sightingArgs, l.ID, p.Hash, p.DHash, b[0], b[1], b[2], b[3], now)
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+	if _, err := tx.ExecContext(ctx, d.rebind(`INSERT INTO listing_photos (listing_id, position, hash, dhash, band0, band1, band2, band3) VALUES `+strings.Join(rows, ", ")), args...); err != nil {
+		return err
+	}
+	_, err := tx.ExecContext(ctx, d.rebind(`INSERT INTO photo_sightings (listing_id, hash, dhash, band0, band1, band2, band3, first_seen) VALUES `+
+		strings.Join(sightings, ", ")+` ON CONFLICT DO NOTHING`), sightingArgs...)
+	return err
+}
+
+// SimilarPhotos finds stored listing photos within maxDistance bits of
+// dhash. Candidates share at least one exact 16-bit band, which is why
+// maxDistance may not exceed photo.MaxBandDistance.
+func (s *SQLStore) SimilarPhotos(ctx context.Context, dhash string, maxDistance int) ([]PhotoMatch, error) {
+	return s.nearPhotos(ctx, `SELECT listing_id, position, hash, dhash FROM listing_photos`, dhash, maxDistance,
+		func(rows *sql.Rows, m *PhotoMatch) error {
+			return rows.Scan(&m.ListingID, &m.Position, &m.Hash, &m.DHash)
+		})
+}
+
+// PhotoSightings is SimilarPhotos over every listing ever stored, including
+// expired ones, with when each first showed the photo. Positions are not
+// kept.
+func (s *SQLStore) PhotoSightings(ctx context.Context, dhash string, maxDistance int) ([]PhotoMatch, error) {
+	return s.nearPhotos(ctx, `SELECT listing_id, hash, dhash, first_seen FROM photo_sightings`, dhash, maxDistance,
+		func(rows *sql.Rows, m *PhotoMatch) error {
+			if err := rows.Scan(&m.ListingID, &m.Hash, &m.DHash, &m.FirstSeen); err != nil {
+				return err
+			}
+			m.FirstSeen = m.FirstSeen.UTC()
+			return nil
+		})
+}
+
+// nearPhotos runs query, a SELECT from a table with band columns, for the
+// rows sharing a band with dhash and keeps those within maxDistance.
+func (s *SQLStore) nearPhotos(ctx context.Context, query, dhash string, maxDistance int, scan func(*sql.Rows, *PhotoMatch) error) ([]PhotoMatch, error) {
+	h, err := photo.ParseDHash(dhash)
+	if err != nil {
+		return nil, err
+	}
+	if maxDistance < 0 || maxDistance > photo.MaxBandDistance {
+		return nil, fmt.Errorf("maxDistance must be between 0 and %d", photo.MaxBandDistance)
+	}
+	b := photo.Bands(h)
+	rows, err := s.db.QueryContext(ctx, s.dialect.rebind(query+`
+		WHERE band0 = ? OR band1 = ? OR band2 = ? OR band3 = ?`), b[0], b[1], b[2], b[3])
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []PhotoMatch
+	for rows.Next() {
+		var m PhotoMatch
+		if err := scan(rows, &m); err != nil {
+			return nil, err
+		}
+		other, err := photo.ParseDHash(m.DHash)
+		if err != nil {
+			continue
+		}
+		if m.Distance = photo.Distance(h, other); m.Distance <= maxDistance {
+			out = append(out, m)
+		}
+	}
+	return out, rows.Err()
+}
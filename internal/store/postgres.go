@@ -0,0 +1,218 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+
+	"home-finder/internal/types"
+)
+
+var postgresDialect = dialect{
+	name:       "postgres",
+	numbered:   true,
+	migrations: postgresMigrations,
+	least:      "LEAST",
+	// Arbitrary constant key shared by every home-finder process.
+	migrationLock: `SELECT pg_advisory_xact_lock(727274)`,
+	inBox:         postgresInBox,
+}
+
+var postgresMigrations = []string{
+	`CREATE TABLE listings (
+		id             TEXT PRIMARY KEY,
+		title          TEXT NOT NULL DEFAULT '',
+		price          INTEGER NOT NULL DEFAULT 0,
+		address        TEXT NOT NULL DEFAULT '',
+		city           TEXT NOT NULL DEFAULT '',
+		state          TEXT NOT NULL DEFAULT '',
+		zip            TEXT NOT NULL DEFAULT '',
+		beds           INTEGER NOT NULL DEFAULT 0,
+		baths          DOUBLE PRECISION NOT NULL DEFAULT 0,
+		sqft           INTEGER NOT NULL DEFAULT 0,
+		lot_sqft       INTEGER NOT NULL DEFAULT 0,
+		year_built     INTEGER NOT NULL DEFAULT 0,
+		stories        INTEGER NOT NULL DEFAULT 0,
+		garage_spaces  INTEGER NOT NULL DEFAULT 0,
+		has_rv_parking BOOLEAN NOT NULL DEFAULT FALSE,
+		has_pool       BOOLEAN NOT NULL DEFAULT FALSE,
+		has_waterfront BOOLEAN NOT NULL DEFAULT FALSE,
+		has_view       BOOLEAN NOT NULL DEFAULT FALSE,
+		has_basement   BOOLEAN NOT NULL DEFAULT FALSE,
+		has_fireplace  BOOLEAN NOT NULL DEFAULT FALSE,
+		is_new_build   BOOLEAN NOT NULL DEFAULT FALSE,
+		is_fixer       BOOLEAN NOT NULL DEFAULT FALSE,
+		has_adu        BOOLEAN NOT NULL DEFAULT FALSE,
+		hoa_fee        INTEGER NOT NULL DEFAULT 0,
+		property_type  TEXT NOT NULL DEFAULT '',
+		tags_text      TEXT NOT NULL DEFAULT '',
+		source         TEXT NOT NULL DEFAULT '',
+		data           JSONB NOT NULL,
+		created_at     TIMESTAMPTZ NOT NULL DEFAULT now(),
+		updated_at     TIMESTAMPTZ NOT NULL DEFAULT now()
+	);
+	CREATE TABLE listing_tags (
+		listing_id TEXT NOT NULL REFERENCES listings (id) ON DELETE CASCADE,
+		tag        TEXT NOT NULL,
+		vision     BOOLEAN NOT NULL,
+		PRIMARY KEY (listing_id, tag, vision)
+	);
+	CREATE INDEX listing_tags_tag_idx ON listing_tags (tag, listing_id);
+	CREATE INDEX listings_price_idx ON listings (price);
+	CREATE INDEX listings_beds_baths_idx ON listings (beds, baths);
+	CREATE INDEX listings_sqft_idx ON listings (sqft);
+	CREATE INDEX listings_state_city_idx ON listings (lower(state), lower(city));
+	CREATE INDEX listings_zip_idx ON listings (zip text_pattern_ops);
+	CREATE INDEX listings_property_type_idx ON listings (lower(property_type));
+	CREATE INDEX listings_updated_at_idx ON listings (updated_at);`,
+	`CREATE TABLE ingest_runs (
+		id           BIGSERIAL PRIMARY KEY,
+		job          TEXT NOT NULL,
+		source       TEXT NOT NULL,
+		triggered_by TEXT NOT NULL,
+		started_at   TIMESTAMPTZ NOT NULL,
+		finished_at  TIMESTAMPTZ NOT NULL,
+		pages        INTEGER NOT NULL DEFAULT 0,
+		fetched      INTEGER NOT NULL DEFAULT 0,
+		upserted     INTEGER NOT NULL DEFAULT 0,
+		skipped      INTEGER NOT NULL DEFAULT 0,
+		error        TEXT NOT NULL DEFAULT ''
+	);
+	CREATE INDEX ingest_runs_started_at_idx ON ingest_runs (started_at DESC);`,
+	`ALTER TABLE listings ADD COLUMN listed_at BIGINT NOT NULL DEFAULT 0;
+	UPDATE listings SET listed_at = EXTRACT(EPOCH FROM created_at)::BIGINT;
+	CREATE INDEX listings_listed_at_idx ON listings (listed_at DESC, id);
+	CREATE INDEX listings_price_id_idx ON listings (price, id);
+	CREATE INDEX listings_year_built_idx ON listings (year_built);`,
+	`ALTER TABLE listings ADD COLUMN latitude DOUBLE PRECISION;
+	ALTER TABLE listings ADD COLUMN longitude DOUBLE PRECISION;
+	CREATE INDEX listings_lat_lng_idx ON listings (latitude, longitude);`,
+	`CREATE TABLE listing_terms (
+		listing_id TEXT NOT NULL REFERENCES listings (id) ON DELETE CASCADE,
+		field      TEXT NOT NULL,
+		term       TEXT NOT NULL,
+		tf         INTEGER NOT NULL,
+		PRIMARY KEY (listing_id, field, term)
+	);
+	CREATE INDEX listing_terms_term_idx ON listing_terms (term, listing_id);`,
+	`ALTER TABLE listing_tags ADD COLUMN confidence DOUBLE PRECISION;`,
+	`CREATE TABLE listing_photos (
+		listing_id TEXT NOT NULL REFERENCES listings (id) ON DELETE CASCADE,
+		position   INTEGER NOT NULL,
+		hash       TEXT NOT NULL,
+		dhash      TEXT NOT NULL,
+		band0      INTEGER NOT NULL,
+		band1      INTEGER NOT NULL,
+		band2      INTEGER NOT NULL,
+		band3      INTEGER NOT NULL,
+		PRIMARY KEY (listing_id, position)
+	);
+	CREATE INDEX listing_photos_band0_idx ON listing_photos (band0);
+	CREATE INDEX listing_photos_band1_idx ON listing_photos (band1);
+	CREATE INDEX listing_photos_band2_idx ON listing_photos (band2);
+	CREATE INDEX listing_photos_band3_idx ON listing_photos (band3);`,
+	`CREATE TABLE vision_results (
+		image_hash   TEXT NOT NULL,
+		model        TEXT NOT NULL,
+		version      TEXT NOT NULL,
+		url          TEXT NOT NULL DEFAULT '',
+		tags         TEXT NOT NULL,
+		result_model TEXT NOT NULL DEFAULT '',
+		created_at   TIMESTAMPTZ NOT NULL,
+		PRIMARY KEY (image_hash, model, version)
+	);`,
+	`CREATE TABLE listing_history (
+		listing_id   TEXT NOT NULL,
+		seq          INTEGER NOT NULL,
+		at           TIMESTAMPTZ NOT NULL,
+		event        TEXT NOT NULL,
+		price        INTEGER NOT NULL,
+		status       TEXT NOT NULL,
+		price_change INTEGER NOT NULL DEFAULT 0,
+		PRIMARY KEY (listing_id, seq)
+	);
+	INSERT INTO listing_history (listing_id, seq, at, event, price, status)
+		SELECT id, 1, created_at, 'listed', price, 'active' FROM listings;
+	ALTER TABLE listings ADD COLUMN original_price INTEGER NOT NULL DEFAULT 0;
+	UPDATE listings SET original_price = price;`,
+	`ALTER TABLE listings ADD COLUMN status TEXT NOT NULL DEFAULT 'active';
+	ALTER TABLE listings ADD COLUMN sold_at BIGINT;
+	CREATE INDEX listings_status_idx ON listings (status, sold_at);`,
+	`CREATE TABLE user_settings (
+		user_id    TEXT PRIMARY KEY,
+		financing  TEXT NOT NULL,
+		updated_at TIMESTAMPTZ NOT NULL
+	);`,
+	`CREATE TABLE saved_searches (
+		id             BIGSERIAL PRIMARY KEY,
+		user_id        TEXT NOT NULL,
+		name           TEXT NOT NULL,
+		filters        TEXT NOT NULL,
+		filters_key    TEXT NOT NULL,
+		sort           TEXT NOT NULL DEFAULT '',
+		created_at     TIMESTAMPTZ NOT NULL,
+		updated_at     TIMESTAMPTZ NOT NULL,
+		last_viewed_at TIMESTAMPTZ,
+		UNIQUE (user_id, filters_key)
+	);
+	CREATE TABLE saved_search_seen (
+		search_id  BIGINT NOT NULL REFERENCES saved_searches (id) ON DELETE CASCADE,
+		listing_id TEXT NOT NULL,
+		PRIMARY KEY (search_id, listing_id)
+	);`,
+	`CREATE TABLE photo_sightings (
+		listing_id TEXT NOT NULL,
+		hash       TEXT NOT NULL,
+		dhash      TEXT NOT NULL,
+		band0      INTEGER NOT NULL,
+		band1      INTEGER NOT NULL,
+		band2      INTEGER NOT NULL,
+		band3      INTEGER NOT NULL,
+		first_seen TIMESTAMPTZ NOT NULL,
+		PRIMARY KEY (listing_id, hash)
+	);
+	CREATE INDEX photo_sightings_band0_idx ON photo_sightings (band0);
+	CREATE INDEX photo_sightings_band1_idx ON photo_sightings (band1);
+	CREATE INDEX photo_sightings_band2_idx ON photo_sightings (band2);
+	CREATE INDEX photo_sightings_band3_idx ON photo_sightings (band3);
+	INSERT INTO photo_sightings (listing_id, hash, dhash, band0, band1, band2, band3, first_seen)
+		SELECT p.listing_id, p.hash, p.dhash, p.band0, p.band1, p.band2, p.band3, l.created_at
+		FROM listing_photos p JOIN listings l ON l.id = p.listing_id WHERE true
+		ON CONFLICT DO NOTHING;`,
+	`ALTER TABLE listing_history ADD COLUMN city TEXT NOT NULL DEFAULT '';
+	ALTER TABLE listing_history ADD COLUMN state TEXT NOT NULL DEFAULT '';
+	ALTER TABLE listing_history ADD COLUMN zip TEXT NOT NULL DEFAULT '';
+	ALTER TABLE listing_history ADD COLUMN property_type TEXT NOT NULL DEFAULT '';
+	ALTER TABLE listing_history ADD COLUMN sqft INTEGER NOT NULL DEFAULT 0;
+	UPDATE listing_history h SET city = l.city, state = l.state, zip = l.zip, property_type = l.property_type, sqft = l.sqft
+		FROM listings l WHERE l.id = h.listing_id;`,
+	`ALTER TABLE listing_tags ADD COLUMN scored_only BOOLEAN NOT NULL DEFAULT FALSE;`,
+	`CREATE INDEX listings_geo_idx ON listings USING gist (point(longitude, latitude));`,
+	`ALTER TABLE listing_terms ADD COLUMN positions TEXT NOT NULL DEFAULT '';
+	CREATE TABLE listing_field_lengths (
+		listing_id TEXT NOT NULL REFERENCES listings (id) ON DELETE CASCADE,
+		field      TEXT NOT NULL,
+		length     INTEGER NOT NULL,
+		PRIMARY KEY (listing_id, field)
+	);
+	CREATE TABLE text_stats (
+		field  TEXT PRIMARY KEY,
+		docs   INTEGER NOT NULL,
+		tokens INTEGER NOT NULL
+	);`,
+}
+
+// postgresInBox matches the GiST index on point(longitude, latitude).
+func postgresInBox(b types.BBox) (string, []any) {
+	return "point(l.longitude, l.latitude) <@ box(point(?, ?), point(?, ?))",
+		[]any{b.MinLng, b.MinLat, b.MaxLng, b.MaxLat}
+}
+
+func openPostgres(databaseURL string) (*SQLStore, error) {
+	db, err := sql.Open("pgx", databaseURL)
+	if err != nil {
+		return nil, fmt.Errorf("open postgres: %w", err)
+	}
+	return &SQLStore{db: db, dialect: postgresDialect}, nil
+}
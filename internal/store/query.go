@@ -0,0 +1,296 @@
+package store
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"home-finder/internal/amenity"
+	"home-finder/internal/filterexpr"
+	"home-finder/internal/fulltext"
+	"home-finder/internal/geo"
+	"home-finder/internal/paging"
+	"home-finder/internal/types"
+)
+
+// buildWhere translates SearchFilters into a WHERE clause over the listings
+// table (aliased l) using ? placeholders. It mirrors api.filterListings so the
+// store and the in-memory path return the same rows.
+func buildWhere(d dialect, f types.SearchFilters) (string, []any) {
+	var conds []string
+	var args []any
+	add := func(cond string, vals ...any) {
+		conds = append(conds, cond)
+		args = append(args, vals...)
+	}
+	intRange := func(col string, min, max int) {
+		if min > 0 {
+			add("l."+col+" >= ?", min)
+		}
+		if max > 0 {
+			add("l."+col+" <= ?", max)
+		}
+	}
+
+	intRange("price", f.MinPrice, f.MaxPrice)
+	intRange("beds", f.MinBeds, f.MaxBeds)
+	if f.MinBaths > 0 {
+		add("l.baths >= ?", f.MinBaths)
+	}
+	if f.MaxBaths > 0 {
+		add("l.baths <= ?", f.MaxBaths)
+	}
+	intRange("sqft", f.MinSqft, f.MaxSqft)
+	intRange("lot_sqft", f.MinLotSqft, f.MaxLotSqft)
+	intRange("year_built", f.MinYearBuilt, f.MaxYearBuilt)
+	intRange("stories", f.MinStories, 0)
+	intRange("garage_spaces", f.MinGarage, 0)
+	intRange("hoa_fee", f.MinHOA, f.MaxHOA)
+
+	placeConds, placeArgs := placeWhere("l", f)
+	conds = append(conds, placeConds...)
+	args = append(args, placeArgs...)
+
+	scope, scopeArgs := tagScope(f)
+	for _, tag := range f.Tags {
+		if tag == "" {
+			continue
+		}
+		add("EXISTS (SELECT 1 FROM listing_tags t WHERE t.listing_id = l.id AND t.tag = ?"+scope+")", append([]any{normalizeTag(tag)}, scopeArgs...)...)
+	}
+	if len(f.ExcludeTags) > 0 {
+		var in []string
+		for _, tag := range f.ExcludeTags {
+			in = append(in, "?")
+			args = append(args, normalizeTag(tag))
+		}
+		conds = append(conds, "NOT EXISTS (SELECT 1 FROM listing_tags t WHERE t.listing_id = l.id AND t.tag IN ("+strings.Join(in, ", ")+")"+scope+")")
+		args = append(args, scopeArgs...)
+	}
+
+	if f.BBox != nil {
+		addBBox(d, &conds, &args, *f.BBox)
+	}
+	if len(f.Polygons) > 0 {
+		addBBox(d, &conds, &args, geo.PolygonBounds(f.Polygons))
+		conds = append(conds, polygonExpr(f.Polygons))
+	}
+	if f.Near != nil && f.RadiusMi > 0 {
+		// The bounding box lets the spatial index do the coarse cut; the
+		// haversine predicate then trims the corners.
+		addBBox(d, &conds, &args, geo.Around(*f.Near, f.RadiusMi))
+		dist, distArgs := distanceExpr(d, *f.Near)
+		add(dist+" <= ?", append(distArgs, f.RadiusMi)...)
+	}
+	// Only term presence is checked here; phrases and scoring need the
+	// token positions and are applied in Go by searchText.
+	for _, term := range fulltext.ParseQuery(f.Query).AllTerms() {
+		add("EXISTS (SELECT 1 FROM listing_terms t WHERE t.listing_id = l.id AND t.term = ?)", term)
+	}
+
+	flags := []struct {
+		on  bool
+		col string
+	}{
+		{f.RequirePool, "has_pool"},
+		{f.RequireWater, "has_waterfront"},
+		{f.RequireView, "has_view"},
+		{f.RequireBasement, "has_basement"},
+		{f.RequireFireplace, "has_fireplace"},
+		{f.RequireADU, "has_adu"},
+		{f.RequireRVParking, "has_rv_parking"},
+		{f.RequireNew, "is_new_build"},
+		{f.RequireFixer, "is_fixer"},
+	}
+	for _, fl := range flags {
+		if fl.on {
+			conds = append(conds, "l."+fl.col)
+		}
+	}
+	cond, condArgs := statusCond(f, time.Now())
+	add(cond, condArgs...)
+	if f.PriceReduced {
+		conds = append(conds, "l.original_price > 0 AND l.price > 0 AND l.price < l.original_price")
+	}
+	if f.MaxMonthly > 0 {
+		// Matches finance.Model.Within: the rounded cost is at most MaxMonthly.
+		add(monthlyExpr(f)+" < ?", float64(f.MaxMonthly)+0.5)
+	}
+	if f.MaxDaysOnMarket > 0 {
+		// DaysOnMarket counts whole days, so N days on market means listed
+		// less than N+1 days ago.
+		add("l.listed_at > ?", time.Now().Add(-time.Duration(f.MaxDaysOnMarket+1)*24*time.Hour).Unix())
+	}
+	for _, name := range f.Verified {
+		fl, ok := amenity.Lookup(name)
+		if !ok {
+			conds = append(conds, "1 = 0")
+			continue
+		}
+		in := strings.TrimSuffix(strings.Repeat("?, ", len(fl.Tags)), ", ")
+		conds = append(conds, "l."+fl.Column+" AND EXISTS (SELECT 1 FROM listing_tags t WHERE t.listing_id = l.id AND t.vision AND NOT t.scored_only AND t.tag IN ("+in+"))")
+		for _, tag := range fl.Tags {
+			args = append(args, tag)
+		}
+	}
+	// Search and Facets reject malformed expressions before building SQL.
+	if expr, err := filterexpr.Parse(f.Filter); err == nil && expr != nil {
+		cond, condArgs := filterSQL(expr, scope, scopeArgs)
+		add(cond, condArgs...)
+	}
+
+	if len(conds) == 0 {
+		return "1 = 1", args
+	}
+	return strings.Join(conds, " AND "), args
+}
+
+// placeWhere returns the conditions for f's property types, city, state and
+// zip over the table aliased alias, which has those columns under the
+// listings names.
+func placeWhere(alias string, f types.SearchFilters) ([]string, []any) {
+	var conds []string
+	var args []any
+	if len(f.PropertyTypes) > 0 {
+		var in []string
+		for _, pt := range f.PropertyTypes {
+			in = append(in, "?")
+			args = append(args, strings.ToLower(strings.TrimSpace(pt)))
+		}
+		conds = append(conds, "lower("+alias+".property_type) IN ("+strings.Join(in, ", ")+")")
+	}
+	if f.City != "" {
+		conds = append(conds, "lower("+alias+`.city) LIKE ? ESCAPE '\'`)
+		args = append(args, "%"+escapeLike(strings.ToLower(f.City))+"%")
+	}
+	if f.State != "" {
+		conds = append(conds, "lower("+alias+".state) = ?")
+		args = append(args, strings.ToLower(f.State))
+	}
+	if f.Zip != "" {
+		conds = append(conds, alias+`.zip LIKE ? ESCAPE '\'`)
+		args = append(args, escapeLike(f.Zip)+"%")
+	}
+	return conds, args
+}
+
+// tagScope restricts listing_tags rows (aliased t) to the ones tag filters
+// see, mirroring Listing.TagPool: vision tags only when the caller opts in,
+// and then every tag scored at or above MinVisionConfidence, scored_only
+// rows included, or else only the listing's VisionTags.
+func tagScope(f types.SearchFilters) (string, []any) {
+	switch {
+	case !f.UseVision:
+		return " AND NOT t.vision", nil
+	case f.MinVisionConfidence > 0:
+		return " AND (NOT t.vision OR t.confidence >= ?)", []any{f.MinVisionConfidence}
+	}
+	return " AND NOT t.scored_only", nil
+}
+
+func escapeLike(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return r.Replace(s)
+}
+
+// addBBox restricts listings to b through the dialect's spatial index, one
+// lookup per side of the antimeridian, then tests the coordinates exactly.
+// The unary plus keeps that test off the (latitude, longitude) index, which
+// would otherwise compete with the spatial one for the plan.
+func addBBox(d dialect, conds *[]string, args *[]any, b types.BBox) {
+	boxes := []types.BBox{b}
+	if b.MinLng > b.MaxLng {
+		boxes = []types.BBox{
+			{MinLat: b.MinLat, MaxLat: b.MaxLat, MinLng: b.MinLng, MaxLng: 180},
+			{MinLat: b.MinLat, MaxLat: b.MaxLat, MinLng: -180, MaxLng: b.MaxLng},
+		}
+	}
+	var lookups []string
+	for _, box := range boxes {
+		cond, boxArgs := d.inBox(box)
+		lookups = append(lookups, cond)
+		*args = append(*args, boxArgs...)
+	}
+	*conds = append(*conds, "("+strings.Join(lookups, " OR ")+")")
+
+	*conds = append(*conds, "+l.latitude BETWEEN ? AND ?")
+	*args = append(*args, b.MinLat, b.MaxLat)
+	if b.MinLng <= b.MaxLng {
+		*conds = append(*conds, "+l.longitude BETWEEN ? AND ?")
+		*args = append(*args, b.MinLng, b.MaxLng)
+	} else {
+		*conds = append(*conds, "(+l.longitude >= ? OR +l.longitude <= ?)")
+		*args = append(*args, b.MinLng, b.MaxLng)
+	}
+}
+
+// polygonExpr is geo.ContainsPolygons in SQL: per polygon, the number of ring
+// edges a westward ray crosses must be odd. Vertex counts are capped by
+// geo.ValidatePolygons, and coordinates are inlined with round-trip precision
+// to keep the parameter count small.
+func polygonExpr(polys []types.Polygon) string {
+	num := func(f float64) string { return strconv.FormatFloat(f, 'g', -1, 64) }
+	var ors []string
+	for _, poly := range polys {
+		var terms []string
+		for _, ring := range poly {
+			for _, e := range geo.Edges(ring) {
+				terms = append(terms, "CASE WHEN l.latitude >= "+num(e.MinLat)+" AND l.latitude < "+num(e.MaxLat)+
+					" AND l.longitude < "+num(e.Slope)+" * (l.latitude - "+num(e.Lat0)+") + "+num(e.Lng0)+" THEN 1 ELSE 0 END")
+			}
+		}
+		if len(terms) == 0 {
+			continue
+		}
+		ors = append(ors, "(("+strings.Join(terms, " + ")+") % 2 = 1)")
+	}
+	if len(ors) == 0 {
+		return "1 = 0"
+	}
+	return "(" + strings.Join(ors, " OR ") + ")"
+}
+
+// distanceExpr is geo.DistanceMi in SQL. It is NULL for listings without coordinates.
+func distanceExpr(d dialect, p types.GeoPoint) (string, []any) {
+	expr := fmt.Sprintf(`%g * 2 * asin(sqrt(%s(1, `+
+		`power(sin(radians(l.latitude - ?) / 2), 2) + `+
+		`cos(radians(?)) * cos(radians(l.latitude)) * power(sin(radians(l.longitude - ?) / 2), 2))))`,
+		geo.EarthRadiusMi, d.least)
+	return expr, []any{p.Lat, p.Lat, p.Lng}
+}
+
+// sortExpr is the SQL twin of paging.Sort.Value, including the sentinel that
+// keeps missing values last. Its args must precede any args of clauses that
+// follow it in the statement.
+func sortExpr(d dialect, s paging.Sort, f types.SearchFilters) (string, []any) {
+	missing := fmt.Sprintf("%g", s.Missing())
+	var expr string
+	var args []any
+	switch s.Key {
+	case "price":
+		expr = "l.price"
+	case "sqft":
+		expr = "CASE WHEN l.sqft > 0 THEN l.sqft ELSE " + missing + " END"
+	case "price_per_sqft":
+		expr = "CASE WHEN l.sqft > 0 THEN CAST(l.price AS DOUBLE PRECISION) / l.sqft ELSE " + missing + " END"
+	case "year_built":
+		expr = "CASE WHEN l.year_built > 0 THEN l.year_built ELSE " + missing + " END"
+	case "newest":
+		expr = "l.listed_at"
+	case "monthly_cost":
+		// Rounded like MonthlyCost.Total, which the in-memory sort uses.
+		expr = "floor(" + monthlyExpr(f) + " + 0.5)"
+	case "distance":
+		if f.Near == nil {
+			expr = missing
+			break
+		}
+		var dist string
+		dist, args = distanceExpr(d, *f.Near)
+		expr = "COALESCE(" + dist + ", " + missing + ")"
+	default:
+		expr = "0"
+	}
+	return "CAST(" + expr + " AS DOUBLE PRECISION)", args
+}
@@ -0,0 +1,45 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"home-finder/internal/market"
+	"home-finder/internal/types"
+)
+
+// ErrNotFound is returned when a listing ID is not present in the store.
+var ErrNotFound = errors.New("listing not found")
+
+// ListingRepository persists normalized listings and answers searches against them.
+type ListingRepository interface {
+	// Upsert inserts or replaces listings keyed by ID and refreshes their last-seen time.
+	Upsert(ctx context.Context, listings ...types.Listing) error
+	// Get returns a single listing, with LastSeen set, or ErrNotFound.
+	Get(ctx context.Context, id string) (types.Listing, error)
+	// Search applies the same semantics as the API's in-memory filter, then the
+	// sort and keyset window in page. A zero page returns every match.
+	Search(ctx context.Context, filters types.SearchFilters, page types.PageRequest) (types.SearchPage, error)
+	// Facets counts the named facets (see package facet) over the matches,
+	// each with its own filter removed.
+	Facets(ctx context.Context, filters types.SearchFilters, names []string) (types.Facets, error)
+	// SimilarPhotos finds stored listing photos whose perceptual hash is
+	// within maxDistance bits of dhash, for spotting stock shots.
+	SimilarPhotos(ctx context.Context, dhash string, maxDistance int) ([]PhotoMatch, error)
+	// PhotoSightings is SimilarPhotos over every listing ever stored,
+	// expired ones included, with when each first showed the photo.
+	PhotoSightings(ctx context.Context, dhash string, maxDistance int) ([]PhotoMatch, error)
+	// History returns a listing's price and status events, oldest first, or
+	// ErrNotFound for IDs never stored. It survives expiry.
+	History(ctx context.Context, id string) ([]types.HistoryEvent, error)
+	// MarketSeries returns the histories of every listing ever stored, of
+	// any status and expired ones included, matching f's location and
+	// property types.
+	MarketSeries(ctx context.Context, f types.SearchFilters) ([]market.Series, error)
+	// Delete removes a listing; deleting an unknown ID returns ErrNotFound.
+	Delete(ctx context.Context, id string) error
+	// ExpireBefore removes listings not seen by an upsert since cutoff and reports how many were removed.
+	ExpireBefore(ctx context.Context, cutoff time.Time) (int, error)
+	Close() error
+}
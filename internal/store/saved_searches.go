@@ -0,0 +1,222 @@
+package store
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"home-finder/internal/types"
+)
+
+// ErrDuplicateSearch is returned when a user saves filters equivalent to
+// one of their existing saved searches.
+var ErrDuplicateSearch = errors.New("an equivalent saved search exists")
+
+// SavedSearch is a user's named filter set. Filters are stored in canonical
+// form (see types.SearchFilters.Canonical).
+type SavedSearch struct {
+	ID           int64               `json:"id"`
+	UserID       string              `json:"-"`
+	Name         string              `json:"name"`
+	Filters      types.SearchFilters `json:"filters"`
+	Sort         string              `json:"sort,omitempty"`
+	CreatedAt    time.Time           `json:"createdAt"`
+	UpdatedAt    time.Time           `json:"updatedAt"`
+	LastViewedAt *time.Time          `json:"lastViewedAt,omitempty"`
+}
+
+// SavedSearchStore persists saved searches and which listings each has
+// shown its owner. Every call is scoped to a user; another user's search is
+// ErrNotFound.
+type SavedSearchStore interface {
+	// CreateSavedSearch stores s and returns it with its ID and timestamps.
+	// An equivalent existing search is returned with ErrDuplicateSearch.
+	CreateSavedSearch(ctx context.Context, s SavedSearch) (SavedSearch, error)
+	SavedSearch(ctx context.Context, userID string, id int64) (SavedSearch, error)
+	SavedSearches(ctx context.Context, userID string) ([]SavedSearch, error)
+	// UpdateSavedSearch replaces the name, filters and sort of s.ID, with
+	// the same duplicate check as CreateSavedSearch.
+	UpdateSavedSearch(ctx context.Context, s SavedSearch) (SavedSearch, error)
+	DeleteSavedSearch(ctx context.Context, userID string, id int64) error
+	// MarkSavedSearchViewed records that listingIDs were shown at the given
+	// time and returns those the search had not shown before.
+	MarkSavedSearchViewed(ctx context.Context, userID string, id int64, listingIDs []string, at time.Time) ([]string, error)
+}
+
+const savedSearchColumns = `id, user_id, name, filters, sort, created_at, updated_at, last_viewed_at`
+
+func (s *SQLStore) CreateSavedSearch(ctx context.Context, ss SavedSearch) (SavedSearch, error) {
+	filters, key, err := encodeSavedFilters(ss.Filters)
+	if err != nil {
+		return SavedSearch{}, err
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return SavedSearch{}, err
+	}
+	defer tx.Rollback()
+	if existing, err := s.savedSearchByKey(ctx, tx, ss.UserID, key); err == nil {
+		return existing, ErrDuplicateSearch
+	} else if !errors.Is(err, ErrNotFound) {
+		return SavedSearch{}, err
+	}
+	now := time.Now().UTC()
+	var id int64
+	if err := tx.QueryRowContext(ctx, s.dialect.rebind(`INSERT INTO saved_searches
+		(user_id, name, filters, filters_key, sort, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?) RETURNING id`),
+		ss.UserID, ss.Name, filters, key, ss.Sort, now, now).Scan(&id); err != nil {
+		return SavedSearch{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return SavedSearch{}, err
+	}
+	return s.SavedSearch(ctx, ss.UserID, id)
+}
+
+func (s *SQLStore) SavedSearch(ctx context.Context, userID string, id int64) (SavedSearch, error) {
+	row := s.db.QueryRowContext(ctx, s.dialect.rebind(`SELECT `+savedSearchColumns+` FROM saved_searches WHERE user_id = ? AND id = ?`), userID, id)
+	return scanSavedSearch(row)
+}
+
+func (s *SQLStore) SavedSearches(ctx context.Context, userID string) ([]SavedSearch, error) {
+	rows, err := s.db.QueryContext(ctx, s.dialect.rebind(`SELECT `+savedSearchColumns+` FROM saved_searches WHERE user_id = ? ORDER BY id`), userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := []SavedSearch{}
+	for rows.Next() {
+		ss, err := scanSavedSearch(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, ss)
+	}
+	return out, rows.Err()
+}
+
+func (s *SQLStore) UpdateSavedSearch(ctx context.Context, ss SavedSearch) (SavedSearch, error) {
+	filters, key, err := encodeSavedFilters(ss.Filters)
+	if err != nil {
+		return SavedSearch{}, err
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return SavedSearch{}, err
+	}
+	defer tx.Rollback()
+	if existing, err := s.savedSearchByKey(ctx, tx, ss.UserID, key); err == nil && existing.ID != ss.ID {
+		return existing, ErrDuplicateSearch
+	} else if err != nil && !errors.Is(err, ErrNotFound) {
+		return SavedSearch{}, err
+	}
+	res, err := tx.ExecContext(ctx, s.dialect.rebind(`UPDATE saved_searches SET name = ?, filters = ?, filters_key = ?, sort = ?, updated_at = ?
+		WHERE user_id = ? AND id = ?`), ss.Name, filters, key, ss.Sort, time.Now().UTC(), ss.UserID, ss.ID)
+	if err != nil {
+		return SavedSearch{}, err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return SavedSearch{}, err
+	} else if n == 0 {
+		return SavedSearch{}, ErrNotFound
+	}
+	if err := tx.Commit(); err != nil {
+		return SavedSearch{}, err
+	}
+	return s.SavedSearch(ctx, ss.UserID, ss.ID)
+}
+
+func (s *SQLStore) DeleteSavedSearch(ctx context.Context, userID string, id int64) error {
+	res, err := s.db.ExecContext(ctx, s.dialect.rebind(`DELETE FROM saved_searches WHERE user_id = ? AND id = ?`), userID, id)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *SQLStore) MarkSavedSearchViewed(ctx context.Context, userID string, id int64, listingIDs []string, at time.Time) ([]string, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	res, err := tx.ExecContext(ctx, s.dialect.rebind(`UPDATE saved_searches SET last_viewed_at = ? WHERE user_id = ? AND id = ?`), at.UTC(), userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return nil, err
+	} else if n == 0 {
+		return nil, ErrNotFound
+	}
+	insert := s.dialect.rebind(`INSERT INTO saved_search_seen (search_id, listing_id) VALUES (?, ?) ON CONFLICT DO NOTHING`)
+	var unseen []string
+	for _, lid := range listingIDs {
+		res, err := tx.ExecContext(ctx, insert, id, lid)
+		if err != nil {
+			return nil, fmt.Errorf("mark %s seen: %w", lid, err)
+		}
+		if n, err := res.RowsAffected(); err != nil {
+			return nil, err
+		} else if n > 0 {
+			unseen = append(unseen, lid)
+		}
+	}
+	return unseen, tx.Commit()
+}
+
+func (s *SQLStore) savedSearchByKey(ctx context.Context, tx *sql.Tx, userID, key string) (SavedSearch, error) {
+	row := tx.QueryRowContext(ctx, s.dialect.rebind(`SELECT `+savedSearchColumns+` FROM saved_searches WHERE user_id = ? AND filters_key = ?`), userID, key)
+	return scanSavedSearch(row)
+}
+
+// encodeSavedFilters returns the canonical JSON of f and the key that makes
+// equivalent filter sets collide.
+func encodeSavedFilters(f types.SearchFilters) (string, string, error) {
+	raw, err := f.CanonicalJSON()
+	if err != nil {
+		return "", "", fmt.Errorf("encode filters: %w", err)
+	}
+	sum := sha256.Sum256(raw)
+	return string(raw), hex.EncodeToString(sum[:]), nil
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanSavedSearch(row rowScanner) (SavedSearch, error) {
+	var (
+		ss      SavedSearch
+		filters string
+		viewed  sql.NullTime
+	)
+	err := row.Scan(&ss.ID, &ss.UserID, &ss.Name, &filters, &ss.Sort, &ss.CreatedAt, &ss.UpdatedAt, &viewed)
+	if errors.Is(err, sql.ErrNoRows) {
+		return SavedSearch{}, ErrNotFound
+	}
+	if err != nil {
+		return SavedSearch{}, err
+	}
+	if err := json.Unmarshal([]byte(filters), &ss.Filters); err != nil {
+		return SavedSearch{}, fmt.Errorf("decode saved filters: %w", err)
+	}
+	ss.CreatedAt, ss.UpdatedAt = ss.CreatedAt.UTC(), ss.UpdatedAt.UTC()
+	if viewed.Valid {
+		t := viewed.Time.UTC()
+		ss.LastViewedAt = &t
+	}
+	return ss, nil
+}
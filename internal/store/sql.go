@@ -0,0 +1,565 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"home-finder/internal/amenity"
+	"home-finder/internal/filterexpr"
+	"home-finder/internal/fulltext"
+	"home-finder/internal/geo"
+	"home-finder/internal/history"
+	"home-finder/internal/paging"
+	"home-finder/internal/types"
+)
+
+// SQLStore is a ListingRepository backed by database/sql. Filter columns are
+// denormalized for indexing; the full listing is kept as JSON in the data column.
+type SQLStore struct {
+	db      *sql.DB
+	dialect dialect
+}
+
+// Open connects to the store named by a DATABASE_URL style connection string.
+// Call Migrate before serving traffic.
+func Open(ctx context.Context, databaseURL string) (*SQLStore, error) {
+	scheme, _, ok := strings.Cut(databaseURL, "://")
+	if !ok {
+		return nil, fmt.Errorf("database url %q has no scheme", databaseURL)
+	}
+	var (
+		s   *SQLStore
+		err error
+	)
+	switch scheme {
+	case "postgres", "postgresql":
+		s, err = openPostgres(databaseURL)
+	case "sqlite", "sqlite3":
+		s, err = openSQLite(databaseURL)
+	default:
+		return nil, fmt.Errorf("unsupported database scheme %q", scheme)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := s.db.PingContext(ctx); err != nil {
+		s.db.Close()
+		return nil, fmt.Errorf("connect %s: %w", s.dialect.name, err)
+	}
+	return s, nil
+}
+
+func (s *SQLStore) Close() error {
+	return s.db.Close()
+}
+
+func (s *SQLStore) Upsert(ctx context.Context, listings ...types.Listing) error {
+	if len(listings) == 0 {
+		return nil
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	upsert := s.dialect.rebind(`INSERT INTO listings (
+		id, title, price, address, city, state, zip, latitude, longitude, beds, baths, sqft, lot_sqft,
+		year_built, stories, garage_spaces, has_rv_parking, has_pool, has_waterfront,
+		has_view, has_basement, has_fireplace, is_new_build, is_fixer, has_adu,
+		hoa_fee, property_type, tags_text, source, data, listed_at, original_price, status, sold_at, created_at, updated_at
+	) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	ON CONFLICT (id) DO UPDATE SET
+		title = excluded.title, price = excluded.price, address = excluded.address,
+		city = excluded.city, state = excluded.state, zip = excluded.zip,
+		latitude = excluded.latitude, longitude = excluded.longitude,
+		beds = excluded.beds, baths = excluded.baths, sqft = excluded.sqft,
+		lot_sqft = excluded.lot_sqft, year_built = excluded.year_built,
+		stories = excluded.stories, garage_spaces = excluded.garage_spaces,
+		has_rv_parking = excluded.has_rv_parking, has_pool = excluded.has_pool,
+		has_waterfront = excluded.has_waterfront, has_view = excluded.has_view,
+		has_basement = excluded.has_basement, has_fireplace = excluded.has_fireplace,
+		is_new_build = excluded.is_new_build, is_fixer = excluded.is_fixer,
+		has_adu = excluded.has_adu, hoa_fee = excluded.hoa_fee,
+		property_type = excluded.property_type, tags_text = excluded.tags_text,
+		source = excluded.source, data = excluded.data, updated_at = excluded.updated_at,
+		original_price = excluded.original_price, status = excluded.status, sold_at = excluded.sold_at,
+		listed_at = CASE WHEN ? THEN excluded.listed_at ELSE listings.listed_at END`)
+	clearTags := s.dialect.rebind(`DELETE FROM listing_tags WHERE listing_id = ?`)
+	insertTag := s.dialect.rebind(`INSERT INTO listing_tags (listing_id, tag, vision, confidence) VALUES (?, ?, ?, ?) ON CONFLICT DO NOTHING`)
+
+	now := time.Now().UTC()
+	for _, l := range listings {
+		if l.ID == "" {
+			return errors.New("listing id is required")
+		}
+		l.DistanceMi, l.Score, l.LastSeen, l.MonthlyCost = 0, 0, nil, nil
+		l.Status = types.NormalizeStatus(l.Status)
+		amenity.Reconcile(&l)
+		relisted, err := recordHistory(ctx, tx, s.dialect, &l, now)
+		if err != nil {
+			return fmt.Errorf("record history %s: %w", l.ID, err)
+		}
+		// Without a source list date, newest falls back to when we first saw
+		// the listing, less any days on market the source reports. A relist
+		// starts the clock again.
+		listedAt := now.Add(-time.Duration(l.DaysOnMarket) * 24 * time.Hour).Unix()
+		switch {
+		case l.ListDate != nil:
+			listedAt = l.ListDate.Unix()
+		case relisted:
+			listedAt = now.Unix()
+		}
+		l.DaysOnMarket = 0
+		data, err := json.Marshal(l)
+		if err != nil {
+			return fmt.Errorf("encode listing %s: %w", l.ID, err)
+		}
+		// Unknown coordinates are stored as NULL so geo predicates skip them.
+		var lat, lng any
+		if l.HasLocation() {
+			lat, lng = l.Latitude, l.Longitude
+		}
+		_, err = tx.ExecContext(ctx, upsert,
+			l.ID, l.Title, l.Price, l.Address, l.City, l.State, l.Zip, lat, lng, l.Beds, l.Baths, l.Sqft, l.LotSqft,
+			l.YearBuilt, l.Stories, l.GarageSpaces, l.HasRVParking, l.HasPool, l.HasWaterfront,
+			l.HasView, l.HasBasement, l.HasFireplace, l.IsNewBuild, l.IsFixer, l.HasADU,
+			l.HOAFee, l.PropertyType, strings.ToLower(strings.Join(l.Tags, " ")), l.Source, string(data),
+			listedAt, l.OriginalPrice, l.Status, soldAt(l), now, now, l.ListDate != nil || relisted,
+		)
+		if err != nil {
+			return fmt.Errorf("upsert listing %s: %w", l.ID, err)
+		}
+		if _, err := tx.ExecContext(ctx, clearTags, l.ID); err != nil {
+			return fmt.Errorf("clear tags %s: %w", l.ID, err)
+		}
+		for _, t := range l.Tags {
+			if _, err := tx.ExecContext(ctx, insertTag, l.ID, normalizeTag(t), false, nil); err != nil {
+				return fmt.Errorf("insert tag %s: %w", l.ID, err)
+			}
+		}
+		for _, t := range l.VisionTags {
+			// Unscored vision tags keep a NULL confidence and fail any threshold.
+			var confidence any
+			if score, ok := visionScore(l, t); ok {
+				confidence = score
+			}
+			if _, err := tx.ExecContext(ctx, insertTag, l.ID, normalizeTag(t), true, confidence); err != nil {
+				return fmt.Errorf("insert vision tag %s: %w", l.ID, err)
+			}
+		}
+		if err := writeScoredTags(ctx, tx, s.dialect, l); err != nil {
+			return fmt.Errorf("insert scored tags %s: %w", l.ID, err)
+		}
+		if err := writeTerms(ctx, tx, s.dialect, l); err != nil {
+			return fmt.Errorf("index terms %s: %w", l.ID, err)
+		}
+		if err := writePhotos(ctx, tx, s.dialect, l, now); err != nil {
+			return fmt.Errorf("index photos %s: %w", l.ID, err)
+		}
+		if s.dialect.writeGeo != nil {
+			if err := s.dialect.writeGeo(ctx, tx, l); err != nil {
+				return fmt.Errorf("index location %s: %w", l.ID, err)
+			}
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	s.optimize(ctx)
+	return nil
+}
+
+// optimize refreshes the planner statistics when the backend leaves that to
+// the application. Without them SQLite prefers the status index over the
+// spatial one. They only steer the planner, so a failed refresh is left to
+// the next write.
+func (s *SQLStore) optimize(ctx context.Context) {
+	if s.dialect.optimize != "" {
+		_, _ = s.db.ExecContext(ctx, s.dialect.optimize)
+	}
+}
+
+func (s *SQLStore) Get(ctx context.Context, id string) (types.Listing, error) {
+	var data string
+	var seen time.Time
+	var listedAt int64
+	err := s.db.QueryRowContext(ctx, s.dialect.rebind(`SELECT data, updated_at, listed_at FROM listings WHERE id = ?`), id).Scan(&data, &seen, &listedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return types.Listing{}, ErrNotFound
+	}
+	if err != nil {
+		return types.Listing{}, err
+	}
+	var l types.Listing
+	if err := json.Unmarshal([]byte(data), &l); err != nil {
+		return types.Listing{}, fmt.Errorf("decode listing %s: %w", id, err)
+	}
+	seen = seen.UTC()
+	l.LastSeen = &seen
+	l.DaysOnMarket = history.DaysOnMarket(time.Unix(listedAt, 0), time.Now())
+	return l, nil
+}
+
+func (s *SQLStore) Search(ctx context.Context, filters types.SearchFilters, page types.PageRequest) (types.SearchPage, error) {
+	sort, err := paging.ParseSort(page.Sort)
+	if err != nil {
+		return types.SearchPage{}, err
+	}
+	cursor, err := paging.DecodeCursor(page.Cursor, sort)
+	if err != nil {
+		return types.SearchPage{}, err
+	}
+	if _, err := filterexpr.Parse(filters.Filter); err != nil {
+		return types.SearchPage{}, err
+	}
+
+	if query := fulltext.ParseQuery(filters.Query); !query.Empty() {
+		return s.searchText(ctx, filters, query, sort, page.Limit, cursor)
+	}
+
+	where, args := buildWhere(s.dialect, filters)
+	var out types.SearchPage
+	if err := s.db.QueryRowContext(ctx, s.dialect.rebind(`SELECT COUNT(*) FROM listings l WHERE `+where), args...).Scan(&out.Total); err != nil {
+		return types.SearchPage{}, err
+	}
+
+	expr, exprArgs := sortExpr(s.dialect, sort, filters)
+	if cursor != nil {
+		cmp := ">"
+		if sort.Desc {
+			cmp = "<"
+		}
+		where += " AND (" + expr + " " + cmp + " ? OR (" + expr + " = ? AND l.id > ?))"
+		args = append(args, exprArgs...)
+		args = append(args, cursor.Value)
+		args = append(args, exprArgs...)
+		args = append(args, cursor.Value, cursor.ID)
+	}
+	dir := "ASC"
+	if sort.Desc {
+		dir = "DESC"
+	}
+	query := `SELECT l.data, l.listed_at, ` + expr + ` AS sort_value FROM listings l WHERE ` + where + ` ORDER BY sort_value ` + dir + `, l.id ASC`
+	if page.Limit > 0 {
+		// One extra row tells us whether another page exists.
+		query += ` LIMIT ?`
+		args = append(args, page.Limit+1)
+	}
+	// The sort expression is selected first, so its args lead the WHERE args.
+	rows, err := s.db.QueryContext(ctx, s.dialect.rebind(query), append(exprArgs, args...)...)
+	if err != nil {
+		return types.SearchPage{}, err
+	}
+	defer rows.Close()
+
+	now := time.Now()
+	var lastValue float64
+	for rows.Next() {
+		var data string
+		var listedAt int64
+		var value float64
+		if err := rows.Scan(&data, &listedAt, &value); err != nil {
+			return types.SearchPage{}, err
+		}
+		if page.Limit > 0 && len(out.Results) == page.Limit {
+			last := out.Results[len(out.Results)-1]
+			out.NextCursor = paging.Cursor{Sort: sort.String(), Value: lastValue, ID: last.ID}.Encode()
+			break
+		}
+		var l types.Listing
+		if err := json.Unmarshal([]byte(data), &l); err != nil {
+			return types.SearchPage{}, fmt.Errorf("decode listing: %w", err)
+		}
+		l.DaysOnMarket = history.DaysOnMarket(time.Unix(listedAt, 0), now)
+		if filters.Near != nil && l.HasLocation() {
+			l.DistanceMi = geo.DistanceMi(*filters.Near, geo.Point(l))
+		}
+		out.Results = append(out.Results, l)
+		lastValue = value
+	}
+	return out, rows.Err()
+}
+
+// searchText serves queries with free text. SQL narrows the candidates to
+// listings holding every query term and passing the other filters, and
+// returns where those terms occur in each; scoring, phrase matching and
+// paging run in Go over just that, and only the page's listings are loaded.
+func (s *SQLStore) searchText(ctx context.Context, filters types.SearchFilters, query fulltext.Query, sort paging.Sort, limit int, cursor *paging.Cursor) (types.SearchPage, error) {
+	terms := query.AllTerms()
+	stats, err := s.textStats(ctx, terms)
+	if err != nil {
+		return types.SearchPage{}, fmt.Errorf("text stats: %w", err)
+	}
+	where, args := buildWhere(s.dialect, filters)
+	expr, exprArgs := sortExpr(s.dialect, sort, filters)
+	in := strings.TrimSuffix(strings.Repeat("?, ", len(terms)), ", ")
+	termArgs := make([]any, len(terms))
+	for i, t := range terms {
+		termArgs[i] = t
+	}
+	rows, err := s.db.QueryContext(ctx, s.dialect.rebind(`SELECT l.id, `+expr+` AS sort_value, lt.field, lt.term, lt.positions, fl.length
+		FROM listings l
+		JOIN listing_terms lt ON lt.listing_id = l.id
+		JOIN listing_field_lengths fl ON fl.listing_id = lt.listing_id AND fl.field = lt.field
+		WHERE lt.term IN (`+in+`) AND `+where), append(append(exprArgs, termArgs...), args...)...)
+	if err != nil {
+		return types.SearchPage{}, err
+	}
+	defer rows.Close()
+
+	fields := make(map[string]int, fulltext.NumFields)
+	for f, name := range fulltext.FieldNames {
+		fields[name] = f
+	}
+	var ids []string
+	matches := map[string]*fulltext.Match{}
+	values := map[string]float64{}
+	for rows.Next() {
+		var id, field, term, positions string
+		var value float64
+		var length int
+		if err := rows.Scan(&id, &value, &field, &term, &positions, &length); err != nil {
+			return types.SearchPage{}, err
+		}
+		f, ok := fields[field]
+		if !ok {
+			continue
+		}
+		pos, err := decodePositions(positions)
+		if err != nil {
+			return types.SearchPage{}, err
+		}
+		m := matches[id]
+		if m == nil {
+			m = &fulltext.Match{}
+			matches[id] = m
+			values[id] = value
+			ids = append(ids, id)
+		}
+		m.Add(term, f, pos, length)
+	}
+	if err := rows.Err(); err != nil {
+		return types.SearchPage{}, err
+	}
+	rows.Close()
+
+	var hits []types.Listing
+	for _, id := range ids {
+		if score, ok := fulltext.ScoreMatch(query, *matches[id], stats); ok {
+			hits = append(hits, types.Listing{ID: id, Score: score})
+		}
+	}
+	value := func(l types.Listing) float64 { return values[l.ID] }
+	if sort.Key == "relevance" {
+		value = sort.Value
+	}
+	page := paging.PaginateBy(hits, sort, limit, cursor, value)
+	if len(page.Results) == 0 {
+		return page, nil
+	}
+
+	pageArgs := make([]any, len(page.Results))
+	for i, l := range page.Results {
+		pageArgs[i] = l.ID
+	}
+	in = strings.TrimSuffix(strings.Repeat("?, ", len(pageArgs)), ", ")
+	rows, err = s.db.QueryContext(ctx, s.dialect.rebind(`SELECT l.data, l.listed_at FROM listings l WHERE l.id IN (`+in+`)`), pageArgs...)
+	if err != nil {
+		return types.SearchPage{}, err
+	}
+	defer rows.Close()
+	now := time.Now()
+	loaded := make(map[string]types.Listing, len(page.Results))
+	for rows.Next() {
+		var data string
+		var listedAt int64
+		if err := rows.Scan(&data, &listedAt); err != nil {
+			return types.SearchPage{}, err
+		}
+		var l types.Listing
+		if err := json.Unmarshal([]byte(data), &l); err != nil {
+			return types.SearchPage{}, fmt.Errorf("decode listing: %w", err)
+		}
+		l.DaysOnMarket = history.DaysOnMarket(time.Unix(listedAt, 0), now)
+		if filters.Near != nil && l.HasLocation() {
+			l.DistanceMi = geo.DistanceMi(*filters.Near, geo.Point(l))
+		}
+		loaded[l.ID] = l
+	}
+	if err := rows.Err(); err != nil {
+		return types.SearchPage{}, err
+	}
+	results := make([]types.Listing, 0, len(page.Results))
+	for _, hit := range page.Results {
+		l, ok := loaded[hit.ID]
+		if !ok {
+			// Deleted since the candidates were read.
+			continue
+		}
+		l.Score = hit.Score
+		results = append(results, l)
+	}
+	page.Results = results
+	return page, nil
+}
+
+// Delete forgets a listing along with its history, unlike expiry, which
+// keeps the history so a returning listing is recognized as relisted.
+func (s *SQLStore) Delete(ctx context.Context, id string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := forgetTerms(ctx, tx, s.dialect, "l.id = ?", id); err != nil {
+		return err
+	}
+	res, err := tx.ExecContext(ctx, s.dialect.rebind(`DELETE FROM listings WHERE id = ?`), id)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	if _, err := tx.ExecContext(ctx, s.dialect.rebind(`DELETE FROM listing_history WHERE listing_id = ?`), id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *SQLStore) ExpireBefore(ctx context.Context, cutoff time.Time) (int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	if err := forgetTerms(ctx, tx, s.dialect, "l.updated_at < ?", cutoff.UTC()); err != nil {
+		return 0, err
+	}
+	res, err := tx.ExecContext(ctx, s.dialect.rebind(`DELETE FROM listings WHERE updated_at < ?`), cutoff.UTC())
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	return int(n), tx.Commit()
+}
+
+func visionScore(l types.Listing, tag string) (float64, bool) {
+	if l.Vision == nil {
+		return 0, false
+	}
+	score, ok := l.Vision.Scores[normalizeTag(tag)]
+	return score, ok
+}
+
+// writeScoredTags stores the tags l's vision analysis scored below the
+// worker's cutoff as scored_only vision rows, so a min_vision_confidence
+// under VISION_MIN_CONFIDENCE can still match them; without a threshold
+// only VisionTags count.
+func writeScoredTags(ctx context.Context, tx *sql.Tx, d dialect, l types.Listing) error {
+	if l.Vision == nil {
+		return nil
+	}
+	listed := make(map[string]bool, len(l.VisionTags))
+	for _, t := range l.VisionTags {
+		listed[normalizeTag(t)] = true
+	}
+	insert := d.rebind(`INSERT INTO listing_tags (listing_id, tag, vision, confidence, scored_only) VALUES (?, ?, ?, ?, ?) ON CONFLICT DO NOTHING`)
+	for _, t := range l.Vision.ScoredTags(0) {
+		if listed[normalizeTag(t)] {
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, insert, l.ID, normalizeTag(t), true, l.Vision.Scores[t], true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// backfillScoredTags adds the scored_only rows for listings stored before
+// they were kept.
+func backfillScoredTags(ctx context.Context, tx *sql.Tx, d dialect) error {
+	rows, err := tx.QueryContext(ctx, `SELECT data FROM listings`)
+	if err != nil {
+		return err
+	}
+	var listings []types.Listing
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			rows.Close()
+			return err
+		}
+		var l types.Listing
+		if err := json.Unmarshal([]byte(data), &l); err != nil {
+			rows.Close()
+			return fmt.Errorf("decode listing: %w", err)
+		}
+		listings = append(listings, l)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for _, l := range listings {
+		if err := writeScoredTags(ctx, tx, d, l); err != nil {
+			return fmt.Errorf("backfill scored tags %s: %w", l.ID, err)
+		}
+	}
+	return nil
+}
+
+func normalizeTag(t string) string {
+	return strings.ToLower(strings.TrimSpace(t))
+}
+
+// dialect captures the small differences between supported SQL backends.
+type dialect struct {
+	name       string
+	numbered   bool // $1, $2 placeholders instead of ?
+	migrations []string
+	// least is the two-argument minimum function (LEAST or MIN).
+	least string
+	// migrationLock serializes concurrent migrators when the backend needs it.
+	migrationLock string
+	// inBox is the spatial index lookup for listings (aliased l) inside a
+	// box that does not cross the antimeridian.
+	inBox func(b types.BBox) (string, []any)
+	// writeGeo keeps a listing's spatial index entry current when the
+	// backend does not index the listings table itself.
+	writeGeo func(ctx context.Context, tx *sql.Tx, l types.Listing) error
+	// optimize refreshes planner statistics, where the backend does not
+	// keep them itself.
+	optimize string
+}
+
+// rebind rewrites ? placeholders for dialects that use numbered parameters.
+func (d dialect) rebind(query string) string {
+	if !d.numbered {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			fmt.Fprintf(&b, "$%d", n)
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
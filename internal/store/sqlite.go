@@ -0,0 +1,273 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"home-finder/internal/types"
+
+	_ "modernc.org/sqlite"
+)
+
+var sqliteDialect = dialect{
+	name:       "sqlite",
+	migrations: sqliteMigrations,
+	least:      "MIN",
+	inBox:      sqliteInBox,
+	writeGeo:   writeSQLiteGeo,
+	optimize:   "PRAGMA optimize=0x10002",
+}
+
+var sqliteMigrations = []string{
+	`CREATE TABLE listings (
+		id             TEXT PRIMARY KEY,
+		title          TEXT NOT NULL DEFAULT '',
+		price          INTEGER NOT NULL DEFAULT 0,
+		address        TEXT NOT NULL DEFAULT '',
+		city           TEXT NOT NULL DEFAULT '',
+		state          TEXT NOT NULL DEFAULT '',
+		zip            TEXT NOT NULL DEFAULT '',
+		beds           INTEGER NOT NULL DEFAULT 0,
+		baths          REAL NOT NULL DEFAULT 0,
+		sqft           INTEGER NOT NULL DEFAULT 0,
+		lot_sqft       INTEGER NOT NULL DEFAULT 0,
+		year_built     INTEGER NOT NULL DEFAULT 0,
+		stories        INTEGER NOT NULL DEFAULT 0,
+		garage_spaces  INTEGER NOT NULL DEFAULT 0,
+		has_rv_parking BOOLEAN NOT NULL DEFAULT 0,
+		has_pool       BOOLEAN NOT NULL DEFAULT 0,
+		has_waterfront BOOLEAN NOT NULL DEFAULT 0,
+		has_view       BOOLEAN NOT NULL DEFAULT 0,
+		has_basement   BOOLEAN NOT NULL DEFAULT 0,
+		has_fireplace  BOOLEAN NOT NULL DEFAULT 0,
+		is_new_build   BOOLEAN NOT NULL DEFAULT 0,
+		is_fixer       BOOLEAN NOT NULL DEFAULT 0,
+		has_adu        BOOLEAN NOT NULL DEFAULT 0,
+		hoa_fee        INTEGER NOT NULL DEFAULT 0,
+		property_type  TEXT NOT NULL DEFAULT '',
+		tags_text      TEXT NOT NULL DEFAULT '',
+		source         TEXT NOT NULL DEFAULT '',
+		data           TEXT NOT NULL,
+		created_at     DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		updated_at     DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE TABLE listing_tags (
+		listing_id TEXT NOT NULL REFERENCES listings (id) ON DELETE CASCADE,
+		tag        TEXT NOT NULL,
+		vision     BOOLEAN NOT NULL,
+		PRIMARY KEY (listing_id, tag, vision)
+	);
+	CREATE INDEX listing_tags_tag_idx ON listing_tags (tag, listing_id);
+	CREATE INDEX listings_price_idx ON listings (price);
+	CREATE INDEX listings_beds_baths_idx ON listings (beds, baths);
+	CREATE INDEX listings_sqft_idx ON listings (sqft);
+	CREATE INDEX listings_state_city_idx ON listings (lower(state), lower(city));
+	CREATE INDEX listings_zip_idx ON listings (zip);
+	CREATE INDEX listings_property_type_idx ON listings (lower(property_type));
+	CREATE INDEX listings_updated_at_idx ON listings (updated_at);`,
+	`CREATE TABLE ingest_runs (
+		id           INTEGER PRIMARY KEY AUTOINCREMENT,
+		job          TEXT NOT NULL,
+		source       TEXT NOT NULL,
+		triggered_by TEXT NOT NULL,
+		started_at   DATETIME NOT NULL,
+		finished_at  DATETIME NOT NULL,
+		pages        INTEGER NOT NULL DEFAULT 0,
+		fetched      INTEGER NOT NULL DEFAULT 0,
+		upserted     INTEGER NOT NULL DEFAULT 0,
+		skipped      INTEGER NOT NULL DEFAULT 0,
+		error        TEXT NOT NULL DEFAULT ''
+	);
+	CREATE INDEX ingest_runs_started_at_idx ON ingest_runs (started_at DESC);`,
+	`ALTER TABLE listings ADD COLUMN listed_at INTEGER NOT NULL DEFAULT 0;
+	UPDATE listings SET listed_at = CAST(strftime('%s', substr(created_at, 1, 19)) AS INTEGER);
+	CREATE INDEX listings_listed_at_idx ON listings (listed_at DESC, id);
+	CREATE INDEX listings_price_id_idx ON listings (price, id);
+	CREATE INDEX listings_year_built_idx ON listings (year_built);`,
+	`ALTER TABLE listings ADD COLUMN latitude REAL;
+	ALTER TABLE listings ADD COLUMN longitude REAL;
+	CREATE INDEX listings_lat_lng_idx ON listings (latitude, longitude);`,
+	`CREATE TABLE listing_terms (
+		listing_id TEXT NOT NULL REFERENCES listings (id) ON DELETE CASCADE,
+		field      TEXT NOT NULL,
+		term       TEXT NOT NULL,
+		tf         INTEGER NOT NULL,
+		PRIMARY KEY (listing_id, field, term)
+	);
+	CREATE INDEX listing_terms_term_idx ON listing_terms (term, listing_id);`,
+	`ALTER TABLE listing_tags ADD COLUMN confidence REAL;`,
+	`CREATE TABLE listing_photos (
+		listing_id TEXT NOT NULL REFERENCES listings (id) ON DELETE CASCADE,
+		position   INTEGER NOT NULL,
+		hash       TEXT NOT NULL,
+		dhash      TEXT NOT NULL,
+		band0      INTEGER NOT NULL,
+		band1      INTEGER NOT NULL,
+		band2      INTEGER NOT NULL,
+		band3      INTEGER NOT NULL,
+		PRIMARY KEY (listing_id, position)
+	);
+	CREATE INDEX listing_photos_band0_idx ON listing_photos (band0);
+	CREATE INDEX listing_photos_band1_idx ON listing_photos (band1);
+	CREATE INDEX listing_photos_band2_idx ON listing_photos (band2);
+	CREATE INDEX listing_photos_band3_idx ON listing_photos (band3);`,
+	`CREATE TABLE vision_results (
+		image_hash   TEXT NOT NULL,
+		model        TEXT NOT NULL,
+		version      TEXT NOT NULL,
+		url          TEXT NOT NULL DEFAULT '',
+		tags         TEXT NOT NULL,
+		result_model TEXT NOT NULL DEFAULT '',
+		created_at   DATETIME NOT NULL,
+		PRIMARY KEY (image_hash, model, version)
+	);`,
+	`CREATE TABLE listing_history (
+		listing_id   TEXT NOT NULL,
+		seq          INTEGER NOT NULL,
+		at           DATETIME NOT NULL,
+		event        TEXT NOT NULL,
+		price        INTEGER NOT NULL,
+		status       TEXT NOT NULL,
+		price_change INTEGER NOT NULL DEFAULT 0,
+		PRIMARY KEY (listing_id, seq)
+	);
+	INSERT INTO listing_history (listing_id, seq, at, event, price, status)
+		SELECT id, 1, created_at, 'listed', price, 'active' FROM listings;
+	ALTER TABLE listings ADD COLUMN original_price INTEGER NOT NULL DEFAULT 0;
+	UPDATE listings SET original_price = price;`,
+	`ALTER TABLE listings ADD COLUMN status TEXT NOT NULL DEFAULT 'active';
+	ALTER TABLE listings ADD COLUMN sold_at INTEGER;
+	CREATE INDEX listings_status_idx ON listings (status, sold_at);`,
+	`CREATE TABLE user_settings (
+		user_id    TEXT PRIMARY KEY,
+		financing  TEXT NOT NULL,
+		updated_at DATETIME NOT NULL
+	);`,
+	`CREATE TABLE saved_searches (
+		id             INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id        TEXT NOT NULL,
+		name           TEXT NOT NULL,
+		filters        TEXT NOT NULL,
+		filters_key    TEXT NOT NULL,
+		sort           TEXT NOT NULL DEFAULT '',
+		created_at     DATETIME NOT NULL,
+		updated_at     DATETIME NOT NULL,
+		last_viewed_at DATETIME,
+		UNIQUE (user_id, filters_key)
+	);
+	CREATE TABLE saved_search_seen (
+		search_id  INTEGER NOT NULL REFERENCES saved_searches (id) ON DELETE CASCADE,
+		listing_id TEXT NOT NULL,
+		PRIMARY KEY (search_id, listing_id)
+	);`,
+	`CREATE TABLE photo_sightings (
+		listing_id TEXT NOT NULL,
+		hash       TEXT NOT NULL,
+		dhash      TEXT NOT NULL,
+		band0      INTEGER NOT NULL,
+		band1      INTEGER NOT NULL,
+		band2      INTEGER NOT NULL,
+		band3      INTEGER NOT NULL,
+		first_seen DATETIME NOT NULL,
+		PRIMARY KEY (listing_id, hash)
+	);
+	CREATE INDEX photo_sightings_band0_idx ON photo_sightings (band0);
+	CREATE INDEX photo_sightings_band1_idx ON photo_sightings (band1);
+	CREATE INDEX photo_sightings_band2_idx ON photo_sightings (band2);
+	CREATE INDEX photo_sightings_band3_idx ON photo_sightings (band3);
+	INSERT INTO photo_sightings (listing_id, hash, dhash, band0, band1, band2, band3, first_seen)
+		SELECT p.listing_id, p.hash, p.dhash, p.band0, p.band1, p.band2, p.band3, l.created_at
+		FROM listing_photos p JOIN listings l ON l.id = p.listing_id WHERE true
+		ON CONFLICT DO NOTHING;`,
+	`ALTER TABLE listing_history ADD COLUMN city TEXT NOT NULL DEFAULT '';
+	ALTER TABLE listing_history ADD COLUMN state TEXT NOT NULL DEFAULT '';
+	ALTER TABLE listing_history ADD COLUMN zip TEXT NOT NULL DEFAULT '';
+	ALTER TABLE listing_history ADD COLUMN property_type TEXT NOT NULL DEFAULT '';
+	ALTER TABLE listing_history ADD COLUMN sqft INTEGER NOT NULL DEFAULT 0;
+	UPDATE listing_history SET
+		city = (SELECT l.city FROM listings l WHERE l.id = listing_history.listing_id),
+		state = (SELECT l.state FROM listings l WHERE l.id = listing_history.listing_id),
+		zip = (SELECT l.zip FROM listings l WHERE l.id = listing_history.listing_id),
+		property_type = (SELECT l.property_type FROM listings l WHERE l.id = listing_history.listing_id),
+		sqft = (SELECT l.sqft FROM listings l WHERE l.id = listing_history.listing_id)
+		WHERE listing_id IN (SELECT id FROM listings);`,
+	`ALTER TABLE listing_tags ADD COLUMN scored_only BOOLEAN NOT NULL DEFAULT 0;`,
+	`CREATE VIRTUAL TABLE listing_geo USING rtree (id, min_lat, max_lat, min_lng, max_lng);
+	ALTER TABLE listings ADD COLUMN geo_id INTEGER;
+	INSERT INTO listing_geo (id, min_lat, max_lat, min_lng, max_lng)
+		SELECT rowid, latitude, latitude, longitude, longitude FROM listings
+		WHERE latitude IS NOT NULL AND longitude IS NOT NULL;
+	UPDATE listings SET geo_id = rowid WHERE latitude IS NOT NULL AND longitude IS NOT NULL;
+	CREATE INDEX listings_geo_id_idx ON listings (geo_id);
+	CREATE TRIGGER listings_geo_delete AFTER DELETE ON listings WHEN OLD.geo_id IS NOT NULL BEGIN
+		DELETE FROM listing_geo WHERE id = OLD.geo_id;
+	END;`,
+	`ALTER TABLE listing_terms ADD COLUMN positions TEXT NOT NULL DEFAULT '';
+	CREATE TABLE listing_field_lengths (
+		listing_id TEXT NOT NULL REFERENCES listings (id) ON DELETE CASCADE,
+		field      TEXT NOT NULL,
+		length     INTEGER NOT NULL,
+		PRIMARY KEY (listing_id, field)
+	);
+	CREATE TABLE text_stats (
+		field  TEXT PRIMARY KEY,
+		docs   INTEGER NOT NULL,
+		tokens INTEGER NOT NULL
+	);`,
+}
+
+// sqliteInBox looks listings up in the listing_geo R*Tree. Its single
+// precision bounds are rounded outward, so it finds a superset that the
+// exact coordinate test then trims.
+func sqliteInBox(b types.BBox) (string, []any) {
+	return "l.geo_id IN (SELECT id FROM listing_geo WHERE min_lat <= ? AND max_lat >= ? AND min_lng <= ? AND max_lng >= ?)",
+		[]any{b.MaxLat, b.MinLat, b.MaxLng, b.MinLng}
+}
+
+// writeSQLiteGeo replaces l's listing_geo entry and records its ID in
+// listings.geo_id. The R*Tree keeps its own IDs rather than the listings
+// rowid, which VACUUM may renumber; deleting a listing drops its entry by
+// trigger.
+func writeSQLiteGeo(ctx context.Context, tx *sql.Tx, l types.Listing) error {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM listing_geo WHERE id = (SELECT geo_id FROM listings WHERE id = ?)`, l.ID); err != nil {
+		return err
+	}
+	var geoID any
+	if l.HasLocation() {
+		res, err := tx.ExecContext(ctx, `INSERT INTO listing_geo (min_lat, max_lat, min_lng, max_lng) VALUES (?, ?, ?, ?)`,
+			l.Latitude, l.Latitude, l.Longitude, l.Longitude)
+		if err != nil {
+			return err
+		}
+		if geoID, err = res.LastInsertId(); err != nil {
+			return err
+		}
+	}
+	_, err := tx.ExecContext(ctx, `UPDATE listings SET geo_id = ? WHERE id = ?`, geoID, l.ID)
+	return err
+}
+
+// openSQLite accepts sqlite://path/to/file.db, sqlite:///abs/path.db or
+// sqlite://:memory:. Foreign keys are enabled so tag rows cascade on delete,
+// and timestamps are stored in a sortable text format.
+func openSQLite(databaseURL string) (*SQLStore, error) {
+	path := strings.TrimPrefix(strings.TrimPrefix(databaseURL, "sqlite3://"), "sqlite://")
+	path, _, _ = strings.Cut(path, "?")
+	if path == "" {
+		return nil, fmt.Errorf("sqlite database path is required")
+	}
+	dsn := "file:" + path + "?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_pragma=analysis_limit(1000)&_time_format=sqlite"
+	if path != ":memory:" {
+		dsn += "&_pragma=journal_mode(WAL)"
+	}
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("open sqlite: %w", err)
+	}
+	// SQLite serializes writers; a single connection avoids SQLITE_BUSY churn
+	// and keeps :memory: databases from splitting across connections.
+	db.SetMaxOpenConns(1)
+	return &SQLStore{db: db, dialect: sqliteDialect}, nil
+}
@@ -0,0 +1,76 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"home-finder/internal/types"
+)
+
+// statusCond restricts listings (aliased l) to f's statuses, with sold ones
+// limited to SoldWithinDays. It mirrors api.matchesStatus.
+func statusCond(f types.SearchFilters, now time.Time) (string, []any) {
+	var ors, in []string
+	var args, inArgs []any
+	for _, st := range f.EffectiveStatuses() {
+		if st == types.StatusSold && f.SoldWithinDays > 0 {
+			ors = append(ors, "(l.status = ? AND l.sold_at > ?)")
+			args = append(args, st, now.AddDate(0, 0, -f.SoldWithinDays).Unix())
+			continue
+		}
+		in = append(in, "?")
+		inArgs = append(inArgs, st)
+	}
+	if len(in) > 0 {
+		ors = append(ors, "l.status IN ("+strings.Join(in, ", ")+")")
+		args = append(args, inArgs...)
+	}
+	return "(" + strings.Join(ors, " OR ") + ")", args
+}
+
+// soldAt is the sold_at column value for l: its SoldDate as Unix seconds,
+// or NULL.
+func soldAt(l types.Listing) any {
+	if l.SoldDate == nil {
+		return nil
+	}
+	return l.SoldDate.Unix()
+}
+
+// backfillStatus fills the status and sold_at columns of listings stored
+// before they existed.
+func backfillStatus(ctx context.Context, tx *sql.Tx, d dialect) error {
+	rows, err := tx.QueryContext(ctx, `SELECT data FROM listings`)
+	if err != nil {
+		return err
+	}
+	var listings []types.Listing
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			rows.Close()
+			return err
+		}
+		var l types.Listing
+		if err := json.Unmarshal([]byte(data), &l); err != nil {
+			rows.Close()
+			return fmt.Errorf("decode listing: %w", err)
+		}
+		listings = append(listings, l)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	update := d.rebind(`UPDATE listings SET status = ?, sold_at = ? WHERE id = ?`)
+	for _, l := range listings {
+		if _, err := tx.ExecContext(ctx, update, types.NormalizeStatus(l.Status), soldAt(l), l.ID); err != nil {
+			return fmt.Errorf("backfill status %s: %w", l.ID, err)
+		}
+	}
+	return nil
+}
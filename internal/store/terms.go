@@ -0,0 +1,226 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"home-finder/internal/fulltext"
+	"home-finder/internal/types"
+)
+
+// writeTerms replaces the listing_terms rows for l, the SQL side of the
+// fulltext inverted index, along with its field lengths, and moves the
+// text_stats totals by the difference.
+func writeTerms(ctx context.Context, tx *sql.Tx, d dialect, l types.Listing) error {
+	if _, err := tx.ExecContext(ctx, d.rebind(`DELETE FROM listing_terms WHERE listing_id = ?`), l.ID); err != nil {
+		return err
+	}
+	doc := fulltext.Analyze(l)
+	var rows []string
+	var args []any
+	for f := 0; f < fulltext.NumFields; f++ {
+		for term, pos := range doc.Positions(f) {
+			rows = append(rows, "(?, ?, ?, ?, ?)")
+			args = append(args, l.ID, fulltext.FieldNames[f], term, len(pos), encodePositions(pos))
+		}
+	}
+	if len(rows) > 0 {
+		if _, err := tx.ExecContext(ctx, d.rebind(`INSERT INTO listing_terms (listing_id, field, term, tf, positions) VALUES `+strings.Join(rows, ", ")), args...); err != nil {
+			return err
+		}
+	}
+
+	old := map[string]int{}
+	lengths, err := tx.QueryContext(ctx, d.rebind(`SELECT field, length FROM listing_field_lengths WHERE listing_id = ?`), l.ID)
+	if err != nil {
+		return err
+	}
+	for lengths.Next() {
+		var field string
+		var n int
+		if err := lengths.Scan(&field, &n); err != nil {
+			lengths.Close()
+			return err
+		}
+		old[field] = n
+	}
+	lengths.Close()
+	if err := lengths.Err(); err != nil {
+		return err
+	}
+	putLength := d.rebind(`INSERT INTO listing_field_lengths (listing_id, field, length) VALUES (?, ?, ?)
+		ON CONFLICT (listing_id, field) DO UPDATE SET length = excluded.length`)
+	moveStats := d.rebind(`UPDATE text_stats SET docs = docs + ?, tokens = tokens + ? WHERE field = ?`)
+	for f, field := range fulltext.FieldNames {
+		n := len(doc[f])
+		prev, indexed := old[field]
+		docs := 0
+		if !indexed {
+			docs = 1
+		}
+		if indexed && prev == n {
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, putLength, l.ID, field, n); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, moveStats, docs, n-prev, field); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// forgetTerms takes the listings (aliased l) matching cond out of the
+// text_stats totals. Call it before deleting them; their listing_terms and
+// listing_field_lengths rows go with them by cascade.
+func forgetTerms(ctx context.Context, tx *sql.Tx, d dialect, cond string, args ...any) error {
+	rows, err := tx.QueryContext(ctx, d.rebind(`SELECT fl.field, COUNT(*), SUM(fl.length)
+		FROM listing_field_lengths fl JOIN listings l ON l.id = fl.listing_id
+		WHERE `+cond+` GROUP BY fl.field`), args...)
+	if err != nil {
+		return err
+	}
+	type total struct {
+		field        string
+		docs, tokens int
+	}
+	var totals []total
+	for rows.Next() {
+		var t total
+		if err := rows.Scan(&t.field, &t.docs, &t.tokens); err != nil {
+			rows.Close()
+			return err
+		}
+		totals = append(totals, t)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	moveStats := d.rebind(`UPDATE text_stats SET docs = docs - ?, tokens = tokens - ? WHERE field = ?`)
+	for _, t := range totals {
+		if _, err := tx.ExecContext(ctx, moveStats, t.docs, t.tokens, t.field); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func encodePositions(pos []int) string {
+	parts := make([]string, len(pos))
+	for i, p := range pos {
+		parts[i] = strconv.Itoa(p)
+	}
+	return strings.Join(parts, ",")
+}
+
+func decodePositions(s string) ([]int, error) {
+	if s == "" {
+		return nil, nil
+	}
+	parts := strings.Split(s, ",")
+	pos := make([]int, len(parts))
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil {
+			return nil, fmt.Errorf("term positions %q: %w", s, err)
+		}
+		pos[i] = n
+	}
+	return pos, nil
+}
+
+// reindexTerms rebuilds listing_terms, listing_field_lengths and text_stats
+// for listings stored before they carried positions and lengths.
+func reindexTerms(ctx context.Context, tx *sql.Tx, d dialect) error {
+	seed := d.rebind(`INSERT INTO text_stats (field, docs, tokens) VALUES (?, 0, 0) ON CONFLICT (field) DO NOTHING`)
+	for _, field := range fulltext.FieldNames {
+		if _, err := tx.ExecContext(ctx, seed, field); err != nil {
+			return err
+		}
+	}
+	rows, err := tx.QueryContext(ctx, `SELECT data FROM listings`)
+	if err != nil {
+		return err
+	}
+	var listings []types.Listing
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			rows.Close()
+			return err
+		}
+		var l types.Listing
+		if err := json.Unmarshal([]byte(data), &l); err != nil {
+			rows.Close()
+			return fmt.Errorf("decode listing: %w", err)
+		}
+		listings = append(listings, l)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for _, l := range listings {
+		if err := writeTerms(ctx, tx, d, l); err != nil {
+			return fmt.Errorf("index listing %s: %w", l.ID, err)
+		}
+	}
+	return nil
+}
+
+// textStats reads the BM25 corpus statistics for terms: the totals from
+// text_stats and the document frequencies from the term index.
+func (s *SQLStore) textStats(ctx context.Context, terms []string) (fulltext.Stats, error) {
+	st := fulltext.Stats{DF: make(map[string]int, len(terms))}
+	rows, err := s.db.QueryContext(ctx, `SELECT field, docs, tokens FROM text_stats`)
+	if err != nil {
+		return st, err
+	}
+	for rows.Next() {
+		var field string
+		var docs, tokens int
+		if err := rows.Scan(&field, &docs, &tokens); err != nil {
+			rows.Close()
+			return st, err
+		}
+		st.Docs = max(st.Docs, docs)
+		for f, name := range fulltext.FieldNames {
+			if name == field && docs > 0 {
+				st.AvgLen[f] = float64(tokens) / float64(docs)
+			}
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return st, err
+	}
+
+	if len(terms) == 0 {
+		return st, nil
+	}
+	in := strings.TrimSuffix(strings.Repeat("?, ", len(terms)), ", ")
+	args := make([]any, len(terms))
+	for i, t := range terms {
+		args[i] = t
+	}
+	rows, err = s.db.QueryContext(ctx, s.dialect.rebind(`SELECT term, COUNT(DISTINCT listing_id) FROM listing_terms WHERE term IN (`+in+`) GROUP BY term`), args...)
+	if err != nil {
+		return st, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var term string
+		var df int
+		if err := rows.Scan(&term, &df); err != nil {
+			return st, err
+		}
+		st.DF[term] = df
+	}
+	return st, rows.Err()
+}
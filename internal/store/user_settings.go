@@ -0,0 +1,47 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"home-finder/internal/types"
+)
+
+// UserSettings persists per-user preferences, keyed by the ID the API's
+// callers identify themselves with.
+type UserSettings interface {
+	// Financing returns the user's saved financing assumptions, or
+	// ErrNotFound when they have none.
+	Financing(ctx context.Context, userID string) (types.Financing, error)
+	SetFinancing(ctx context.Context, userID string, f types.Financing) error
+}
+
+func (s *SQLStore) Financing(ctx context.Context, userID string) (types.Financing, error) {
+	var raw string
+	err := s.db.QueryRowContext(ctx, s.dialect.rebind(`SELECT financing FROM user_settings WHERE user_id = ?`), userID).Scan(&raw)
+	if errors.Is(err, sql.ErrNoRows) {
+		return types.Financing{}, ErrNotFound
+	}
+	if err != nil {
+		return types.Financing{}, err
+	}
+	var f types.Financing
+	if err := json.Unmarshal([]byte(raw), &f); err != nil {
+		return types.Financing{}, err
+	}
+	return f, nil
+}
+
+func (s *SQLStore) SetFinancing(ctx context.Context, userID string, f types.Financing) error {
+	raw, err := json.Marshal(f)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx, s.dialect.rebind(`INSERT INTO user_settings (user_id, financing, updated_at) VALUES (?, ?, ?)
+		ON CONFLICT (user_id) DO UPDATE SET financing = excluded.financing, updated_at = excluded.updated_at`),
+		userID, string(raw), time.Now().UTC())
+	return err
+}
@@ -0,0 +1,112 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// VisionResult is a cached vision answer for one image under one model and
+// prompt version.
+type VisionResult struct {
+	ImageHash string
+	Model     string
+	Version   string
+	// URL is where the image was fetched from, so the answer can be redone.
+	URL         string
+	Tags        map[string]float64
+	ResultModel string // model name the API reported, e.g. a dated snapshot
+	CreatedAt   time.Time
+}
+
+// VisionResultCache persists vision answers keyed by image content hash,
+// model and prompt version.
+type VisionResultCache interface {
+	// GetVisionResult returns ErrNotFound when there is no entry.
+	GetVisionResult(ctx context.Context, imageHash, model, version string) (VisionResult, error)
+	PutVisionResult(ctx context.Context, r VisionResult) error
+	// DeleteVisionResults removes every entry for imageHash, or every entry
+	// when imageHash is empty, and reports how many were removed.
+	DeleteVisionResults(ctx context.Context, imageHash string) (int, error)
+	DeleteVisionResult(ctx context.Context, imageHash, model, version string) error
+	// OutdatedVisionResults lists entries made under any other model or
+	// version.
+	OutdatedVisionResults(ctx context.Context, model, version string) ([]VisionResult, error)
+}
+
+func (s *SQLStore) GetVisionResult(ctx context.Context, imageHash, model, version string) (VisionResult, error) {
+	r := VisionResult{ImageHash: imageHash, Model: model, Version: version}
+	var tags string
+	err := s.db.QueryRowContext(ctx, s.dialect.rebind(`SELECT url, tags, result_model, created_at FROM vision_results
+		WHERE image_hash = ? AND model = ? AND version = ?`), imageHash, model, version).
+		Scan(&r.URL, &tags, &r.ResultModel, &r.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return VisionResult{}, ErrNotFound
+	}
+	if err != nil {
+		return VisionResult{}, err
+	}
+	if err := json.Unmarshal([]byte(tags), &r.Tags); err != nil {
+		return VisionResult{}, fmt.Errorf("decode vision result %s: %w", imageHash, err)
+	}
+	return r, nil
+}
+
+func (s *SQLStore) PutVisionResult(ctx context.Context, r VisionResult) error {
+	tags, err := json.Marshal(r.Tags)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx, s.dialect.rebind(`INSERT INTO vision_results (
+		image_hash, model, version, url, tags, result_model, created_at
+	) VALUES (?, ?, ?, ?, ?, ?, ?)
+	ON CONFLICT (image_hash, model, version) DO UPDATE SET
+		url = excluded.url, tags = excluded.tags, result_model = excluded.result_model, created_at = excluded.created_at`),
+		r.ImageHash, r.Model, r.Version, r.URL, string(tags), r.ResultModel, r.CreatedAt.UTC(),
+	)
+	return err
+}
+
+func (s *SQLStore) DeleteVisionResults(ctx context.Context, imageHash string) (int, error) {
+	query, args := `DELETE FROM vision_results`, []any(nil)
+	if imageHash != "" {
+		query, args = query+` WHERE image_hash = ?`, []any{imageHash}
+	}
+	res, err := s.db.ExecContext(ctx, s.dialect.rebind(query), args...)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+func (s *SQLStore) DeleteVisionResult(ctx context.Context, imageHash, model, version string) error {
+	_, err := s.db.ExecContext(ctx, s.dialect.rebind(`DELETE FROM vision_results
+		WHERE image_hash = ? AND model = ? AND version = ?`), imageHash, model, version)
+	return err
+}
+
+func (s *SQLStore) OutdatedVisionResults(ctx context.Context, model, version string) ([]VisionResult, error) {
+	rows, err := s.db.QueryContext(ctx, s.dialect.rebind(`SELECT image_hash, model, version, url, tags, result_model, created_at
+		FROM vision_results WHERE NOT (model = ? AND version = ?) ORDER BY created_at`), model, version)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []VisionResult
+	for rows.Next() {
+		var r VisionResult
+		var tags string
+		if err := rows.Scan(&r.ImageHash, &r.Model, &r.Version, &r.URL, &tags, &r.ResultModel, &r.CreatedAt); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(tags), &r.Tags); err != nil {
+			return nil, fmt.Errorf("decode vision result %s: %w", r.ImageHash, err)
+		}
+		out = append(out, r)
+	}
+	return out, rows.Err()
+}
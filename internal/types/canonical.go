@@ -0,0 +1,71 @@
+package types
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+)
+
+// Canonical rewrites f into a normal form that matches exactly the same
+// listings: values are trimmed, case-insensitive ones lowercased (states
+// uppercased), set-valued lists deduplicated and sorted, and settings that
+// cannot apply, such as a radius without a point, dropped. Two filter sets
+// that only differ in how they were written have equal canonical forms.
+// Filter is only trimmed; callers that can parse it should store the
+// parsed expression's String form first.
+func (f SearchFilters) Canonical() SearchFilters {
+	c := f
+	c.City = strings.ToLower(strings.TrimSpace(f.City))
+	c.State = strings.ToUpper(strings.TrimSpace(f.State))
+	c.Zip = strings.TrimSpace(f.Zip)
+	c.PropertyTypes = canonicalSet(f.PropertyTypes)
+	c.Tags = canonicalSet(f.Tags)
+	c.ExcludeTags = canonicalSet(f.ExcludeTags)
+	c.Verified = canonicalSet(f.Verified)
+	c.Query = strings.ToLower(strings.Join(strings.Fields(f.Query), " "))
+	c.Filter = strings.TrimSpace(f.Filter)
+	if f.Near == nil {
+		c.RadiusMi = 0
+	}
+	if !f.UseVision {
+		c.MinVisionConfidence = 0
+	}
+	c.Statuses = f.EffectiveStatuses()
+	sort.Strings(c.Statuses)
+	sold := false
+	for _, st := range c.Statuses {
+		sold = sold || st == StatusSold
+	}
+	if !sold {
+		c.SoldWithinDays = 0
+	}
+	if len(c.Statuses) == 1 && c.Statuses[0] == StatusActive {
+		c.Statuses = nil
+	}
+	if len(f.Polygons) == 0 {
+		c.Polygons = nil
+	}
+	return c
+}
+
+// CanonicalJSON is the stable encoding of f: the JSON of its canonical
+// form, so equivalent filter sets encode to the same bytes.
+func (f SearchFilters) CanonicalJSON() ([]byte, error) {
+	return json.Marshal(f.Canonical())
+}
+
+// canonicalSet lowercases, trims, deduplicates and sorts values, or returns
+// nil when none are left.
+func canonicalSet(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	var out []string
+	for _, v := range values {
+		v = strings.ToLower(strings.TrimSpace(v))
+		if v != "" && !seen[v] {
+			seen[v] = true
+			out = append(out, v)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
@@ -0,0 +1,15 @@
+package types
+
+// FacetBucket is one value of a facet and the number of matching listings.
+// Histogram buckets also carry their price range; Max is nil on the open top
+// bucket.
+type FacetBucket struct {
+	Value string   `json:"value"`
+	Count int      `json:"count"`
+	Min   *float64 `json:"min,omitempty"`
+	Max   *float64 `json:"max,omitempty"`
+}
+
+// Facets maps a facet name (property_type, beds, tags, price_histogram, city)
+// to its buckets.
+type Facets map[string][]FacetBucket
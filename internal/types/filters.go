@@ -0,0 +1,119 @@
+package types
+
+// SearchFilters is the normalized filter set shared by the API, the listing
+// store and upstream providers. Zero values mean "no constraint". JSON names
+// follow the /search query params; see Canonical for a stable encoding.
+type SearchFilters struct {
+	MinPrice            int       `json:"min_price,omitempty"`
+	MaxPrice            int       `json:"max_price,omitempty"`
+	MinBeds             int       `json:"min_beds,omitempty"`
+	MaxBeds             int       `json:"max_beds,omitempty"`
+	MinBaths            float64   `json:"min_baths,omitempty"`
+	MaxBaths            float64   `json:"max_baths,omitempty"`
+	MinSqft             int       `json:"min_sqft,omitempty"`
+	MaxSqft             int       `json:"max_sqft,omitempty"`
+	MinLotSqft          int       `json:"min_lot_sqft,omitempty"`
+	MaxLotSqft          int       `json:"max_lot_sqft,omitempty"`
+	MinYearBuilt        int       `json:"min_year_built,omitempty"`
+	MaxYearBuilt        int       `json:"max_year_built,omitempty"`
+	MinStories          int       `json:"min_stories,omitempty"`
+	MinGarage           int       `json:"min_garage,omitempty"`
+	MaxHOA              int       `json:"max_hoa,omitempty"`
+	MinHOA              int       `json:"min_hoa,omitempty"`
+	PropertyTypes       []string  `json:"property_types,omitempty"`
+	Tags                []string  `json:"tags,omitempty"`
+	ExcludeTags         []string  `json:"exclude_tags,omitempty"`
+	City                string    `json:"city,omitempty"`
+	State               string    `json:"state,omitempty"`
+	Zip                 string    `json:"zip,omitempty"`
+	Near                *GeoPoint `json:"near,omitempty"`
+	RadiusMi            float64   `json:"radius_mi,omitempty"` // only applied with Near
+	BBox                *BBox     `json:"bbox,omitempty"`
+	Polygons            []Polygon `json:"polygons,omitempty"` // inside any polygon (MultiPolygon semantics)
+	Query               string    `json:"q,omitempty"`
+	Filter              string    `json:"filter,omitempty"` // boolean expression, see package filterexpr
+	UseVision           bool      `json:"use_vision,omitempty"`
+	MinVisionConfidence float64   `json:"min_vision_confidence,omitempty"` // with UseVision, count every tag the photos scored at least this high
+	RequirePool         bool      `json:"pool,omitempty"`
+	RequireWater        bool      `json:"waterfront,omitempty"`
+	RequireView         bool      `json:"view,omitempty"`
+	RequireBasement     bool      `json:"basement,omitempty"`
+	RequireFireplace    bool      `json:"fireplace,omitempty"`
+	RequireADU          bool      `json:"adu,omitempty"`
+	RequireRVParking    bool      `json:"rv_parking,omitempty"`
+	RequireNew          bool      `json:"new_build,omitempty"`
+	RequireFixer        bool      `json:"fixer,omitempty"`
+	PriceReduced        bool      `json:"price_reduced,omitempty"` // asking price below OriginalPrice
+	MaxDaysOnMarket     int       `json:"max_days_on_market,omitempty"`
+	// MaxMonthly caps MonthlyCost.Total under Financing, or the default
+	// assumptions when Financing is nil.
+	MaxMonthly int        `json:"max_monthly,omitempty"`
+	Financing  *Financing `json:"financing,omitempty"`
+	// Statuses restricts results to these Status values; empty means active
+	// listings only (see EffectiveStatuses).
+	Statuses []string `json:"status,omitempty"`
+	// SoldWithinDays limits sold listings to those with a SoldDate in the
+	// last N days; other statuses are unaffected.
+	SoldWithinDays int `json:"sold_within_days,omitempty"`
+	// Verified names amenity flags (see package amenity) that must also be
+	// confirmed by a vision tag; each implies its Require* flag.
+	Verified []string `json:"verified,omitempty"`
+}
+
+// EffectiveStatuses is Statuses normalized and deduplicated. When none are
+// given it is just active, or just sold when SoldWithinDays asks for recent
+// sales.
+func (f SearchFilters) EffectiveStatuses() []string {
+	if len(f.Statuses) == 0 {
+		if f.SoldWithinDays > 0 {
+			return []string{StatusSold}
+		}
+		return []string{StatusActive}
+	}
+	seen := make(map[string]bool, len(f.Statuses))
+	var out []string
+	for _, s := range f.Statuses {
+		s = NormalizeStatus(s)
+		if !seen[s] {
+			seen[s] = true
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// GeoPoint is a WGS84 coordinate.
+type GeoPoint struct {
+	Lat float64 `json:"lat"`
+	Lng float64 `json:"lng"`
+}
+
+// BBox is a map viewport. MinLng > MaxLng means the box crosses the antimeridian.
+type BBox struct {
+	MinLng float64 `json:"min_lng"`
+	MinLat float64 `json:"min_lat"`
+	MaxLng float64 `json:"max_lng"`
+	MaxLat float64 `json:"max_lat"`
+}
+
+// Ring is a closed linear ring whose first and last points are equal.
+type Ring []GeoPoint
+
+// Polygon is an outer ring followed by zero or more holes.
+type Polygon []Ring
+
+// PageRequest selects the sort order and the window of results to return.
+// Sort is one of the keys accepted by paging.ParseSort; Cursor is the opaque
+// next_cursor from a previous page; Limit <= 0 returns every match.
+type PageRequest struct {
+	Sort   string
+	Limit  int
+	Cursor string
+}
+
+// SearchPage is one window of search results.
+type SearchPage struct {
+	Results    []Listing
+	Total      int
+	NextCursor string
+}
@@ -0,0 +1,30 @@
+package types
+
+// Financing holds the assumptions behind a MonthlyCost. Rates are annual
+// percentages: 6.5 means 6.5%.
+type Financing struct {
+	DownPaymentPct float64 `json:"downPaymentPct"`
+	// DownPayment is a cash amount that replaces DownPaymentPct when set.
+	DownPayment int     `json:"downPayment,omitempty"`
+	RatePct     float64 `json:"ratePct"`
+	TermYears   int     `json:"termYears"`
+	// TaxRatePct overrides the property tax rate looked up by state and zip.
+	TaxRatePct       float64 `json:"taxRatePct,omitempty"`
+	InsuranceRatePct float64 `json:"insuranceRatePct"`
+	// PMIRatePct is charged on the loan while the down payment is under 20%.
+	PMIRatePct float64 `json:"pmiRatePct"`
+}
+
+// MonthlyCost is what owning a listing costs per month under some
+// Financing, in whole dollars.
+type MonthlyCost struct {
+	PrincipalInterest int     `json:"principalInterest"`
+	PropertyTax       int     `json:"propertyTax"`
+	Insurance         int     `json:"insurance"`
+	PMI               int     `json:"pmi"`
+	HOA               int     `json:"hoa"`
+	Total             int     `json:"total"`
+	DownPayment       int     `json:"downPayment"`
+	LoanAmount        int     `json:"loanAmount"`
+	TaxRatePct        float64 `json:"taxRatePct"`
+}
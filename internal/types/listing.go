@@ -1,33 +1,214 @@
 package types
 
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
 type Listing struct {
-	ID            string   `json:"id"`
-	Title         string   `json:"title"`
-	Price         int      `json:"price"`
-	Address       string   `json:"address"`
-	City          string   `json:"city"`
-	State         string   `json:"state"`
-	Zip           string   `json:"zip"`
-	Beds          int      `json:"beds"`
-	Baths         float64  `json:"baths"`
-	Sqft          int      `json:"sqft"`
-	LotSqft       int      `json:"lotSqft"`
-	YearBuilt     int      `json:"yearBuilt"`
-	Stories       int      `json:"stories"`
-	GarageSpaces  int      `json:"garageSpaces"`
-	HasRVParking  bool     `json:"hasRvParking"`
-	HasPool       bool     `json:"hasPool"`
-	HasWaterfront bool     `json:"hasWaterfront"`
-	HasView       bool     `json:"hasView"`
-	HasBasement   bool     `json:"hasBasement"`
-	HasFireplace  bool     `json:"hasFireplace"`
-	IsNewBuild    bool     `json:"isNewBuild"`
-	IsFixer       bool     `json:"isFixer"`
-	HasADU        bool     `json:"hasAdu"`
-	HOAFee        int      `json:"hoaFee"`
-	PropertyType  string   `json:"propertyType"`
-	PhotoURL      string   `json:"photoUrl"`
-	Tags          []string `json:"tags"`
-	VisionTags    []string `json:"visionTags,omitempty"`
-	Source        string   `json:"source"`
+	ID            string  `json:"id"`
+	Title         string  `json:"title"`
+	Description   string  `json:"description,omitempty"`
+	Price         int     `json:"price"`
+	Address       string  `json:"address"`
+	City          string  `json:"city"`
+	State         string  `json:"state"`
+	Zip           string  `json:"zip"`
+	Latitude      float64 `json:"latitude,omitempty"`
+	Longitude     float64 `json:"longitude,omitempty"`
+	Beds          int     `json:"beds"`
+	Baths         float64 `json:"baths"`
+	Sqft          int     `json:"sqft"`
+	LotSqft       int     `json:"lotSqft"`
+	YearBuilt     int     `json:"yearBuilt"`
+	Stories       int     `json:"stories"`
+	GarageSpaces  int     `json:"garageSpaces"`
+	HasRVParking  bool    `json:"hasRvParking"`
+	HasPool       bool    `json:"hasPool"`
+	HasWaterfront bool    `json:"hasWaterfront"`
+	HasView       bool    `json:"hasView"`
+	HasBasement   bool    `json:"hasBasement"`
+	HasFireplace  bool    `json:"hasFireplace"`
+	IsNewBuild    bool    `json:"isNewBuild"`
+	IsFixer       bool    `json:"isFixer"`
+	HasADU        bool    `json:"hasAdu"`
+	HOAFee        int     `json:"hoaFee"`
+	PropertyType  string  `json:"propertyType"`
+	PhotoURL      string  `json:"photoUrl"`
+	// Photos is the gallery in display order; PhotoURL is its hero shot.
+	Photos     []Photo  `json:"photos,omitempty"`
+	Tags       []string `json:"tags"`
+	VisionTags []string `json:"visionTags,omitempty"`
+	Source     string   `json:"source"`
+	// Vision is the latest vision analysis of the listing's photos;
+	// VisionTags holds its tags above the enrichment confidence threshold.
+	Vision *VisionAnalysis `json:"vision,omitempty"`
+	// Verification maps each amenity flag the listing claims or its photos
+	// show to confirmed, contradicted or unverified (see package amenity);
+	// Contradictions lists the contradicted ones.
+	Verification   map[string]string `json:"verification,omitempty"`
+	Contradictions []string          `json:"contradictions,omitempty"`
+	// Status is one of the Status constants; empty means active.
+	Status string `json:"status,omitempty"`
+	// ListDate is when the listing went on market, if the source reports it.
+	ListDate *time.Time `json:"listDate,omitempty"`
+	// PendingDate is when an offer was accepted, and SoldDate and SoldPrice
+	// record the closing, when the source reports them.
+	PendingDate *time.Time `json:"pendingDate,omitempty"`
+	SoldDate    *time.Time `json:"soldDate,omitempty"`
+	SoldPrice   int        `json:"soldPrice,omitempty"`
+	// DaysOnMarket counts whole days since ListDate, or since the listing
+	// was first seen when the source gives no date.
+	DaysOnMarket int `json:"daysOnMarket"`
+	// OriginalPrice is the asking price when the listing last came on the
+	// market, PriceChangePct the change from it to Price (negative for
+	// cuts) and LastPriceDropAt when the price last went down.
+	OriginalPrice   int        `json:"originalPrice,omitempty"`
+	PriceChangePct  float64    `json:"priceChangePct,omitempty"`
+	LastPriceDropAt *time.Time `json:"lastPriceDropAt,omitempty"`
+	// DistanceMi is the distance from the search's near point; only set in
+	// search responses.
+	DistanceMi float64 `json:"distanceMi,omitempty"`
+	// Score is the full-text relevance of the listing to the search's query;
+	// only set in search responses.
+	Score float64 `json:"score,omitempty"`
+	// MonthlyCost is the cost of owning the listing under the request's
+	// financing assumptions; only set in search and listing responses.
+	MonthlyCost *MonthlyCost `json:"monthlyCost,omitempty"`
+	// LastSeen is when an ingest or refresh last saw the listing upstream;
+	// only set on listings read back from the store by ID.
+	LastSeen *time.Time `json:"lastSeen,omitempty"`
+	// Provenance maps a field's JSON name to the Source that supplied its value
+	// when the listing was merged from several sources.
+	Provenance map[string]string `json:"provenance,omitempty"`
+	// Alternates lists every source record merged into this listing.
+	Alternates []SourceRef `json:"alternates,omitempty"`
+	// RelistedFrom is the ID of an earlier listing of the same home, found
+	// because this one re-uses its photos.
+	RelistedFrom string `json:"relistedFrom,omitempty"`
+}
+
+// SourceRef identifies a listing record at one source.
+type SourceRef struct {
+	Source string `json:"source"`
+	ID     string `json:"id"`
+}
+
+// Photo is one image in a listing's gallery.
+type Photo struct {
+	URL     string `json:"url"`
+	Caption string `json:"caption,omitempty"`
+	Order   int    `json:"order"`
+	Width   int    `json:"width,omitempty"`
+	Height  int    `json:"height,omitempty"`
+	// Room is what the photo shows ("Kitchen", "Front of Structure"), when
+	// the source labels it.
+	Room string `json:"room,omitempty"`
+	// Hash is the SHA-256 of the image in the local photo cache (served at
+	// /photos/{hash}) and DHash its perceptual hash; both are set once the
+	// photo has been cached.
+	Hash  string `json:"hash,omitempty"`
+	DHash string `json:"dhash,omitempty"`
+	// Stock marks a picture that also appears on several other listings,
+	// such as a placeholder or a builder's stock shot.
+	Stock bool `json:"stock,omitempty"`
+}
+
+// VisionAnalysis records what the vision model saw across a listing's
+// photos.
+type VisionAnalysis struct {
+	// Scores aggregates each tag's per-photo confidences into [0, 1].
+	Scores map[string]float64 `json:"scores"`
+	// Evidence maps each scored tag to the URL of the photo that scored it
+	// highest.
+	Evidence map[string]string `json:"evidence,omitempty"`
+	Photos   []PhotoAnalysis   `json:"photos,omitempty"`
+	// Model, Version and AnalyzedAt describe the most recent photo analysis.
+	Model      string    `json:"model"`
+	Version    string    `json:"version,omitempty"`
+	AnalyzedAt time.Time `json:"analyzedAt"`
+}
+
+// ScoredTags lists the tags scored at or above threshold, sorted.
+func (a *VisionAnalysis) ScoredTags(threshold float64) []string {
+	var out []string
+	for tag, score := range a.Scores {
+		if score >= threshold {
+			out = append(out, tag)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// PhotoAnalysis is the vision model's answer for one photo.
+type PhotoAnalysis struct {
+	URL        string             `json:"url"`
+	Scores     map[string]float64 `json:"scores"`
+	Model      string             `json:"model"`
+	Version    string             `json:"version,omitempty"`
+	AnalyzedAt time.Time          `json:"analyzedAt"`
+}
+
+// PhotoURLs lists the gallery URLs in display order, led by PhotoURL when
+// the gallery does not include it. Duplicates and blanks are dropped.
+func (l Listing) PhotoURLs() []string {
+	photos := append([]Photo(nil), l.Photos...)
+	sort.SliceStable(photos, func(i, j int) bool { return photos[i].Order < photos[j].Order })
+	seen := make(map[string]bool)
+	var out []string
+	add := func(u string) {
+		if u != "" && !seen[u] {
+			seen[u] = true
+			out = append(out, u)
+		}
+	}
+	inGallery := false
+	for _, p := range photos {
+		inGallery = inGallery || p.URL == l.PhotoURL
+	}
+	if !inGallery {
+		add(l.PhotoURL)
+	}
+	for _, p := range photos {
+		add(p.URL)
+	}
+	return out
+}
+
+// TagPool is the tag list tag filters match against: Tags, plus VisionTags
+// when useVision is set. With minConfidence > 0 the vision part is every tag
+// the analysis scored at least that high instead, so a threshold below the
+// worker's VISION_MIN_CONFIDENCE still finds tags that missed VisionTags, and
+// unscored vision tags drop out.
+func (l Listing) TagPool(useVision bool, minConfidence float64) []string {
+	if !useVision {
+		return l.Tags
+	}
+	if minConfidence > 0 {
+		if l.Vision == nil || len(l.Vision.Scores) == 0 {
+			return l.Tags
+		}
+		return append(append([]string(nil), l.Tags...), l.Vision.ScoredTags(minConfidence)...)
+	}
+	if len(l.VisionTags) == 0 {
+		return l.Tags
+	}
+	return append(append([]string(nil), l.Tags...), l.VisionTags...)
+}
+
+// VisionConfidence is the recorded score for a vision tag, or 0 when the
+// listing has no analysis that scored it.
+func (l Listing) VisionConfidence(tag string) float64 {
+	if l.Vision == nil {
+		return 0
+	}
+	return l.Vision.Scores[strings.ToLower(strings.TrimSpace(tag))]
+}
+
+// HasLocation reports whether the listing carries coordinates. (0, 0) is
+// treated as unknown; no listing sits in the Gulf of Guinea.
+func (l Listing) HasLocation() bool {
+	return l.Latitude != 0 || l.Longitude != 0
 }
@@ -0,0 +1,56 @@
+package types
+
+import (
+	"strings"
+	"time"
+)
+
+// Listing statuses, as stored in Listing.Status.
+const (
+	StatusActive     = "active"
+	StatusContingent = "contingent"
+	StatusPending    = "pending"
+	StatusSold       = "sold"
+	StatusWithdrawn  = "withdrawn"
+)
+
+// Statuses lists every listing status.
+var Statuses = []string{StatusActive, StatusContingent, StatusPending, StatusSold, StatusWithdrawn}
+
+// NormalizeStatus maps the status vocabularies of upstream sources (RESO
+// StandardStatus, portal labels) onto the Status constants. Empty and
+// unrecognized values count as active.
+func NormalizeStatus(s string) string {
+	s = strings.ToLower(strings.TrimSpace(s))
+	switch {
+	case strings.Contains(s, "contingent"), strings.Contains(s, "under contract"), strings.Contains(s, "backup"):
+		return StatusContingent
+	case strings.Contains(s, "pending"):
+		return StatusPending
+	case s == "sold", s == "closed", strings.Contains(s, "recently sold"):
+		return StatusSold
+	case s == "withdrawn", s == "canceled", s == "cancelled", s == "expired", s == "hold", s == "off market", s == "delisted":
+		return StatusWithdrawn
+	}
+	return StatusActive
+}
+
+// History event kinds.
+const (
+	EventListed       = "listed"
+	EventPriceChange  = "price_change"
+	EventStatusChange = "status_change"
+	// EventRelisted starts a new marketing period: the listing came back
+	// after being sold, withdrawn or dropped from its source.
+	EventRelisted = "relisted"
+)
+
+// HistoryEvent is one recorded change to a listing's price or status.
+type HistoryEvent struct {
+	At     time.Time `json:"at"`
+	Event  string    `json:"event"`
+	Price  int       `json:"price"`
+	Status string    `json:"status"`
+	// PriceChange is Price minus the price of the event before it.
+	PriceChange int `json:"priceChange,omitempty"`
+}
@@ -0,0 +1,194 @@
+package vision
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"home-finder/internal/store"
+)
+
+// DefaultCacheTTL is how long a cached answer is trusted by default.
+const DefaultCacheTTL = 90 * 24 * time.Hour
+
+// Hasher maps a photo URL to the SHA-256 of its content, e.g. via the local
+// photo cache.
+type Hasher interface {
+	ContentHash(ctx context.Context, url string) (string, error)
+}
+
+// CacheStats counts CachedClient lookups since start.
+type CacheStats struct {
+	Hits    int64 `json:"hits"`
+	Misses  int64 `json:"misses"`  // includes expired entries
+	Expired int64 `json:"expired"` // entries found but older than the TTL
+	Errors  int64 `json:"errors"`  // hashing or store failures; the call went through uncached
+}
+
+// CachedClient wraps a Client so each image is sent to the model once per
+// model and prompt version. Entries are keyed by the image's content hash,
+// so the same photo behind two URLs is analyzed once and a photo replaced at
+// the same URL is analyzed again. Without a Hasher the URL stands in for the
+// content and only the TTL catches replaced photos.
+type CachedClient struct {
+	next    Client
+	model   string
+	version string
+	results store.VisionResultCache
+	hasher  Hasher
+	ttl     time.Duration
+
+	hits, misses, expired, errs atomic.Int64
+}
+
+// NewCachedClient caches next's answers, which come from model under prompt
+// version, in results. hasher may be nil; a ttl of zero keeps entries until
+// invalidated.
+func NewCachedClient(next Client, model, version string, results store.VisionResultCache, hasher Hasher, ttl time.Duration) *CachedClient {
+	return &CachedClient{next: next, model: model, version: version, results: results, hasher: hasher, ttl: ttl}
+}
+
+// Version is the prompt version entries are cached under.
+func (c *CachedClient) Version() string {
+	return c.version
+}
+
+// CacheTTLFromEnv reads VISION_CACHE_TTL (default DefaultCacheTTL; 0 never
+// expires).
+func CacheTTLFromEnv() (time.Duration, error) {
+	v := os.Getenv("VISION_CACHE_TTL")
+	if v == "" {
+		return DefaultCacheTTL, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("VISION_CACHE_TTL must be a non-negative duration")
+	}
+	return d, nil
+}
+
+func (c *CachedClient) ExtractFeatures(ctx context.Context, photoURL string) (Features, error) {
+	key, err := c.key(ctx, photoURL)
+	if err != nil {
+		c.errs.Add(1)
+		log.Printf("vision cache: hash %s: %v", photoURL, err)
+		return c.next.ExtractFeatures(ctx, photoURL)
+	}
+	r, err := c.results.GetVisionResult(ctx, key, c.model, c.version)
+	switch {
+	case err == nil && c.fresh(r):
+		c.hits.Add(1)
+		return Features{Tags: r.Tags, Model: r.ResultModel, Version: c.version}, nil
+	case err == nil:
+		c.expired.Add(1)
+	case !errors.Is(err, store.ErrNotFound):
+		c.errs.Add(1)
+		log.Printf("vision cache: lookup %s: %v", photoURL, err)
+	}
+	c.misses.Add(1)
+	return c.refresh(ctx, key, photoURL)
+}
+
+// refresh asks the wrapped client and stores its answer under key.
+func (c *CachedClient) refresh(ctx context.Context, key, photoURL string) (Features, error) {
+	features, err := c.next.ExtractFeatures(ctx, photoURL)
+	if err != nil {
+		return Features{}, err
+	}
+	features.Version = c.version
+	err = c.results.PutVisionResult(ctx, store.VisionResult{
+		ImageHash:   key,
+		Model:       c.model,
+		Version:     c.version,
+		URL:         photoURL,
+		Tags:        features.Tags,
+		ResultModel: features.Model,
+		CreatedAt:   time.Now(),
+	})
+	if err != nil {
+		c.errs.Add(1)
+		log.Printf("vision cache: store %s: %v", photoURL, err)
+	}
+	return features, nil
+}
+
+// Invalidate drops every cached answer for the photo at photoURL.
+func (c *CachedClient) Invalidate(ctx context.Context, photoURL string) (int, error) {
+	key, err := c.key(ctx, photoURL)
+	if err != nil {
+		return 0, err
+	}
+	return c.InvalidateHash(ctx, key)
+}
+
+// InvalidateHash drops every cached answer for an image hash. Use Clear to
+// drop them all.
+func (c *CachedClient) InvalidateHash(ctx context.Context, hash string) (int, error) {
+	if hash == "" {
+		return 0, errors.New("image hash is required")
+	}
+	return c.results.DeleteVisionResults(ctx, hash)
+}
+
+// Clear drops every cached answer. They cost a model call each to redo.
+func (c *CachedClient) Clear(ctx context.Context) (int, error) {
+	return c.results.DeleteVisionResults(ctx, "")
+}
+
+// Revalidate redoes every entry made under another model or prompt version,
+// such as after a vocabulary change, and drops the old entry once its
+// replacement is stored. Entries whose photo cannot be analyzed are kept
+// for the next attempt.
+func (c *CachedClient) Revalidate(ctx context.Context) (refreshed, failed int, err error) {
+	outdated, err := c.results.OutdatedVisionResults(ctx, c.model, c.version)
+	if err != nil {
+		return 0, 0, err
+	}
+	for _, old := range outdated {
+		if err := ctx.Err(); err != nil {
+			return refreshed, failed, err
+		}
+		key, err := c.key(ctx, old.URL)
+		if err == nil {
+			_, err = c.refresh(ctx, key, old.URL)
+		}
+		if err != nil {
+			log.Printf("vision cache: revalidate %s: %v", old.URL, err)
+			failed++
+			continue
+		}
+		if err := c.results.DeleteVisionResult(ctx, old.ImageHash, old.Model, old.Version); err != nil {
+			return refreshed, failed, err
+		}
+		refreshed++
+	}
+	return refreshed, failed, nil
+}
+
+// Stats reports lookups since start.
+func (c *CachedClient) Stats() CacheStats {
+	return CacheStats{
+		Hits:    c.hits.Load(),
+		Misses:  c.misses.Load(),
+		Expired: c.expired.Load(),
+		Errors:  c.errs.Load(),
+	}
+}
+
+func (c *CachedClient) fresh(r store.VisionResult) bool {
+	return c.ttl <= 0 || time.Since(r.CreatedAt) < c.ttl
+}
+
+func (c *CachedClient) key(ctx context.Context, photoURL string) (string, error) {
+	if c.hasher != nil {
+		return c.hasher.ContentHash(ctx, photoURL)
+	}
+	sum := sha256.Sum256([]byte(photoURL))
+	return "url:" + hex.EncodeToString(sum[:]), nil
+}
@@ -1,14 +1,26 @@
 package vision
 
-// Client defines the contract for vision feature extraction.
-// In production, implement using a hosted vision model (e.g., GPT-4o mini vision).
+import "context"
+
+// Client defines the contract for vision feature extraction. HTTPClient talks
+// to a hosted vision model; StubClient returns fixed tags for local/demo use.
 type Client interface {
-	ExtractFeatures(photoURL string) (Features, error)
+	ExtractFeatures(ctx context.Context, photoURL string) (Features, error)
+}
+
+// Versioned is implemented by clients whose answers depend on a prompt or
+// vocabulary; Version changes whenever they do, so earlier answers can be
+// recognized as outdated.
+type Versioned interface {
+	Version() string
 }
 
-// Features represents detected tags with confidences.
+// Features represents detected tags with confidences in [0, 1], along with
+// the model and prompt version that produced them.
 type Features struct {
-	Tags map[string]float64
+	Tags    map[string]float64
+	Model   string
+	Version string
 }
 
 // StubClient is a no-op vision client for local/demo use.
@@ -16,6 +28,6 @@ type StubClient struct {
 	DefaultTags map[string]float64
 }
 
-func (s StubClient) ExtractFeatures(_ string) (Features, error) {
-	return Features{Tags: s.DefaultTags}, nil
+func (s StubClient) ExtractFeatures(_ context.Context, _ string) (Features, error) {
+	return Features{Tags: s.DefaultTags, Model: "stub"}, nil
 }
@@ -0,0 +1,404 @@
+package vision
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultBaseURL = "https://api.openai.com/v1"
+	DefaultModel   = "gpt-4o-mini"
+	// PromptVersion names the prompt in systemPrompt; bump it when the
+	// wording changes meaning. Version folds in the exact text as well.
+	PromptVersion = "tags-v1"
+
+	defaultTimeout    = 30 * time.Second
+	defaultMaxRetries = 3
+	defaultRPM        = 60
+	maxPhotoBytes     = 10 << 20
+	maxResponseBytes  = 1 << 20
+	maxBackoff        = 30 * time.Second
+)
+
+// HTTPClient extracts features with an OpenAI-compatible chat-completions
+// vision endpoint. It downloads the photo itself and sends it inline, so the
+// model provider never needs to reach the photo host, and constrains the
+// answer to Vocabulary.
+type HTTPClient struct {
+	BaseURL    string // API root, e.g. https://api.openai.com/v1
+	APIKey     string
+	Model      string
+	Vocabulary []string
+	HTTP       *http.Client
+	// Timeout bounds each attempt (photo download or completion); the
+	// caller's context bounds the whole call, retries included.
+	Timeout    time.Duration
+	MaxRetries int
+	// Limiter paces completion requests; nil means unlimited.
+	Limiter *Limiter
+	// Photos supplies photo bytes, e.g. from the local photo cache; nil
+	// downloads each photo directly.
+	Photos PhotoLoader
+}
+
+// PhotoLoader returns a photo's bytes and content type.
+type PhotoLoader interface {
+	Load(ctx context.Context, url string) ([]byte, string, error)
+}
+
+func NewHTTPClient(baseURL, apiKey string) *HTTPClient {
+	return &HTTPClient{
+		BaseURL:    baseURL,
+		APIKey:     apiKey,
+		Model:      DefaultModel,
+		Vocabulary: DefaultVocabulary,
+		HTTP:       &http.Client{},
+		Timeout:    defaultTimeout,
+		MaxRetries: defaultMaxRetries,
+		Limiter:    NewLimiter(defaultRPM),
+	}
+}
+
+// ClientFromEnv builds an HTTPClient from VISION_API_KEY, VISION_API_BASE,
+// VISION_MODEL, VISION_TIMEOUT, VISION_MAX_RETRIES and VISION_RPM. It returns
+// nil when VISION_API_KEY is unset.
+func ClientFromEnv() Client {
+	key := os.Getenv("VISION_API_KEY")
+	if key == "" {
+		return nil
+	}
+	base := os.Getenv("VISION_API_BASE")
+	if base == "" {
+		base = DefaultBaseURL
+	}
+	c := NewHTTPClient(base, key)
+	if model := os.Getenv("VISION_MODEL"); model != "" {
+		c.Model = model
+	}
+	if d, err := time.ParseDuration(os.Getenv("VISION_TIMEOUT")); err == nil && d > 0 {
+		c.Timeout = d
+	}
+	if n, err := strconv.Atoi(os.Getenv("VISION_MAX_RETRIES")); err == nil && n >= 0 {
+		c.MaxRetries = n
+	}
+	if n, err := strconv.Atoi(os.Getenv("VISION_RPM")); err == nil {
+		c.Limiter = NewLimiter(n)
+	}
+	return c
+}
+
+// StatusError is a non-2xx answer from the photo host or the model API.
+type StatusError struct {
+	URL        string
+	Code       int
+	Body       string
+	RetryAfter time.Duration
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s returned %d: %s", e.URL, e.Code, e.Body)
+}
+
+// Temporary reports whether retrying may help: rate limits and server errors.
+func (e *StatusError) Temporary() bool {
+	return e.Code == http.StatusTooManyRequests || e.Code >= 500
+}
+
+func (c *HTTPClient) ExtractFeatures(ctx context.Context, photoURL string) (Features, error) {
+	if photoURL == "" {
+		return Features{}, errors.New("vision: photo URL is required")
+	}
+	photo, err := c.downloadPhoto(ctx, photoURL)
+	if err != nil {
+		return Features{}, err
+	}
+	body, err := json.Marshal(c.chatRequest(photo))
+	if err != nil {
+		return Features{}, err
+	}
+	endpoint := strings.TrimRight(c.BaseURL, "/") + "/chat/completions"
+	raw, _, err := c.do(ctx, true, maxResponseBytes, func(ctx context.Context) (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+c.APIKey)
+		return req, nil
+	})
+	if err != nil {
+		return Features{}, fmt.Errorf("vision: completion: %w", err)
+	}
+	return c.parseResponse(raw)
+}
+
+// downloadPhoto fetches the photo, through c.Photos when set, and returns it
+// as a data URL.
+func (c *HTTPClient) downloadPhoto(ctx context.Context, photoURL string) (string, error) {
+	var data []byte
+	var contentType string
+	var err error
+	if c.Photos != nil {
+		data, contentType, err = c.Photos.Load(ctx, photoURL)
+	} else {
+		data, contentType, err = c.do(ctx, false, maxPhotoBytes, func(ctx context.Context) (*http.Request, error) {
+			return http.NewRequestWithContext(ctx, http.MethodGet, photoURL, nil)
+		})
+	}
+	if err != nil {
+		return "", fmt.Errorf("vision: download photo: %w", err)
+	}
+	if !strings.HasPrefix(contentType, "image/") {
+		contentType = http.DetectContentType(data)
+	}
+	if !strings.HasPrefix(contentType, "image/") {
+		return "", fmt.Errorf("vision: %s is %s, not an image", photoURL, contentType)
+	}
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	return "data:" + strings.TrimSpace(mediaType) + ";base64," + base64.StdEncoding.EncodeToString(data), nil
+}
+
+// do sends a request built by newReq, retrying network errors, timeouts, 429s
+// and 5xx with exponential backoff (or the server's Retry-After). Bodies over
+// limit bytes are rejected. limited requests wait on c.Limiter first.
+func (c *HTTPClient) do(ctx context.Context, limited bool, limit int64, newReq func(context.Context) (*http.Request, error)) ([]byte, string, error) {
+	for attempt := 0; ; attempt++ {
+		if limited {
+			if err := c.Limiter.Wait(ctx); err != nil {
+				return nil, "", err
+			}
+		}
+		body, contentType, err := c.attempt(ctx, limit, newReq)
+		if err == nil {
+			return body, contentType, nil
+		}
+
+		var status *StatusError
+		isStatus := errors.As(err, &status)
+		if ctx.Err() != nil || attempt >= c.MaxRetries || (isStatus && !status.Temporary()) {
+			return nil, "", err
+		}
+		wait := backoff(attempt)
+		if isStatus && status.RetryAfter > 0 {
+			wait = status.RetryAfter
+			if limited && status.Code == http.StatusTooManyRequests {
+				// Hold back every request sharing the limiter, not just this one.
+				c.Limiter.Pause(wait)
+			}
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, "", fmt.Errorf("%w (after %d attempts: %v)", ctx.Err(), attempt+1, err)
+		case <-timer.C:
+		}
+	}
+}
+
+func (c *HTTPClient) attempt(ctx context.Context, limit int64, newReq func(context.Context) (*http.Request, error)) ([]byte, string, error) {
+	if c.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+		defer cancel()
+	}
+	req, err := newReq(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return nil, "", err
+	}
+	if resp.StatusCode >= 300 {
+		snippet := string(body)
+		if len(snippet) > 200 {
+			snippet = snippet[:200]
+		}
+		return nil, "", &StatusError{
+			URL:        req.URL.Redacted(),
+			Code:       resp.StatusCode,
+			Body:       strings.TrimSpace(snippet),
+			RetryAfter: retryAfter(resp.Header.Get("Retry-After")),
+		}
+	}
+	if int64(len(body)) > limit {
+		return nil, "", fmt.Errorf("%s response exceeds %d bytes", req.URL.Redacted(), limit)
+	}
+	return body, resp.Header.Get("Content-Type"), nil
+}
+
+// backoff is 500ms doubled per attempt with ±25% jitter, capped at maxBackoff.
+func backoff(attempt int) time.Duration {
+	d := time.Duration(float64(500*time.Millisecond) * math.Pow(2, float64(attempt)))
+	if d > maxBackoff {
+		d = maxBackoff
+	}
+	return time.Duration(float64(d) * (0.75 + rand.Float64()/2))
+}
+
+// retryAfter parses a Retry-After header given in seconds or as an HTTP date.
+func retryAfter(v string) time.Duration {
+	if v == "" {
+		return 0
+	}
+	var d time.Duration
+	if secs, err := strconv.Atoi(v); err == nil {
+		d = time.Duration(secs) * time.Second
+	} else if t, err := http.ParseTime(v); err == nil {
+		d = time.Until(t)
+	}
+	if d < 0 {
+		return 0
+	}
+	if d > maxBackoff {
+		return maxBackoff
+	}
+	return d
+}
+
+type chatRequest struct {
+	Model          string          `json:"model"`
+	Messages       []chatMessage   `json:"messages"`
+	Temperature    float64         `json:"temperature"`
+	MaxTokens      int             `json:"max_tokens"`
+	ResponseFormat *responseFormat `json:"response_format,omitempty"`
+}
+
+type chatMessage struct {
+	Role    string `json:"role"`
+	Content any    `json:"content"`
+}
+
+type contentPart struct {
+	Type     string    `json:"type"`
+	Text     string    `json:"text,omitempty"`
+	ImageURL *imageURL `json:"image_url,omitempty"`
+}
+
+type imageURL struct {
+	URL    string `json:"url"`
+	Detail string `json:"detail,omitempty"`
+}
+
+type responseFormat struct {
+	Type string `json:"type"`
+}
+
+func (c *HTTPClient) systemPrompt() string {
+	return `You tag real-estate listing photos. Answer with a JSON object of the form ` +
+		`{"tags": {"<tag>": <confidence between 0 and 1>}} using only these tags: ` +
+		strings.Join(c.Vocabulary, ", ") + `. Include a tag only if it is visible in the photo.`
+}
+
+// Version identifies the prompt the client sends: PromptVersion plus a
+// digest of the prompt text, so a vocabulary change yields a new version
+// without anyone remembering to bump PromptVersion.
+func (c *HTTPClient) Version() string {
+	sum := sha256.Sum256([]byte(c.systemPrompt()))
+	return PromptVersion + "-" + hex.EncodeToString(sum[:4])
+}
+
+func (c *HTTPClient) chatRequest(photoDataURL string) chatRequest {
+	return chatRequest{
+		Model: c.Model,
+		Messages: []chatMessage{
+			{Role: "system", Content: c.systemPrompt()},
+			{Role: "user", Content: []contentPart{
+				{Type: "text", Text: "Tag this listing photo."},
+				{Type: "image_url", ImageURL: &imageURL{URL: photoDataURL, Detail: "low"}},
+			}},
+		},
+		Temperature:    0,
+		MaxTokens:      300,
+		ResponseFormat: &responseFormat{Type: "json_object"},
+	}
+}
+
+type chatResponse struct {
+	Model   string `json:"model"`
+	Choices []struct {
+		Message struct {
+			Content string `json:"content"`
+		} `json:"message"`
+		FinishReason string `json:"finish_reason"`
+	} `json:"choices"`
+}
+
+// parseResponse reads the model's JSON answer. Tags may come as a map of
+// confidences or a list of {tag, confidence}; anything outside the
+// vocabulary is dropped and confidences are clamped to [0, 1].
+func (c *HTTPClient) parseResponse(raw []byte) (Features, error) {
+	var resp chatResponse
+	if err := json.Unmarshal(raw, &resp); err != nil {
+		return Features{}, fmt.Errorf("vision: decode completion: %w", err)
+	}
+	if len(resp.Choices) == 0 {
+		return Features{}, errors.New("vision: completion has no choices")
+	}
+	content := strings.TrimSpace(resp.Choices[0].Message.Content)
+	content = strings.TrimPrefix(content, "```json")
+	content = strings.Trim(content, "`\n ")
+
+	var answer struct {
+		Tags json.RawMessage `json:"tags"`
+	}
+	if err := json.Unmarshal([]byte(content), &answer); err != nil {
+		return Features{}, fmt.Errorf("vision: model answer is not JSON (finish_reason %q): %w", resp.Choices[0].FinishReason, err)
+	}
+	found := map[string]float64{}
+	var asMap map[string]float64
+	var asList []struct {
+		Tag        string  `json:"tag"`
+		Confidence float64 `json:"confidence"`
+	}
+	switch {
+	case len(answer.Tags) == 0 || string(answer.Tags) == "null":
+	case json.Unmarshal(answer.Tags, &asMap) == nil:
+		for tag, conf := range asMap {
+			found[tag] = conf
+		}
+	case json.Unmarshal(answer.Tags, &asList) == nil:
+		for _, t := range asList {
+			found[t.Tag] = t.Confidence
+		}
+	default:
+		return Features{}, fmt.Errorf("vision: unexpected tags in model answer: %.200s", answer.Tags)
+	}
+
+	allowed := make(map[string]bool, len(c.Vocabulary))
+	for _, v := range c.Vocabulary {
+		allowed[v] = true
+	}
+	features := Features{Tags: map[string]float64{}, Model: resp.Model, Version: c.Version()}
+	if features.Model == "" {
+		features.Model = c.Model
+	}
+	for tag, conf := range found {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if !allowed[tag] || math.IsNaN(conf) {
+			continue
+		}
+		features.Tags[tag] = math.Max(0, math.Min(1, conf))
+	}
+	return features, nil
+}
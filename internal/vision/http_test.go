@@ -0,0 +1,280 @@
+package vision
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newMockClient serves m and returns an HTTPClient pointed at it, without a
+// limiter unless the test sets one.
+func newMockClient(t *testing.T, m *MockServer, h http.Handler) (*HTTPClient, string) {
+	t.Helper()
+	if h == nil {
+		h = m.Handler()
+	}
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+	c := NewHTTPClient(srv.URL+"/v1", m.APIKey)
+	c.Limiter = nil
+	return c, srv.URL + "/photos/"
+}
+
+func newMock(t *testing.T) *MockServer {
+	t.Helper()
+	m, err := NewMockServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestHTTPClientRecordings(t *testing.T) {
+	m := newMock(t)
+	m.APIKey = "secret"
+	c, photos := newMockClient(t, m, nil)
+	cases := map[string]map[string]float64{
+		"backyard-pool":   {"pool": 0.94, "back yard": 0.81, "patio": 0.62, "fenced yard": 0.35},
+		"craftsman-porch": {"front porch": 0.9, "garden": 0.72, "detached garage": 1},
+		"lake-view":       {"lake view": 0.91, "balcony": 0.77, "high-rise": 0.55},
+		"default":         {},
+	}
+	for name, want := range cases {
+		f, err := c.ExtractFeatures(context.Background(), photos+name+".png")
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if len(f.Tags) != len(want) {
+			t.Errorf("%s: tags = %v, want %v", name, f.Tags, want)
+		}
+		for tag, conf := range want {
+			if f.Tags[tag] != conf {
+				t.Errorf("%s: %s = %v, want %v", name, tag, f.Tags[tag], conf)
+			}
+		}
+		if f.Model != "gpt-4o-mini-2024-07-18" || f.Version != c.Version() {
+			t.Errorf("%s: model %q version %q", name, f.Model, f.Version)
+		}
+	}
+}
+
+func TestHTTPClientRetriesServerErrors(t *testing.T) {
+	m := newMock(t)
+	m.FailEvery = 2
+	c, photos := newMockClient(t, m, nil)
+	for i := 0; i < 2; i++ {
+		if _, err := c.ExtractFeatures(context.Background(), photos+"backyard-pool.png"); err != nil {
+			t.Fatalf("call %d: %v", i, err)
+		}
+	}
+	// The second call's first attempt got a 503 and was retried.
+	if got := m.Calls(); got != 3 {
+		t.Fatalf("completions = %d, want 3", got)
+	}
+}
+
+func TestHTTPClientRetriesRateLimits(t *testing.T) {
+	m := newMock(t)
+	m.RateLimitEvery = 2
+	c, photos := newMockClient(t, m, nil)
+	c.Limiter = NewLimiter(6000)
+	if _, err := c.ExtractFeatures(context.Background(), photos+"lake-view.png"); err != nil {
+		t.Fatal(err)
+	}
+	// The second call's first attempt is rate limited with Retry-After: 1.
+	start := time.Now()
+	if _, err := c.ExtractFeatures(context.Background(), photos+"lake-view.png"); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("retried after %s, want Retry-After of 1s", elapsed)
+	}
+	if got := m.Calls(); got != 3 {
+		t.Fatalf("completions = %d, want 3", got)
+	}
+	c.Limiter.mu.Lock()
+	paused := c.Limiter.next.Sub(start)
+	c.Limiter.mu.Unlock()
+	if paused < time.Second {
+		t.Errorf("limiter paused until %s after start, want the Retry-After", paused)
+	}
+}
+
+func TestHTTPClientGivesUp(t *testing.T) {
+	m := newMock(t)
+	m.FailEvery = 1
+	c, photos := newMockClient(t, m, nil)
+	c.MaxRetries = 1
+	_, err := c.ExtractFeatures(context.Background(), photos+"backyard-pool.png")
+	var status *StatusError
+	if !errors.As(err, &status) || status.Code != http.StatusServiceUnavailable {
+		t.Fatalf("err = %v, want a 503 StatusError", err)
+	}
+	if got := m.Calls(); got != 2 {
+		t.Fatalf("completions = %d, want 2", got)
+	}
+}
+
+func TestHTTPClientDoesNotRetryClientErrors(t *testing.T) {
+	m := newMock(t)
+	m.APIKey = "secret"
+	c, photos := newMockClient(t, m, nil)
+	c.APIKey = "wrong"
+	_, err := c.ExtractFeatures(context.Background(), photos+"backyard-pool.png")
+	var status *StatusError
+	if !errors.As(err, &status) || status.Code != http.StatusUnauthorized {
+		t.Fatalf("err = %v, want a 401 StatusError", err)
+	}
+	if got := m.Calls(); got != 1 {
+		t.Fatalf("completions = %d, want 1", got)
+	}
+}
+
+func TestHTTPClientLimiterPacesCompletions(t *testing.T) {
+	m := newMock(t)
+	c, photos := newMockClient(t, m, nil)
+	c.Limiter = NewLimiter(600) // one every 100ms
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if _, err := c.ExtractFeatures(context.Background(), photos+"default.png"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+		t.Fatalf("3 completions took %s, want at least 200ms", elapsed)
+	}
+}
+
+func TestLimiter(t *testing.T) {
+	if NewLimiter(0) != nil {
+		t.Fatal("NewLimiter(0) should not limit")
+	}
+	var unlimited *Limiter
+	if err := unlimited.Wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	unlimited.Pause(time.Hour)
+
+	l := NewLimiter(60) // one a second
+	if err := l.Wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := l.Wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("second Wait = %v, want deadline exceeded", err)
+	}
+
+	l = NewLimiter(6000)
+	l.Pause(150 * time.Millisecond)
+	start := time.Now()
+	if err := l.Wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Fatalf("Wait after Pause returned in %s", elapsed)
+	}
+}
+
+func TestHTTPClientMalformedAnswers(t *testing.T) {
+	m := newMock(t)
+	h := m.Handler()
+	var broken atomic.Bool
+	wrapped := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if broken.Load() && r.Method == http.MethodPost {
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte("<html>bad gateway</html>"))
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+	c, photos := newMockClient(t, m, wrapped)
+	_, err := c.ExtractFeatures(context.Background(), photos+"truncated.png")
+	if err == nil || !strings.Contains(err.Error(), "not JSON") || !strings.Contains(err.Error(), `"length"`) {
+		t.Fatalf("truncated answer: err = %v", err)
+	}
+
+	// A proxy in the way answers with an error page.
+	broken.Store(true)
+	_, err = c.ExtractFeatures(context.Background(), photos+"default.png")
+	if err == nil || !strings.Contains(err.Error(), "decode completion") {
+		t.Fatalf("HTML body: err = %v", err)
+	}
+}
+
+func TestHTTPClientRejectsNonImages(t *testing.T) {
+	m := newMock(t)
+	c, photos := newMockClient(t, m, nil)
+	if _, err := c.ExtractFeatures(context.Background(), photos+"missing.png"); err == nil {
+		t.Fatal("missing photo: want error")
+	}
+	if m.Calls() != 0 {
+		t.Fatalf("completions = %d, want 0", m.Calls())
+	}
+}
+
+func TestHTTPClientHonorsContext(t *testing.T) {
+	m := newMock(t)
+	// The mock notices a dropped client only once the delay is over.
+	m.Delay = time.Second
+	c, photos := newMockClient(t, m, nil)
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := c.ExtractFeatures(ctx, photos+"backyard-pool.png")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v, want deadline exceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Fatalf("returned after %s", elapsed)
+	}
+	if got := m.Calls(); got != 1 {
+		t.Fatalf("completions = %d, want 1 (no retry once the context is done)", got)
+	}
+}
+
+func TestHTTPClientCancelDuringBackoff(t *testing.T) {
+	m := newMock(t)
+	m.FailEvery = 1
+	c, photos := newMockClient(t, m, nil)
+	c.MaxRetries = 10
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+	start := time.Now()
+	_, err := c.ExtractFeatures(ctx, photos+"backyard-pool.png")
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, want canceled", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("returned after %s, want promptly after cancel", elapsed)
+	}
+}
+
+// staticPhotos is a PhotoLoader over fixed bytes.
+type staticPhotos map[string][]byte
+
+func (p staticPhotos) Load(_ context.Context, url string) ([]byte, string, error) {
+	data, ok := p[url]
+	if !ok {
+		return nil, "", errors.New("not cached")
+	}
+	return data, "", nil
+}
+
+func TestHTTPClientUsesPhotoLoader(t *testing.T) {
+	m := newMock(t)
+	c, _ := newMockClient(t, m, nil)
+	c.Photos = staticPhotos{"cached://pool": MockPhoto("backyard-pool")}
+	f, err := c.ExtractFeatures(context.Background(), "cached://pool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Tags["pool"] != 0.94 {
+		t.Fatalf("tags = %v", f.Tags)
+	}
+}
@@ -0,0 +1,63 @@
+package vision
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Limiter spaces requests evenly to stay under a per-minute quota. It is safe
+// for concurrent use; a nil Limiter never waits.
+type Limiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+// NewLimiter allows perMinute requests per minute; zero or less means no limit.
+func NewLimiter(perMinute int) *Limiter {
+	if perMinute <= 0 {
+		return nil
+	}
+	return &Limiter{interval: time.Minute / time.Duration(perMinute)}
+}
+
+// Wait blocks until the caller's slot comes up or ctx is done.
+func (l *Limiter) Wait(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	now := time.Now()
+	at := l.next
+	if at.Before(now) {
+		at = now
+	}
+	l.next = at.Add(l.interval)
+	l.mu.Unlock()
+
+	d := time.Until(at)
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// Pause holds every caller back for at least d, e.g. after a 429.
+func (l *Limiter) Pause(d time.Duration) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	if until := time.Now().Add(d); l.next.Before(until) {
+		l.next = until
+	}
+	l.mu.Unlock()
+}
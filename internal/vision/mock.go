@@ -0,0 +1,205 @@
+package vision
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"embed"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"image"
+	"image/color"
+	"image/png"
+	"net/http"
+	"path"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+//go:embed recordings/*.json
+var recordings embed.FS
+
+// MockServer is a local stand-in for an OpenAI-compatible vision API. It
+// serves generated photos at /photos/{name}.png and answers
+// POST /v1/chat/completions with the recorded response for the photo it is
+// sent (recordings/{name}.json), falling back to recordings/default.json for
+// photos it does not know. Point VISION_API_BASE at http://host/v1 to run
+// HTTPClient end to end without a real model.
+type MockServer struct {
+	// APIKey is the bearer token completions require; empty accepts any.
+	APIKey string
+	// RateLimitEvery answers every Nth completion with 429 and Retry-After: 1.
+	RateLimitEvery int
+	// FailEvery answers every Nth completion with 503.
+	FailEvery int
+	// Delay is added before each completion, to exercise client timeouts.
+	Delay time.Duration
+
+	responses map[string][]byte
+	photos    map[string][]byte
+	byPhoto   map[[sha256.Size]byte]string
+	calls     atomic.Int64
+}
+
+// NewMockServer loads the embedded recordings and renders a photo for each.
+func NewMockServer() (*MockServer, error) {
+	m := &MockServer{
+		responses: map[string][]byte{},
+		photos:    map[string][]byte{},
+		byPhoto:   map[[sha256.Size]byte]string{},
+	}
+	entries, err := recordings.ReadDir("recordings")
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		name := strings.TrimSuffix(e.Name(), ".json")
+		body, err := recordings.ReadFile(path.Join("recordings", e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		if !json.Valid(body) {
+			return nil, fmt.Errorf("recording %s is not valid JSON", e.Name())
+		}
+		photo := MockPhoto(name)
+		m.responses[name] = body
+		m.photos[name] = photo
+		m.byPhoto[sha256.Sum256(photo)] = name
+	}
+	if _, ok := m.responses["default"]; !ok {
+		return nil, fmt.Errorf("recordings/default.json is missing")
+	}
+	return m, nil
+}
+
+// Recordings lists the photo names with a recorded response.
+func (m *MockServer) Recordings() []string {
+	names := make([]string, 0, len(m.responses))
+	for name := range m.responses {
+		names = append(names, name)
+	}
+	return names
+}
+
+// Calls reports how many completion requests the server has received.
+func (m *MockServer) Calls() int64 {
+	return m.calls.Load()
+}
+
+func (m *MockServer) Handler() http.Handler {
+	r := chi.NewRouter()
+	r.Get("/photos/{file}", m.servePhoto)
+	r.Post("/v1/chat/completions", m.serveCompletion)
+	return r
+}
+
+func (m *MockServer) servePhoto(w http.ResponseWriter, r *http.Request) {
+	photo, ok := m.photos[strings.TrimSuffix(chi.URLParam(r, "file"), ".png")]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "image/png")
+	_, _ = w.Write(photo)
+}
+
+func (m *MockServer) serveCompletion(w http.ResponseWriter, r *http.Request) {
+	n := m.calls.Add(1)
+	if m.APIKey != "" && r.Header.Get("Authorization") != "Bearer "+m.APIKey {
+		mockError(w, http.StatusUnauthorized, "invalid_api_key", "Incorrect API key provided.")
+		return
+	}
+	if m.Delay > 0 {
+		select {
+		case <-time.After(m.Delay):
+		case <-r.Context().Done():
+			return
+		}
+	}
+	if m.RateLimitEvery > 0 && n%int64(m.RateLimitEvery) == 0 {
+		w.Header().Set("Retry-After", "1")
+		mockError(w, http.StatusTooManyRequests, "rate_limit_exceeded", "Rate limit reached for requests.")
+		return
+	}
+	if m.FailEvery > 0 && n%int64(m.FailEvery) == 0 {
+		mockError(w, http.StatusServiceUnavailable, "server_error", "The server is overloaded.")
+		return
+	}
+
+	var req chatRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxPhotoBytes*2)).Decode(&req); err != nil {
+		mockError(w, http.StatusBadRequest, "invalid_request_error", "Could not parse request body: "+err.Error())
+		return
+	}
+	photo, err := requestPhoto(req)
+	if req.Model == "" || err != nil {
+		msg := "model is required"
+		if err != nil {
+			msg = err.Error()
+		}
+		mockError(w, http.StatusBadRequest, "invalid_request_error", msg)
+		return
+	}
+	name, ok := m.byPhoto[sha256.Sum256(photo)]
+	if !ok {
+		name = "default"
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(m.responses[name])
+}
+
+// requestPhoto pulls the inline image out of a chat request. The request is
+// decoded into the client's own types, so content parts arrive as maps.
+func requestPhoto(req chatRequest) ([]byte, error) {
+	for _, msg := range req.Messages {
+		parts, _ := msg.Content.([]any)
+		for _, p := range parts {
+			part, _ := p.(map[string]any)
+			img, _ := part["image_url"].(map[string]any)
+			dataURL, _ := img["url"].(string)
+			if dataURL == "" {
+				continue
+			}
+			_, payload, ok := strings.Cut(dataURL, ";base64,")
+			if !strings.HasPrefix(dataURL, "data:image/") || !ok {
+				return nil, fmt.Errorf("image_url must be a base64 data URL")
+			}
+			return base64.StdEncoding.DecodeString(payload)
+		}
+	}
+	return nil, fmt.Errorf("no image_url content part")
+}
+
+func mockError(w http.ResponseWriter, status int, code, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"error": map[string]string{"message": msg, "type": code, "code": code},
+	})
+}
+
+// MockPhoto renders the small PNG the mock serves for name: a flat color
+// derived from the name with a diagonal stripe, so every name hashes apart.
+func MockPhoto(name string) []byte {
+	h := fnv.New32a()
+	h.Write([]byte(name))
+	sum := h.Sum32()
+	fill := color.RGBA{R: uint8(sum), G: uint8(sum >> 8), B: uint8(sum >> 16), A: 255}
+	img := image.NewRGBA(image.Rect(0, 0, 64, 48))
+	for y := 0; y < 48; y++ {
+		for x := 0; x < 64; x++ {
+			c := fill
+			if (x+y)%16 < 3 {
+				c = color.RGBA{R: 255 - fill.R, G: 255 - fill.G, B: 255 - fill.B, A: 255}
+			}
+			img.SetRGBA(x, y, c)
+		}
+	}
+	var buf bytes.Buffer
+	_ = png.Encode(&buf, img)
+	return buf.Bytes()
+}
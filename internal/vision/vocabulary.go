@@ -0,0 +1,12 @@
+package vision
+
+// DefaultVocabulary is the closed set of tags the model may return. Keeping
+// it closed makes tags comparable across listings and usable as filters; it
+// covers the listing flags plus the visual features seen in listing photos.
+var DefaultVocabulary = []string{
+	"pool", "hot tub", "waterfront", "lake view", "water view", "city view", "mountain view",
+	"fireplace", "basement", "adu", "rv parking", "rv garage", "attached garage", "detached garage",
+	"carport", "single story", "two-story", "high-rise", "loft", "open layout", "modern kitchen",
+	"updated kitchen", "hardwood", "vaulted ceilings", "natural light", "balcony", "deck", "patio",
+	"front porch", "back yard", "fenced yard", "garden", "solar panels", "new construction", "fixer",
+}
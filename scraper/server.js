@@ -209,11 +209,19 @@ async function extractZillowCards(page, limit) {
         node.querySelector('img')?.getAttribute('src') ||
         node.querySelector('img')?.getAttribute('data-src') ||
         '';
+      // Status badges ("Pending", "Sold 3/12/24", "Under contract") sit on the card itself.
+      const statusMatch = (node.textContent || '').match(/\b(sold|pending|contingent|under contract)\b/i);
+      // Card carousels carry the rest of the gallery; lazy slides use data-src.
+      const photos = Array.from(node.querySelectorAll('img'))
+        .map((el) => ({ url: el.getAttribute('src') || el.getAttribute('data-src') || '', caption: el.getAttribute('alt') || '' }))
+        .filter((p) => p.url && !p.url.startsWith('data:'))
+        .map((p, order) => ({ ...p, order }));
 
       return {
         id: idMatch ? idMatch[1] : link || Math.random().toString(36).slice(2),
         title: normalizeText(node, '[data-testid=\"property-card-price\"]') || 'Listing',
         price: toNumber(priceText),
+        status: statusMatch ? statusMatch[1] : 'active',
         address,
         city: '',
         state: '',
@@ -237,6 +245,7 @@ async function extractZillowCards(page, limit) {
         hoaFee: 0,
         propertyType: '',
         photoUrl: img,
+        photos,
         tags: meta,
         visionTags: [],
         source: 'zillow-scraper',
@@ -279,6 +288,13 @@ async function extractRedfinCards(page, limit) {
         node.querySelector('img')?.getAttribute('src') ||
         node.querySelector('img')?.getAttribute('data-src') ||
         '';
+      // Status badges ("Pending", "Sold 3/12/24", "Under contract") sit on the card itself.
+      const statusMatch = (node.textContent || '').match(/\b(sold|pending|contingent|under contract)\b/i);
+      // Card carousels carry the rest of the gallery; lazy slides use data-src.
+      const photos = Array.from(node.querySelectorAll('img'))
+        .map((el) => ({ url: el.getAttribute('src') || el.getAttribute('data-src') || '', caption: el.getAttribute('alt') || '' }))
+        .filter((p) => p.url && !p.url.startsWith('data:'))
+        .map((p, order) => ({ ...p, order }));
       const idMatch = link.match(/\/home\/([^\/]+)/);
 
       const [city = '', state = '', zip = ''] = (cityStateZip || '').split(',').map((p) => p.trim().replace(/\s+/g, ' '));
@@ -287,6 +303,7 @@ async function extractRedfinCards(page, limit) {
         id: idMatch ? idMatch[1] : link || Math.random().toString(36).slice(2),
         title: price?.trim() || 'Listing',
         price: toNumber(price),
+        status: statusMatch ? statusMatch[1] : 'active',
         address,
         city,
         state,
@@ -310,6 +327,7 @@ async function extractRedfinCards(page, limit) {
         hoaFee: 0,
         propertyType: '',
         photoUrl: img,
+        photos,
         tags: [],
         visionTags: [],
         source: 'redfin-scraper',
@@ -339,6 +357,13 @@ async function extractRealtorCards(page, limit) {
         node.querySelector('img')?.getAttribute('src') ||
         node.querySelector('img')?.getAttribute('data-src') ||
         '';
+      // Status badges ("Pending", "Sold 3/12/24", "Under contract") sit on the card itself.
+      const statusMatch = (node.textContent || '').match(/\b(sold|pending|contingent|under contract)\b/i);
+      // Card carousels carry the rest of the gallery; lazy slides use data-src.
+      const photos = Array.from(node.querySelectorAll('img'))
+        .map((el) => ({ url: el.getAttribute('src') || el.getAttribute('data-src') || '', caption: el.getAttribute('alt') || '' }))
+        .filter((p) => p.url && !p.url.startsWith('data:'))
+        .map((p, order) => ({ ...p, order }));
 
       const [city = '', rest = ''] = (cityStateZip || '').split(',').map((p) => p.trim());
       const [state = '', zip = ''] = rest.split(' ').filter(Boolean);
@@ -347,6 +372,7 @@ async function extractRealtorCards(page, limit) {
         id: link || Math.random().toString(36).slice(2),
         title: price || 'Listing',
         price: toNumber(price),
+        status: statusMatch ? statusMatch[1] : 'active',
         address,
         city,
         state,
@@ -370,6 +396,7 @@ async function extractRealtorCards(page, limit) {
         hoaFee: 0,
         propertyType: '',
         photoUrl: img,
+        photos,
         tags: [],
         visionTags: [],
         source: 'realtor-scraper',